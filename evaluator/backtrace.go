@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// callFrame はバックトレースの1フレームで、呼び出された手続きの名前と呼び出し式です。
+type callFrame struct {
+	procedure string
+	form      parser.Expr
+}
+
+// backtraceError はクロージャの呼び出しを抜けて伝播するエラーに、呼び出しの連鎖を付加したものです。
+// frames は内側（エラーの発生源に近い方）から順に並びます。
+type backtraceError struct {
+	err    error
+	frames []callFrame
+}
+
+// Error は元のエラーメッセージに続けて、バックトレースを内側から順に返します。
+func (e *backtraceError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.err.Error())
+	sb.WriteString("\nbacktrace:")
+	for _, f := range e.frames {
+		sb.WriteString("\n  in ")
+		sb.WriteString(f.procedure)
+		sb.WriteString(": ")
+		sb.WriteString(WriteString(f.form))
+	}
+	return sb.String()
+}
+
+// Unwrap は元のエラーを返します。
+func (e *backtraceError) Unwrap() error {
+	return e.err
+}
+
+// withCallFrame はクロージャの呼び出し form で発生したエラーに、呼び出しのフレームを追加します。
+func withCallFrame(err error, callable Callable, form parser.List) error {
+	if _, ok := callable.(*Closure); !ok {
+		return err
+	}
+	return appendFrame(err, newCallFrame(callable, form[0], form))
+}
+
+// newCallFrame は呼び出し form のフレームを作ります。
+// 手続きの名前は演算子の位置の式 opForm がシンボルならその名前、そうでなければ手続きの外部表現です。
+func newCallFrame(callable Callable, opForm parser.Expr, form parser.List) callFrame {
+	name, ok := opForm.(parser.Symbol)
+	if !ok {
+		name = parser.Symbol(WriteString(callable))
+	}
+	return callFrame{procedure: string(name), form: form}
+}
+
+// appendFrame はエラーのバックトレースの外側に frame を追加します。
+func appendFrame(err error, frame callFrame) error {
+	var bt *backtraceError
+	if errors.As(err, &bt) {
+		bt.frames = append(bt.frames, frame)
+		return err
+	}
+	return &backtraceError{err: err, frames: []callFrame{frame}}
+}
+
+// maxTailFrames は末尾呼び出しについて、バックトレースのために覚えておくフレームの最大数です。
+// 末尾呼び出しによる繰り返しでメモリを消費し続けないよう、これを超えると古いフレームから捨てます。
+const maxTailFrames = 64
+
+// tailFrames は末尾呼び出しのフレームを、直近の maxTailFrames 個まで覚えておくリングバッファです。
+type tailFrames struct {
+	frames []callFrame
+	// oldest は最も古いフレームの位置です
+	oldest int
+}
+
+// push はフレームを追加します。満杯であれば最も古いフレームを上書きします。
+func (t *tailFrames) push(frame callFrame) {
+	if len(t.frames) < maxTailFrames {
+		t.frames = append(t.frames, frame)
+		return
+	}
+	t.frames[t.oldest] = frame
+	t.oldest = (t.oldest + 1) % maxTailFrames
+}
+
+// attach は覚えているフレームを、新しいもの（内側）から順にエラーのバックトレースへ追加します。
+func (t *tailFrames) attach(err error) error {
+	for i := len(t.frames) - 1; i >= 0; i-- {
+		err = appendFrame(err, t.frames[(t.oldest+i)%len(t.frames)])
+	}
+	return err
+}
+
+// innermostError はバックトレースを取り除いた、エラーの発生源のエラーを返します。
+func innermostError(err error) error {
+	var bt *backtraceError
+	if errors.As(err, &bt) {
+		return bt.err
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestBacktrace は深い呼び出しの中で発生したエラーのメッセージが、発生源のメッセージに続けて
+// 途中の手続きの名前を内側から順に並べたバックトレースを含むことをテストします。
+func TestBacktrace(t *testing.T) {
+	input := `
+	(define (inner x) (error "boom" x))
+	(define (middle x) (inner (* x 2)))
+	(define (outer x) (middle (+ x 1)))
+	(outer 1)
+	`
+	expected := `boom 4
+backtrace:
+  in inner: (inner (* x 2))
+  in middle: (middle (+ x 1))
+  in outer: (outer 1)`
+	for name, eval := range map[string]func(string) error{
+		"Eval": func(input string) error {
+			_, err := evalString(t, NewGlobalEnv(), input)
+			return err
+		},
+		"Compile": func(input string) error {
+			exprs, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			_, err = evalCompiled(exprs, NewGlobalEnv())
+			return err
+		},
+	} {
+		err := eval(input)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q, got %v", name, expected, err)
+		}
+	}
+
+	// 組み込み関数のエラーや、トップレベルで発生したエラーにはフレームを付けない
+	if _, err := evalString(t, NewGlobalEnv(), `(+ 1 "a")`); err == nil || err.Error() != "+: invalid argument type parser.String" {
+		t.Errorf("expected a plain error, got %v", err)
+	}
+	// 演算子がシンボルでない場合は手続きの外部表現を名前とする
+	got, err := evalString(t, NewGlobalEnv(), `((lambda (x) (error "bad")) 1)`)
+	if err == nil || err.Error() != "bad\nbacktrace:\n  in #<closure (x)>: ((lambda (x) (error \"bad\")) 1)" {
+		t.Errorf("unexpected result %v, error %v", got, err)
+	}
+	// guard で捕捉した condition のメッセージにはバックトレースを含めない
+	if got := evalToString(t, NewGlobalEnv(), `(define (f) (car '())) (guard (e (#t (error-message e))) (f))`); got != `"undefined symbol: car"` {
+		t.Errorf(`expected "undefined symbol: car", got %s`, got)
+	}
+}
@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// caseClause は (case key ((datum...) body...) ... (else body...)) の key を評価し、
+// 値が datum のいずれかと同じになる最初の節（else はどの値にも一致する）の body を返します。
+// body は末尾位置にあるため、評価は呼び出し側の eval が行います。どの節にも一致しなければ空の body を返します。
+func caseClause(exp parser.List, env *Env) ([]parser.Expr, error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("case: too few arguments")
+	}
+	key, err := evalExpr(exp[1], env)
+	if err != nil {
+		return nil, err
+	}
+	for i, clause := range exp[2:] {
+		c, ok := clause.(parser.List)
+		if !ok || len(c) == 0 {
+			return nil, fmt.Errorf("case: clause must be a non-empty list, got %s", WriteString(clause))
+		}
+		if c[0] == parser.Symbol("else") {
+			if i != len(exp)-3 {
+				return nil, fmt.Errorf("case: else clause must be last")
+			}
+			if len(c) == 1 {
+				return nil, fmt.Errorf("case: else clause must have a body")
+			}
+			return c[1:], nil
+		}
+		data, ok := c[0].(parser.List)
+		if !ok {
+			return nil, fmt.Errorf("case: data must be a list, got %s", WriteString(c[0]))
+		}
+		for _, datum := range data {
+			if caseMatches(key, datum) {
+				return c[1:], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// caseMatches は case の key の値が datum と同じかを判定します。
+// eq? と同じ比較ですが、有理数は同一性ではなく値で比較します。
+func caseMatches(key, datum parser.Expr) bool {
+	if _, ok := datum.(parser.Rational); ok {
+		return isEqual(key, datum)
+	}
+	return isEq(key, datum)
+}
@@ -0,0 +1,66 @@
+package evaluator
+
+import "testing"
+
+// TestWhenUnless は when が test の真のとき、unless が偽のときだけ本体を評価し、
+// そうでなければ未規定値を返すことをテストします。
+func TestWhenUnless(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(when (< 1 2) 'yes)`, "yes"},
+		{`(unless (< 1 2) 'yes)`, ""},
+		{`(when #f 'yes)`, ""},
+		{`(unless #f 'no)`, "no"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{`(when #t 1 2 3)`, "3"},
+		// test が偽なら本体は評価しない
+		{`(when #f (error "not evaluated"))`, ""},
+		{`(unless #t (error "not evaluated"))`, ""},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(when)", "(when #t)", "(unless #f)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestCase は case が key の値を含む最初の節の本体を評価し、どれにも含まれなければ else 節を、
+// else 節もなければ未規定値を返すことをテストします。
+func TestCase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(define (kind x) (case x ((1 2 3) 'small) ((a b) 'letter) (else 'other))) (kind 2)`, "small"},
+		{`(define (kind x) (case x ((1 2 3) 'small) ((a b) 'letter) (else 'other))) (kind 'b)`, "letter"},
+		{`(define (kind x) (case x ((1 2 3) 'small) ((a b) 'letter) (else 'other))) (kind "a")`, "other"},
+		{`(case #\a ((#\a) 'char))`, "char"},
+		// 有理数は値で比較し、正確数と不正確数は一致しない
+		{`(case (* 1/2 1) ((1/2) 'half))`, "half"},
+		{`(case 1/2 ((0.5) 'inexact) (else 'exact))`, "exact"},
+		// リストは eq? と同じく同一性で比較するため、内容が同じでも一致しない
+		{`(case (list 1) (((1)) 'same) (else 'different))`, "different"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{`(case 'x ((x) 1 2 3))`, "3"},
+		// 一致する節がなければ未規定値
+		{`(case 4 ((1 2 3) 'small))`, ""},
+		{`(case 4)`, ""},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(case)", "(case 1 2)", "(case 1 (1 'a))", "(case 1 ())", "(case 1 (else 'a) ((1) 'b))", "(case 1 (else))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
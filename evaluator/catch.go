@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// thrown は throw によって送出される値です。
+// Go の panic として送出し、タグが一致する catch の recover で受け取ります。
+type thrown struct {
+	tag   parser.Symbol
+	value parser.Expr
+}
+
+// evalCatch は (catch tag body...) を評価します。
+// 本体の評価中に同じタグで throw されると、その値を catch の値として返します。
+// タグの異なる throw は外側の catch へそのまま伝播させます。
+func evalCatch(exp parser.List, env *Env) (result parser.Expr, err error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("catch: too few arguments")
+	}
+	tagVal, err := evalExpr(exp[1], env)
+	if err != nil {
+		return nil, err
+	}
+	tag, ok := tagVal.(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("catch: tag must be a symbol, got %s", WriteString(tagVal))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			t, ok := r.(*thrown)
+			if !ok || t.tag != tag {
+				panic(r)
+			}
+			result, err = t.value, nil
+		}
+	}()
+	return evalBody(exp[2:], env)
+}
+
+// builtinThrow は "throw" を実装します。
+// (throw tag value) タグが一致する最も内側の catch まで脱出します。
+func builtinThrow(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("throw: wrong number of arguments")
+	}
+	tag, ok := args[0].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("throw: tag must be a symbol, got %s", WriteString(args[0]))
+	}
+	panic(&thrown{tag: tag, value: args[1]})
+}
+
+// uncaughtThrow は recover した値が catch されなかった throw であれば、トップレベルのエラーに変換します。
+// それ以外の panic はそのまま送出し直します。
+func uncaughtThrow(env *Env, r any) error {
+	t, ok := r.(*thrown)
+	if !ok {
+		panic(r)
+	}
+	return env.signal(fmt.Errorf("throw: no catch for tag %s", t.tag))
+}
+
+// registerCatchBuiltins は catch/throw 関連の組み込み関数を環境に登録します。
+func registerCatchBuiltins(env *Env) {
+	env.Set("throw", &Builtin{Name: "throw", Fn: builtinThrow})
+}
@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestCatchThrow は throw が最も内側の一致する catch まで脱出し、その catch の値になることをテストします。
+func TestCatchThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(catch 'done (throw 'done 42))", "42"},
+		{"(catch 'done 1 2 3)", "3"},
+		{"(catch 'done (+ 1 (throw 'done 10)))", "10"},
+		// タグの異なる内側の catch を通り越して外側の catch へ届く
+		{"(catch 'outer (+ 1 (catch 'inner (throw 'outer 'escaped))))", "escaped"},
+		{"(catch 'outer (+ 1 (catch 'inner (throw 'inner 2))))", "3"},
+		// 関数の中からの throw も脱出できる
+		{"(define (f x) (throw 'found x)) (catch 'found (f 7) 0)", "7"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestUncaughtThrow は一致する catch がない throw がトップレベルのエラーになることをテストします。
+func TestUncaughtThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(throw 'nowhere 1)", "throw: no catch for tag nowhere"},
+		{"(catch 'other (throw 'nowhere 1))", "throw: no catch for tag nowhere"},
+		{"(catch 1 2)", "catch: tag must be a symbol, got 1"},
+		{"(throw \"tag\" 1)", `throw: tag must be a symbol, got "tag"`},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+		if result != nil {
+			t.Errorf("%s: expected nil result, got %v", tt.input, result)
+		}
+	}
+}
+
+// TestUncaughtThrowFromEval は EvalAll を経由せずに Eval や Compile した式の Eval を直接呼び出しても、
+// 一致する catch がない throw が panic ではなくエラーになることをテストします。
+func TestUncaughtThrowFromEval(t *testing.T) {
+	expr, err := parser.NewParser(strings.NewReader("(begin (define (f x) (throw 'k x)) (catch 'other (f 1)))")).ParseExpr()
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+	const expected = "throw: no catch for tag k"
+	if result, err := Eval(expr, NewGlobalEnv()); err == nil || err.Error() != expected || result != nil {
+		t.Errorf("Eval: expected error %q and nil result, got %v, %v", expected, result, err)
+	}
+	compiled, err := Compile(expr)
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+	if result, err := compiled.Eval(NewGlobalEnv()); err == nil || err.Error() != expected || result != nil {
+		t.Errorf("compiled Eval: expected error %q and nil result, got %v, %v", expected, result, err)
+	}
+	// 一致する catch があれば、コンパイルした式の中でも捕捉できる
+	caught, err := parser.NewParser(strings.NewReader("(catch 'k (+ 1 (throw 'k 41)))")).ParseExpr()
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+	if compiled, err = Compile(caught); err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+	if result, err := compiled.Eval(NewGlobalEnv()); err != nil || result != parser.Integer(41) {
+		t.Errorf("compiled catch: expected 41, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,319 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// CompiledExpr は Compile によって前処理された式です。
+// 特殊フォームの判定や構文チェックはコンパイル時に済んでいるため、
+// Eval は Go の関数呼び出しの連鎖として実行されます。
+type CompiledExpr interface {
+	// Eval は環境 env のもとで式を実行し、その結果を返します。
+	Eval(env *Env) (parser.Expr, error)
+}
+
+// compiledFunc は関数を CompiledExpr として扱うためのアダプタです。
+type compiledFunc func(env *Env) (parser.Expr, error)
+
+// Eval は compiledFunc 自身を呼び出します。
+func (f compiledFunc) Eval(env *Env) (parser.Expr, error) {
+	return f(env)
+}
+
+// Compile は AST をクロージャの連鎖に変換します。
+// 同じ式を何度も評価する場合、Eval で毎回 AST を走査するよりも高速に実行できます。
+// 評価する位置に循環するリストを含む式は、実行すると終わらないためエラーにします。
+// 返す式の Eval は、Eval と同じくどの catch にも捕捉されなかった throw をエラーとして返します。
+func Compile(expr parser.Expr) (CompiledExpr, error) {
+	if err := checkAcyclic(expr); err != nil {
+		return nil, err
+	}
+	compiled, err := compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return compiledFunc(func(env *Env) (result parser.Expr, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, uncaughtThrow(env, r)
+			}
+		}()
+		result, err = compiled.Eval(env)
+		if err != nil {
+			return nil, env.signal(err)
+		}
+		return result, nil
+	}), nil
+}
+
+// compile は Compile の本体です。部分式のコンパイルにも使います。
+func compile(expr parser.Expr) (CompiledExpr, error) {
+	switch exp := expr.(type) {
+	case parser.Integer, parser.Rational, parser.Float, parser.String, parser.Boolean, parser.Char, parser.Comment, *Vector:
+		return compiledFunc(func(*Env) (parser.Expr, error) {
+			return exp, nil
+		}), nil
+
+	case parser.Symbol:
+		return compiledFunc(func(env *Env) (parser.Expr, error) {
+			return lookupSymbol(exp, env)
+		}), nil
+
+	case parser.List:
+		return compileList(exp)
+
+	default:
+		return nil, fmt.Errorf("cannot evaluate expression: %v", expr)
+	}
+}
+
+// compileList はリスト（特殊フォームまたは関数適用）をコンパイルします。
+func compileList(exp parser.List) (CompiledExpr, error) {
+	if len(exp) == 0 {
+		return nil, fmt.Errorf("cannot evaluate empty list")
+	}
+	if firstSym, ok := exp[0].(parser.Symbol); ok {
+		switch firstSym {
+		case "quote":
+			if len(exp) != 2 {
+				return nil, fmt.Errorf("quote: wrong number of arguments")
+			}
+			quoted := exp[1]
+			return compiledFunc(func(*Env) (parser.Expr, error) {
+				return quoted, nil
+			}), nil
+		case "define":
+			return compileDefine(exp)
+		case "lambda":
+			return compileLambda(exp)
+		case "if":
+			return compileIf(exp)
+		case "begin":
+			return compileBegin(exp)
+		default:
+			// 個別にコンパイルしない特殊フォームは、実行時に eval で評価します
+			if specialForms[firstSym] {
+				return compiledFunc(func(env *Env) (parser.Expr, error) {
+					return eval(exp, env)
+				}), nil
+			}
+		}
+	}
+	return compileApplication(exp)
+}
+
+// compileDefine は (define var expr) と (define (fun arg...) body...) をコンパイルします。
+func compileDefine(exp parser.List) (CompiledExpr, error) {
+	if len(exp) < 3 {
+		return nil, fmt.Errorf("define: too few arguments")
+	}
+	if list, ok := exp[1].(parser.List); ok {
+		if len(list) == 0 {
+			return nil, fmt.Errorf("define: invalid function definition")
+		}
+		funName, ok := list[0].(parser.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("define: function name must be a symbol")
+		}
+		if err := checkBindable("define", funName); err != nil {
+			return nil, err
+		}
+		params, err := lambdaParams("define", "define: function parameters must be symbols or lists", list[1:])
+		if err != nil {
+			return nil, err
+		}
+		makeClosure, err := compileClosure(params, lambdaBody(exp))
+		if err != nil {
+			return nil, err
+		}
+		return compiledFunc(func(env *Env) (parser.Expr, error) {
+			if err := env.checkAssignable("define", funName); err != nil {
+				return nil, err
+			}
+			closure := makeClosure(env)
+			closure.name = funName
+			env.warnShadowing(funName, exp)
+			env.Set(funName, closure)
+			return funName, nil
+		}), nil
+	}
+	varName, ok := exp[1].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("define: first argument must be a symbol")
+	}
+	if err := checkBindable("define", varName); err != nil {
+		return nil, err
+	}
+	value, err := compile(exp[2])
+	if err != nil {
+		return nil, err
+	}
+	return compiledFunc(func(env *Env) (parser.Expr, error) {
+		if err := env.checkAssignable("define", varName); err != nil {
+			return nil, err
+		}
+		val, err := value.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		env.warnShadowing(varName, exp)
+		env.Set(varName, val)
+		return varName, nil
+	}), nil
+}
+
+// compileLambda は (lambda (params...) body...) をコンパイルします。
+func compileLambda(exp parser.List) (CompiledExpr, error) {
+	if len(exp) < 3 {
+		return nil, fmt.Errorf("lambda: too few arguments")
+	}
+	paramList, ok := exp[1].(parser.List)
+	if !ok {
+		return nil, fmt.Errorf("lambda: first argument must be a list of parameters")
+	}
+	params, err := lambdaParams("lambda", "lambda: parameters must be symbols or lists", paramList)
+	if err != nil {
+		return nil, err
+	}
+	makeClosure, err := compileClosure(params, lambdaBody(exp))
+	if err != nil {
+		return nil, err
+	}
+	return compiledFunc(func(env *Env) (parser.Expr, error) {
+		return makeClosure(env), nil
+	}), nil
+}
+
+// compileIf は (if test then [else]) をコンパイルします。else がなく test が偽なら Unspecified を返します。
+func compileIf(exp parser.List) (CompiledExpr, error) {
+	if len(exp) != 3 && len(exp) != 4 {
+		return nil, fmt.Errorf("if: wrong number of arguments")
+	}
+	test, err := compile(exp[1])
+	if err != nil {
+		return nil, err
+	}
+	then, err := compile(exp[2])
+	if err != nil {
+		return nil, err
+	}
+	var alt CompiledExpr = compiledFunc(func(*Env) (parser.Expr, error) {
+		return Unspecified, nil
+	})
+	if len(exp) == 4 {
+		if alt, err = compile(exp[3]); err != nil {
+			return nil, err
+		}
+	}
+	return compiledFunc(func(env *Env) (parser.Expr, error) {
+		val, err := test.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(val) {
+			return then.Eval(env)
+		}
+		return alt.Eval(env)
+	}), nil
+}
+
+// compileBegin は (begin expr...) をコンパイルします。式がなければ Unspecified を返します。
+func compileBegin(exp parser.List) (CompiledExpr, error) {
+	body := make([]CompiledExpr, len(exp)-1)
+	for i, e := range exp[1:] {
+		var err error
+		if body[i], err = compile(e); err != nil {
+			return nil, err
+		}
+	}
+	return compiledFunc(func(env *Env) (parser.Expr, error) {
+		var result parser.Expr = Unspecified
+		for _, e := range body {
+			var err error
+			if result, err = e.Eval(env); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}), nil
+}
+
+// compileClosure は本体をコンパイルし、環境を受け取ってクロージャを生成する関数を返します。
+func compileClosure(params []parser.Expr, body parser.Expr) (func(*Env) *Closure, error) {
+	compiled, err := compile(body)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) *Closure {
+		return &Closure{
+			params:   params,
+			body:     body,
+			env:      env,
+			compiled: compiled,
+		}
+	}, nil
+}
+
+// compileApplication は関数適用をコンパイルします。
+func compileApplication(exp parser.List) (CompiledExpr, error) {
+	op, err := compile(exp[0])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]CompiledExpr, len(exp)-1)
+	for i, arg := range exp[1:] {
+		if args[i], err = compile(arg); err != nil {
+			return nil, err
+		}
+	}
+	run := compiledApplication(exp, op, args)
+	// Eval と同じく、呼び出しで起きたエラーはその場で例外ハンドラへ通知する
+	return compiledFunc(func(env *Env) (parser.Expr, error) {
+		result, err := run(env)
+		if err != nil {
+			return nil, env.signal(err)
+		}
+		return result, nil
+	}), nil
+}
+
+// compiledApplication はコンパイル済みの演算子と引数を評価し、手続きを呼び出します。
+func compiledApplication(exp parser.List, op CompiledExpr, args []CompiledExpr) func(*Env) (parser.Expr, error) {
+	return func(env *Env) (parser.Expr, error) {
+		if err := env.checkArgCount(exp[0], len(args)); err != nil {
+			return nil, err
+		}
+		fn, err := op.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]parser.Expr, 0, len(args))
+		for _, arg := range args {
+			val, err := arg.Eval(env)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+		callable, ok := fn.(Callable)
+		if !ok {
+			return nil, notCallableError(exp[0], fn)
+		}
+		// apply は環境で設定された引数の上限に従って展開する
+		if callable == Callable(applyBuiltin) {
+			proc, spread, err := spreadApplyArgs(vals, env.argLimit())
+			if err != nil {
+				return nil, withCallFrame(err, callable, exp)
+			}
+			callable, vals = proc, spread
+		}
+		env.tracer.called(callable)
+		result, err := callable.Call(vals)
+		if err != nil {
+			return nil, withCallFrame(err, callable, exp)
+		}
+		return result, nil
+	}
+}
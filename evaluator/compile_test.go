@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// compileTestPrograms は Eval と Compile の結果を比較するためのプログラム群です。
+var compileTestPrograms = []string{
+	"42",
+	`"str"`,
+	"(+ 1 2 3)",
+	"(* 2 3.5)",
+	"'(1 2 (3 4))",
+	"(define x 10) (+ x x)",
+	"(define (square x) (* x x)) (square 7)",
+	"((lambda (a b) (+ a b)) 3 4)",
+	"(define (make-adder n) (lambda (x) (+ x n))) (define add5 (make-adder 5)) (add5 10)",
+	"(define (compose f g) (lambda (x) (f (g x)))) ((compose (lambda (x) (* x 2)) (lambda (x) (+ x 1))) 4)",
+	// compileIf: 両方の分岐、else のない if、#f 以外の値を真とみなす場合
+	"(if (< 1 2) 'yes 'no)",
+	"(if (> 1 2) 'yes 'no)",
+	"(if #f 'never)",
+	"(if '() 'empty-is-true 'no)",
+	"(define (fact n) (if (= n 0) 1 (* n (fact (- n 1))))) (fact 10)",
+	// compileBegin: 空の begin、順に評価して最後の値を返す場合、begin の中の define
+	"(begin)",
+	"(begin 1 2 3)",
+	"(begin (define y 4) (define z (* y y)) (list y z))",
+	"(define (f x) (begin (define w (+ x 1)) (if (> w 2) (begin 'big) 'small))) (list (f 1) (f 5))",
+}
+
+// evalCompiled は各式をコンパイルしてから順次実行し、最後の結果を返します。
+func evalCompiled(exprs []parser.Expr, env *Env) (parser.Expr, error) {
+	var result parser.Expr
+	for _, expr := range exprs {
+		compiled, err := Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		result, err = compiled.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// TestCompileMatchesEval はコンパイル済みの式の実行結果が Eval と一致することをテストします。
+func TestCompileMatchesEval(t *testing.T) {
+	for _, input := range compileTestPrograms {
+		exprs, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		expected, err := EvalAll(exprs, NewGlobalEnv())
+		if err != nil {
+			t.Fatalf("%s: EvalAll error: %v", input, err)
+		}
+		got, err := evalCompiled(exprs, NewGlobalEnv())
+		if err != nil {
+			t.Fatalf("%s: compiled evaluation error: %v", input, err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", input, expected, got)
+		}
+	}
+}
+
+// TestCompileErrors は構文エラーがコンパイル時に検出されることをテストします。
+func TestCompileErrors(t *testing.T) {
+	inputs := []string{"(quote)", "(define x)", "(lambda x x)", "(lambda (1) x)", "()", "(if)", "(if 1 2 3 4)", "(begin (if))"}
+	for _, input := range inputs {
+		expr, err := parser.NewParser(strings.NewReader(input)).ParseExpr()
+		if err != nil {
+			t.Fatalf("ParseExpr error: %v", err)
+		}
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("%s: expected compile error, got nil", input)
+		}
+	}
+}
+
+// benchmarkProgram は再帰する関数を定義するベンチマーク用のプログラムです。
+const benchmarkProgram = `
+(define (fib n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))
+`
+
+// benchmarkExpr は各イテレーションで評価する式です。
+const benchmarkExpr = "(fib 15)"
+
+// benchmarkSetup はベンチマーク用の環境と評価対象の式を準備します。
+// compiled が真の場合、関数定義もコンパイルしてから実行します。
+func benchmarkSetup(b *testing.B, compiled bool) (*Env, parser.Expr) {
+	b.Helper()
+	env := NewGlobalEnv()
+	exprs, err := parser.NewParser(strings.NewReader(benchmarkProgram)).ParseAll()
+	if err != nil {
+		b.Fatalf("ParseAll error: %v", err)
+	}
+	run := EvalAll
+	if compiled {
+		run = evalCompiled
+	}
+	if _, err := run(exprs, env); err != nil {
+		b.Fatalf("setup error: %v", err)
+	}
+	expr, err := parser.NewParser(strings.NewReader(benchmarkExpr)).ParseExpr()
+	if err != nil {
+		b.Fatalf("ParseExpr error: %v", err)
+	}
+	return env, expr
+}
+
+// BenchmarkEvalInterpreted は Eval による評価の速度を測定します。
+func BenchmarkEvalInterpreted(b *testing.B) {
+	env, expr := benchmarkSetup(b, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Eval(expr, env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEvalCompiled はコンパイル済みの式の実行速度を測定します。
+func BenchmarkEvalCompiled(b *testing.B) {
+	env, expr := benchmarkSetup(b, true)
+	compiled, err := Compile(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := compiled.Eval(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
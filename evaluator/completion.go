@@ -0,0 +1,25 @@
+package evaluator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// CompletionsFor は環境（外側の環境を含む）で束縛されているシンボルのうち、prefix で始まるものを名前順に返します。
+// 内側と外側の環境で同じ名前が束縛されていても1つにまとめます。REPL の補完に使うことを想定しています。
+func (env *Env) CompletionsFor(prefix string) []parser.Symbol {
+	seen := make(map[parser.Symbol]bool)
+	var names []parser.Symbol
+	for e := env; e != nil; e = e.outer {
+		for _, name := range e.bindings() {
+			if !seen[name] && strings.HasPrefix(string(name), prefix) {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
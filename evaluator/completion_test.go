@@ -0,0 +1,37 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestCompletionsFor は CompletionsFor が外側の環境を含めて prefix で始まる束縛を重複なく名前順に返すことをテストします。
+func TestCompletionsFor(t *testing.T) {
+	global := NewGlobalEnv()
+	if _, err := evalString(t, global, `
+(define my-zeta 1)
+(define my-alpha 2)
+(define (my-func x) x)
+(define other 3)
+`); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	inner := NewEnv(global)
+	inner.Set("my-inner", parser.Integer(4))
+	// 外側と同じ名前は1つにまとめる
+	inner.Set("my-alpha", parser.Integer(5))
+
+	expected := []parser.Symbol{"my-alpha", "my-func", "my-inner", "my-zeta"}
+	if got := inner.CompletionsFor("my-"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if got := global.CompletionsFor("my-i"); len(got) != 0 {
+		t.Errorf("expected no completions in the global environment, got %v", got)
+	}
+	// 組み込み関数も補完の対象
+	if got := global.CompletionsFor("list-ta"); !reflect.DeepEqual(got, []parser.Symbol{"list-tabulate"}) {
+		t.Errorf("expected [list-tabulate], got %v", got)
+	}
+}
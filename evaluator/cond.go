@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// condClause は (cond (test body...) ... (else body...)) の test を上から順に評価し、
+// 真になった最初の節（else はつねに真）の body を返します。
+// body は末尾位置にあるため、評価は呼び出し側の eval が行います。
+// body のない節 (test) が選ばれた場合は body の代わりに test の値を value として返し、
+// どの節も選ばれなければ body と value はどちらも空です。
+func condClause(exp parser.List, env *Env) (body []parser.Expr, value parser.Expr, err error) {
+	for i, clause := range exp[1:] {
+		c, ok := clause.(parser.List)
+		if !ok || len(c) == 0 {
+			return nil, nil, fmt.Errorf("cond: clause must be a non-empty list, got %s", WriteString(clause))
+		}
+		if c[0] == parser.Symbol("else") {
+			if i != len(exp)-2 {
+				return nil, nil, fmt.Errorf("cond: else clause must be last")
+			}
+			if len(c) == 1 {
+				return nil, nil, fmt.Errorf("cond: else clause must have a body")
+			}
+			return c[1:], nil, nil
+		}
+		test, err := evalExpr(c[0], env)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isTrue(test) {
+			continue
+		}
+		if len(c) == 1 {
+			return nil, test, nil
+		}
+		return c[1:], nil, nil
+	}
+	return nil, nil, nil
+}
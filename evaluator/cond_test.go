@@ -0,0 +1,35 @@
+package evaluator
+
+import "testing"
+
+// TestCond は cond が test の真になった最初の節の本体を評価し、どれも真でなければ else 節を、
+// else 節もなければ未規定値を返すことをテストします。
+func TestCond(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(define (sign x) (cond ((< x 0) 'negative) ((= x 0) 'zero) (else 'positive))) (sign 5)`, "positive"},
+		{`(define (sign x) (cond ((< x 0) 'negative) ((= x 0) 'zero) (else 'positive))) (sign 0)`, "zero"},
+		{`(define (sign x) (cond ((< x 0) 'negative) ((= x 0) 'zero) (else 'positive))) (sign -3)`, "negative"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{`(cond (#t 1 2 3))`, "3"},
+		// 真になった節より後の test は評価しない
+		{`(cond (#t 'first) ((error "not evaluated") 'second))`, "first"},
+		// 本体のない節は test の値を返す
+		{`(cond (#f 'no) ((+ 1 2)))`, "3"},
+		// 一致する節がなければ未規定値
+		{`(cond ((= 1 2) 'a) (#f 'b))`, ""},
+		{`(cond)`, ""},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(cond 1)", "(cond (#f 1) x)", "(cond ())", "(cond (else 1) (#t 2))", "(cond (else))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// checkAssignable は form（define や fluid-let）がこのフレームの束縛 sym を書き換えられるかを確認します。
+// define-constant で束縛した変数は書き換えられないため、エラーにします。
+func (env *Env) checkAssignable(form string, sym parser.Symbol) error {
+	if env.constants[sym] {
+		return fmt.Errorf("%s: cannot reassign constant %s", form, sym)
+	}
+	return nil
+}
+
+// evalDefineConstant は (define-constant name expr) を評価します。
+// define と同じく現在のフレームに束縛しますが、以後そのフレームでの define や fluid-let による書き換えはエラーになります。
+// 内側のフレームで同じ名前を束縛すること（lambda の仮引数など）は妨げません。
+func evalDefineConstant(exp parser.List, env *Env) (parser.Expr, error) {
+	if len(exp) != 3 {
+		return nil, fmt.Errorf("define-constant: wrong number of arguments")
+	}
+	name, ok := exp[1].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("define-constant: first argument must be a symbol")
+	}
+	if err := checkBindable("define-constant", name); err != nil {
+		return nil, err
+	}
+	if err := env.checkAssignable("define-constant", name); err != nil {
+		return nil, err
+	}
+	value, err := evalExpr(exp[2], env)
+	if err != nil {
+		return nil, err
+	}
+	env.warnShadowing(name, exp)
+	env.Set(name, value)
+	env.markConstant(name)
+	return name, nil
+}
+
+// markConstant はこのフレームの束縛 name を define-constant による定数として記録します。
+func (env *Env) markConstant(name parser.Symbol) {
+	if env.constants == nil {
+		env.constants = make(map[parser.Symbol]bool)
+	}
+	env.constants[name] = true
+}
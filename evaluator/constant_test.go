@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestDefineConstant は define-constant で束縛した変数は参照できるが、同じフレームで書き換えられないこと、
+// 通常の define で束縛した変数は書き換えられることをテストします。
+func TestDefineConstant(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define-constant pi 3.14) pi", "3.14"},
+		{"(define-constant n (+ 1 2)) (list n n)", "(3 3)"},
+		{"(define x 1) (define x 2) x", "2"},
+		// 内側のフレームで同じ名前を束縛し直すことはできる
+		{"(define-constant x 1) ((lambda (x) x) 2)", "2"},
+		{"(define-constant x 1) (define (f) (define x 5)) (list (f) x)", "(x 1)"},
+	}
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define-constant pi 3.14) (define pi 3)", "define: cannot reassign constant pi"},
+		{"(define-constant f 1) (define (f) 2)", "define: cannot reassign constant f"},
+		{"(define-constant pi 3.14) (define-constant pi 3)", "define-constant: cannot reassign constant pi"},
+		{"(define-constant pi 3.14) (fluid-let ((pi 3)) pi)", "fluid-let: cannot reassign constant pi"},
+		{"(define-constant 1 2)", "define-constant: first argument must be a symbol"},
+		{"(define-constant x)", "define-constant: wrong number of arguments"},
+		{"(define-constant lambda 1)", "define-constant: cannot bind special form name lambda"},
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, tt := range tests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			result, err := run(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", tt.input, name, err)
+				continue
+			}
+			if got := WriteString(result); got != tt.expected {
+				t.Errorf("%s (%s): expected %s, got %s", tt.input, name, tt.expected, got)
+			}
+		}
+		for _, tt := range errTests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			_, err = run(exprs, NewGlobalEnv())
+			if err == nil || innermostError(err).Error() != tt.expected {
+				t.Errorf("%s (%s): expected error %q, got %v", tt.input, name, tt.expected, err)
+			}
+		}
+	}
+}
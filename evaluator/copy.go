@@ -0,0 +1,71 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// deepCopier は deep-copy の途中で、コピー済みのリスト・ベクタ・ハッシュテーブルとそのコピーの対応を保持します。
+// 同じ構造を何度参照していてもコピーは1つだけ作り、循環した構造でも無限に再帰しません。
+type deepCopier struct {
+	lists  map[listIdentity]parser.List
+	others map[parser.Expr]parser.Expr
+}
+
+// copy は expr を再帰的にコピーします。数値・文字列・シンボルなどの変更できない値はそのまま共有します。
+func (c *deepCopier) copy(expr parser.Expr) parser.Expr {
+	switch v := expr.(type) {
+	case parser.List:
+		if len(v) == 0 {
+			return parser.List{}
+		}
+		id := identityOf(v)
+		if copied, ok := c.lists[id]; ok {
+			return copied
+		}
+		// 循環に備えて、要素をコピーする前に登録しておく
+		copied := make(parser.List, len(v))
+		c.lists[id] = copied
+		for i, elem := range v {
+			copied[i] = c.copy(elem)
+		}
+		return copied
+	case *Vector:
+		if copied, ok := c.others[v]; ok {
+			return copied
+		}
+		copied := &Vector{Elems: make([]parser.Expr, len(v.Elems))}
+		c.others[v] = copied
+		for i, elem := range v.Elems {
+			copied.Elems[i] = c.copy(elem)
+		}
+		return copied
+	case *HashTable:
+		if copied, ok := c.others[v]; ok {
+			return copied
+		}
+		copied := NewHashTable()
+		c.others[v] = copied
+		for _, entry := range v.entries {
+			copied.Set(c.copy(entry.key), c.copy(entry.value))
+		}
+		return copied
+	default:
+		return expr
+	}
+}
+
+// builtinDeepCopy は "deep-copy" を実装します。
+// (deep-copy obj) リスト・ベクタ・ハッシュテーブルを再帰的にコピーし、コピーへの変更が元の構造に影響しないようにします。
+// 構造の共有や循環はコピー後も保たれます。
+func builtinDeepCopy(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("deep-copy: wrong number of arguments")
+	}
+	c := &deepCopier{
+		lists:  make(map[listIdentity]parser.List),
+		others: make(map[parser.Expr]parser.Expr),
+	}
+	return c.copy(args[0]), nil
+}
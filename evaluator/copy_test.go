@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestDeepCopy は deep-copy したリストやハッシュテーブルを変更しても元の構造が変わらないことをテストします。
+func TestDeepCopy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define orig (list 1 (list 2 3) 4)) (define c (deep-copy orig)) (list-set! (list-ref c 1) 0 'x) (list orig c)", "((1 (2 3) 4) (1 (x 3) 4))"},
+		{"(define orig (make-hash-table)) (hash-table-set! orig 'k (list 1 2)) (define c (deep-copy orig)) (hash-table-set! c 'k 0) (list-set! (hash-table-ref orig 'k) 0 'y) (list (hash-table-ref orig 'k) (hash-table-ref c 'k))", "((y 2) 0)"},
+		{"(deep-copy (list 'a \"b\" (vector 1 (list 2)) '()))", `(a "b" #(1 (2)) ())`},
+		{"(deep-copy 42)", "42"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// 自分自身を要素に持つ循環したリストも、循環を保ったままコピーする
+	cyclic := parser.List{parser.Integer(1), nil}
+	cyclic[1] = cyclic
+	result, err := builtinDeepCopy([]parser.Expr{cyclic})
+	if err != nil {
+		t.Fatalf("deep-copy error: %v", err)
+	}
+	copied := result.(parser.List)
+	inner, ok := copied[1].(parser.List)
+	if !ok || &inner[0] != &copied[0] {
+		t.Errorf("expected the copy to refer to itself, got %#v", copied[1])
+	}
+	if &copied[0] == &cyclic[0] {
+		t.Errorf("expected a fresh list, got the original")
+	}
+}
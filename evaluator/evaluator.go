@@ -1,42 +1,132 @@
 package evaluator
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
 
 	"github.com/Warashi/lispish/parser"
 )
 
+// smallFrameSize は map を使わずに保持できる束縛の数です。
+// 関数呼び出しごとに作られる環境の多くは引数が少ないため、小さな配列で済ませてアロケーションを減らします。
+const smallFrameSize = 4
+
 // Env は変数とその値の束縛を保持する環境です。
 // outer があれば、ネストした環境（静的スコープ）を実現します。
 type Env struct {
-	vars  map[parser.Symbol]parser.Expr
-	outer *Env
+	// 束縛は最初の smallFrameSize 個までは names/values に、それ以降は vars に保持します。
+	names  [smallFrameSize]parser.Symbol
+	values [smallFrameSize]parser.Expr
+	n      int
+	vars   map[parser.Symbol]parser.Expr
+	outer  *Env
+	out    io.Writer
+	// handlers は with-exception-handler によって動的にインストールされた例外ハンドラのスタックです。
+	// defaultHandler とともにグローバル環境でのみ保持されます。
+	handlers       []Callable
+	defaultHandler Callable
+	// warnf はリント警告のコールバックで、warnSpan は EvalAt で評価中のトップレベルの式の範囲です（グローバル環境でのみ保持）。
+	warnf    WarnFunc
+	warnSpan parser.Span
+	// constants はこのフレームで define-constant によって束縛された変数の集合です。
+	constants map[parser.Symbol]bool
+	// maxArgs は SetMaxArgs で設定した引数の最大数です（0 なら DefaultMaxArgs）。内側の環境に引き継ぎます。
+	maxArgs int
+	// tracer は手続きの呼び出しを監視するフックで、最も外側の環境で作って内側の環境に引き継ぎます。
+	tracer *callTracer
 }
 
 // NewEnv は新しい環境を生成します。
 func NewEnv(outer *Env) *Env {
-	return &Env{
-		vars:  make(map[parser.Symbol]parser.Expr),
+	env := &Env{
 		outer: outer,
 	}
+	if outer != nil {
+		env.maxArgs = outer.maxArgs
+		env.tracer = outer.tracer
+	} else {
+		env.tracer = &callTracer{}
+	}
+	return env
+}
+
+// lookup はこの環境自身（外側を含まない）に束縛された値を探索します。
+func (env *Env) lookup(sym parser.Symbol) (parser.Expr, bool) {
+	for i := 0; i < env.n; i++ {
+		if env.names[i] == sym {
+			return env.values[i], true
+		}
+	}
+	if env.vars != nil {
+		val, ok := env.vars[sym]
+		return val, ok
+	}
+	return nil, false
 }
 
 // Get はシンボルに束縛された値を探索します。
 func (env *Env) Get(sym parser.Symbol) (parser.Expr, bool) {
-	if val, ok := env.vars[sym]; ok {
-		return val, true
-	}
-	if env.outer != nil {
-		return env.outer.Get(sym)
+	for e := env; e != nil; e = e.outer {
+		if val, ok := e.lookup(sym); ok {
+			return val, true
+		}
 	}
 	return nil, false
 }
 
 // Set はシンボルと値の束縛を設定します。
 func (env *Env) Set(sym parser.Symbol, val parser.Expr) {
+	for i := 0; i < env.n; i++ {
+		if env.names[i] == sym {
+			env.values[i] = val
+			return
+		}
+	}
+	if env.vars == nil {
+		if env.n < smallFrameSize {
+			env.names[env.n] = sym
+			env.values[env.n] = val
+			env.n++
+			return
+		}
+		env.vars = make(map[parser.Symbol]parser.Expr)
+	}
 	env.vars[sym] = val
 }
 
+// SetOutput は display や write の出力先を設定します。
+func (env *Env) SetOutput(w io.Writer) {
+	env.out = w
+}
+
+// Output は出力先を返します。設定されていなければ外側の環境を探索し、どこにもなければ標準出力を返します。
+func (env *Env) Output() io.Writer {
+	if env.out != nil {
+		return env.out
+	}
+	if env.outer != nil {
+		return env.outer.Output()
+	}
+	return os.Stdout
+}
+
+// UnspecifiedValue は意味のある値を返さない式（display や、どの節にも一致しない分岐など）の結果の型です。
+type UnspecifiedValue struct{}
+
+// Unspecified は未規定値を表す共有の値です。nil の代わりにこれを返すことで、
+// 「値がない」結果を一貫して扱えるようにします。表示すると何も出力されません。
+var Unspecified = UnspecifiedValue{}
+
+// isTrue は条件式の値が真であるかを判定します。#f 以外の値はすべて真です。
+func isTrue(val parser.Expr) bool {
+	return val != parser.Boolean(false)
+}
+
 // Callable インターフェースは、関数オブジェクトとして呼び出し可能なものが実装すべきメソッドを定義します。
 type Callable interface {
 	// Call は引数を受け取り、その評価結果を返します。
@@ -57,171 +147,591 @@ func (b *Builtin) Call(args []parser.Expr) (parser.Expr, error) {
 
 // Closure はユーザ定義の関数（lambda式）のクロージャを表します。
 type Closure struct {
-	params []parser.Symbol
+	// params は仮引数です。各要素はシンボルか、リストの引数を分解して束縛する
+	// ネストした仮引数のリスト（(lambda ((a b) c) ...) の (a b) など）です。
+	params []parser.Expr
 	body   parser.Expr
 	env    *Env
+	// name は (define (name ...) ...) で定義された場合の手続きの名前で、エラーメッセージに使います。
+	name parser.Symbol
+	// compiled は Compile 経由で生成された場合の、コンパイル済みの本体です。
+	compiled CompiledExpr
 }
 
 // Call により、クロージャ内の式を引数付きで評価します。
 func (c *Closure) Call(args []parser.Expr) (parser.Expr, error) {
-	if len(args) != len(c.params) {
-		return nil, fmt.Errorf("expected %d arguments, got %d", len(c.params), len(args))
+	newEnv, err := c.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	if c.compiled != nil {
+		result, err := c.compiled.Eval(newEnv)
+		if err != nil {
+			return nil, newEnv.signal(err)
+		}
+		return result, nil
+	}
+	return evalExpr(c.body, newEnv)
+}
+
+// bind はクロージャの捕捉した環境の内側に、仮引数を実引数に束縛した新しい環境を作ります。
+// 省略された #!optional の仮引数には、その省略時の値を評価して束縛します。
+func (c *Closure) bind(args []parser.Expr) (*Env, error) {
+	required, optional := c.splitParams()
+	if len(args) < len(required) || len(args) > len(required)+len(optional) {
+		return nil, c.arityError(len(args))
 	}
 	newEnv := NewEnv(c.env)
+	for i, param := range required {
+		if err := bindParam(newEnv, param, args[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i, param := range optional {
+		name, init, _ := optionalParam("lambda", param)
+		if len(required)+i < len(args) {
+			newEnv.Set(name, args[len(required)+i])
+			continue
+		}
+		val, err := evalExpr(init, newEnv)
+		if err != nil {
+			return nil, err
+		}
+		newEnv.Set(name, val)
+	}
+	return newEnv, nil
+}
+
+// splitParams は仮引数を、#!optional より前の必須のものと後の省略可能なものに分けます。
+func (c *Closure) splitParams() (required, optional []parser.Expr) {
 	for i, param := range c.params {
-		newEnv.Set(param, args[i])
+		if param == optionalMarker {
+			return c.params[:i], c.params[i+1:]
+		}
+	}
+	return c.params, nil
+}
+
+// arityError は実引数の数 got が仮引数の数と合わないときのエラーを、仮引数のリストとともに返します。
+// 名前のあるクロージャでは "square: expected 1 argument (x), got 2" のように名前を先頭に付けます。
+// 省略可能な仮引数があれば "expected 1 to 2 arguments" のように受け付ける範囲を示します。
+func (c *Closure) arityError(got int) error {
+	required, optional := c.splitParams()
+	var expected string
+	switch {
+	case len(optional) > 0:
+		expected = fmt.Sprintf("%d to %d arguments", len(required), len(required)+len(optional))
+	case len(required) == 1:
+		expected = "1 argument"
+	default:
+		expected = fmt.Sprintf("%d arguments", len(required))
+	}
+	msg := fmt.Sprintf("expected %s %s, got %d", expected, WriteString(parser.List(c.params)), got)
+	if c.name != "" {
+		return fmt.Errorf("%s: %s", c.name, msg)
+	}
+	return errors.New(msg)
+}
+
+// bindParam は仮引数 param に実引数 arg を束縛します。
+// param がリストの場合は、arg が同じ長さのリストであることを確認し、要素ごとに再帰的に束縛します。
+func bindParam(env *Env, param, arg parser.Expr) error {
+	pattern, ok := param.(parser.List)
+	if !ok {
+		env.Set(param.(parser.Symbol), arg)
+		return nil
 	}
-	return Eval(c.body, newEnv)
+	list, ok := arg.(parser.List)
+	if !ok || len(list) != len(pattern) {
+		return fmt.Errorf("expected a list of %d elements for parameter %s, got %s", len(pattern), WriteString(pattern), WriteString(arg))
+	}
+	for i, p := range pattern {
+		if err := bindParam(env, p, list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// specialForms は特殊フォームのキーワードの集合です。
+// これらは値として参照できないため、束縛がなければ「未定義」ではなく専用のエラーにします。
+var specialForms = map[parser.Symbol]bool{
+	"quote":           true,
+	"define":          true,
+	"lambda":          true,
+	"if":              true,
+	"catch":           true,
+	"match":           true,
+	"guard":           true,
+	"fluid-let":       true,
+	"for-range":       true,
+	"receive":         true,
+	"letrec":          true,
+	"letrec*":         true,
+	"let":             true,
+	"let*":            true,
+	"define-constant": true,
+	"begin":           true,
+	"trace-define":    true,
+	"typecase":        true,
+	"profile":         true,
+	"cond":            true,
+	"when":            true,
+	"unless":          true,
+	"case":            true,
+}
+
+// checkBindable は form（define や lambda）がシンボルを束縛できるかを確認します。
+// 特殊フォーム名を束縛すると特殊フォームが使えなくなるため、エラーにします。
+// 組み込み関数の名前（+ など）は通常の束縛なので再定義できます。
+func checkBindable(form string, sym parser.Symbol) error {
+	if specialForms[sym] {
+		return fmt.Errorf("%s: cannot bind special form name %s", form, sym)
+	}
+	return nil
+}
+
+// undefinedSymbolError は束縛のないシンボルを参照したときのエラーを返します。
+func undefinedSymbolError(sym parser.Symbol) error {
+	if specialForms[sym] {
+		return fmt.Errorf("%s: special form cannot be used as a value", sym)
+	}
+	return fmt.Errorf("undefined symbol: %s", sym)
+}
+
+// lookupSymbol は変数としてのシンボルの値を返します。
+// 束縛がない場合や、letrec の初期化がまだ済んでいない変数の場合はエラーにします。
+func lookupSymbol(sym parser.Symbol, env *Env) (parser.Expr, error) {
+	val, ok := env.Get(sym)
+	if !ok {
+		return nil, undefinedSymbolError(sym)
+	}
+	if u, ok := val.(unassignedValue); ok {
+		return nil, fmt.Errorf("%s: variable %s used before its definition", u.form, sym)
+	}
+	return val, nil
 }
 
 // Eval は AST（parser.Expr）を評価し、その結果を返します。
-func Eval(expr parser.Expr, env *Env) (parser.Expr, error) {
-	switch exp := expr.(type) {
-	// リテラルはそのまま返す
-	case parser.Integer, parser.Float, parser.String:
-		return exp, nil
-
-	// シンボルは環境から値を取得
-	case parser.Symbol:
-		val, ok := env.Get(exp)
-		if !ok {
-			return nil, fmt.Errorf("undefined symbol: %s", exp)
+// エラーが発生した場合、インストールされている例外ハンドラへ通知してから返します。
+// 評価する位置に循環するリストを含む式は、評価すると終わらないためエラーにします。
+// どの catch にも捕捉されなかった throw はエラーとして返します。
+func Eval(expr parser.Expr, env *Env) (result parser.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, uncaughtThrow(env, r)
 		}
-		return val, nil
+	}()
+	if err := checkAcyclic(expr); err != nil {
+		return nil, env.signal(err)
+	}
+	return evalExpr(expr, env)
+}
 
-	// リストは特殊フォームもしくは関数適用として評価する
-	case parser.List:
-		if len(exp) == 0 {
-			return nil, fmt.Errorf("cannot evaluate empty list")
+// evalExpr は式を評価し、エラーが発生した場合は例外ハンドラへ通知してから返します。
+// 評価の途中で部分式を評価する場合に使います。
+func evalExpr(expr parser.Expr, env *Env) (parser.Expr, error) {
+	result, err := eval(expr, env)
+	if err != nil {
+		return nil, env.signal(err)
+	}
+	return result, nil
+}
+
+// eval は evalExpr の本体です。
+// 末尾位置の式（クロージャの本体や、match などの本体の最後の式）は再帰せずにループで評価するため、
+// 末尾呼び出しによる繰り返しは Go のスタックを消費しません。apply による呼び出しも同様です。
+func eval(expr parser.Expr, env *Env) (result parser.Expr, err error) {
+	// frames は末尾呼び出しで置き換えたクロージャの呼び出しで、エラー時にバックトレースへ加えます
+	var frames tailFrames
+	defer func() {
+		if err != nil && len(frames.frames) > 0 {
+			err = frames.attach(err)
 		}
+	}()
+	for {
+		switch exp := expr.(type) {
+		// リテラル（#(...) のベクタを含む）はそのまま返す
+		case parser.Integer, parser.Rational, parser.Float, parser.String, parser.Boolean, parser.Char, *Vector:
+			return exp, nil
 
-		// 最初の要素がシンボルの場合、特殊フォームの可能性をチェック
-		if firstSym, ok := exp[0].(parser.Symbol); ok {
-			switch firstSym {
-			case "quote":
-				// (quote expr) → expr を評価せずに返す
-				if len(exp) != 2 {
-					return nil, fmt.Errorf("quote: wrong number of arguments")
-				}
-				return exp[1], nil
+		// シンボルは環境から値を取得
+		case parser.Symbol:
+			return lookupSymbol(exp, env)
 
-			case "define":
-				// (define var expr) または (define (fun arg...) body...)
-				if len(exp) < 3 {
-					return nil, fmt.Errorf("define: too few arguments")
-				}
-				// 関数定義の短縮形の場合
-				if list, ok := exp[1].(parser.List); ok {
-					if len(list) == 0 {
-						return nil, fmt.Errorf("define: invalid function definition")
+		// リストは特殊フォームもしくは関数適用として評価する
+		case parser.List:
+			if len(exp) == 0 {
+				return nil, fmt.Errorf("cannot evaluate empty list")
+			}
+
+			// 最初の要素がシンボルの場合、特殊フォームの可能性をチェック
+			if firstSym, ok := exp[0].(parser.Symbol); ok {
+				switch firstSym {
+				case "quote":
+					// (quote expr) → expr を評価せずに返す
+					if len(exp) != 2 {
+						return nil, fmt.Errorf("quote: wrong number of arguments")
 					}
-					funName, ok := list[0].(parser.Symbol)
-					if !ok {
-						return nil, fmt.Errorf("define: function name must be a symbol")
+					return exp[1], nil
+
+				case "define":
+					// (define var expr) または (define (fun arg...) body...)
+					if len(exp) < 3 {
+						return nil, fmt.Errorf("define: too few arguments")
 					}
-					var params []parser.Symbol
-					for _, param := range list[1:] {
-						s, ok := param.(parser.Symbol)
+					// 関数定義の短縮形の場合
+					if list, ok := exp[1].(parser.List); ok {
+						if len(list) == 0 {
+							return nil, fmt.Errorf("define: invalid function definition")
+						}
+						funName, ok := list[0].(parser.Symbol)
 						if !ok {
-							return nil, fmt.Errorf("define: function parameters must be symbols")
+							return nil, fmt.Errorf("define: function name must be a symbol")
 						}
-						params = append(params, s)
-					}
-					var body parser.Expr
-					if len(exp) == 3 {
-						body = exp[2]
+						if err := checkBindable("define", funName); err != nil {
+							return nil, err
+						}
+						params, err := lambdaParams("define", "define: function parameters must be symbols or lists", list[1:])
+						if err != nil {
+							return nil, err
+						}
+						body := lambdaBody(exp)
+						closure := &Closure{
+							params: params,
+							body:   body,
+							env:    env,
+							name:   funName,
+						}
+						if err := env.checkAssignable("define", funName); err != nil {
+							return nil, err
+						}
+						env.warnShadowing(funName, exp)
+						env.Set(funName, closure)
+						return funName, nil
 					} else {
-						body = parser.List(exp[2:])
+						// 変数定義の場合: (define var expr)
+						varName, ok := exp[1].(parser.Symbol)
+						if !ok {
+							return nil, fmt.Errorf("define: first argument must be a symbol")
+						}
+						if err := checkBindable("define", varName); err != nil {
+							return nil, err
+						}
+						if err := env.checkAssignable("define", varName); err != nil {
+							return nil, err
+						}
+						value, err := evalExpr(exp[2], env)
+						if err != nil {
+							return nil, err
+						}
+						env.warnShadowing(varName, exp)
+						env.Set(varName, value)
+						return varName, nil
 					}
-					closure := &Closure{
+
+				case "lambda":
+					// (lambda (params...) body...) → クロージャを生成して返す
+					if len(exp) < 3 {
+						return nil, fmt.Errorf("lambda: too few arguments")
+					}
+					paramList, ok := exp[1].(parser.List)
+					if !ok {
+						return nil, fmt.Errorf("lambda: first argument must be a list of parameters")
+					}
+					params, err := lambdaParams("lambda", "lambda: parameters must be symbols or lists", paramList)
+					if err != nil {
+						return nil, err
+					}
+					body := lambdaBody(exp)
+					return &Closure{
 						params: params,
 						body:   body,
 						env:    env,
+					}, nil
+
+				case "define-constant":
+					return evalDefineConstant(exp, env)
+
+				case "trace-define":
+					return evalTraceDefine(exp, env)
+
+				case "profile":
+					return evalProfile(exp, env)
+
+				case "if":
+					// (if test then [else]) → test の値に応じて一方の分岐だけを末尾位置で評価する
+					if len(exp) != 3 && len(exp) != 4 {
+						return nil, fmt.Errorf("if: wrong number of arguments")
 					}
-					env.Set(funName, closure)
-					return funName, nil
-				} else {
-					// 変数定義の場合: (define var expr)
-					varName, ok := exp[1].(parser.Symbol)
-					if !ok {
-						return nil, fmt.Errorf("define: first argument must be a symbol")
+					test, err := evalExpr(exp[1], env)
+					if err != nil {
+						return nil, err
+					}
+					if isTrue(test) {
+						expr = exp[2]
+						continue
 					}
-					value, err := Eval(exp[2], env)
+					if len(exp) == 3 {
+						return Unspecified, nil
+					}
+					expr = exp[3]
+					continue
+
+				case "begin":
+					// (begin expr...) → 式を順に評価し、末尾位置の最後の式の値を返す
+					if len(exp) == 1 {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(exp[1:], env); err != nil {
+						return nil, err
+					}
+					continue
+
+				case "catch":
+					return evalCatch(exp, env)
+
+				case "match":
+					body, bindings, err := matchClause(exp, env)
 					if err != nil {
 						return nil, err
 					}
-					env.Set(varName, value)
-					return varName, nil
-				}
+					if len(body) == 0 {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(body, bindings); err != nil {
+						return nil, err
+					}
+					env = bindings
+					continue
+
+				case "cond":
+					body, value, err := condClause(exp, env)
+					if err != nil {
+						return nil, err
+					}
+					if value != nil {
+						return value, nil
+					}
+					if len(body) == 0 {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(body, env); err != nil {
+						return nil, err
+					}
+					continue
 
-			case "lambda":
-				// (lambda (params...) body...) → クロージャを生成して返す
-				if len(exp) < 3 {
-					return nil, fmt.Errorf("lambda: too few arguments")
+				case "when", "unless":
+					// (when test body...) は test が真のとき、(unless test body...) は偽のときだけ body を評価する
+					if len(exp) < 3 {
+						return nil, fmt.Errorf("%s: too few arguments", firstSym)
+					}
+					test, err := evalExpr(exp[1], env)
+					if err != nil {
+						return nil, err
+					}
+					if isTrue(test) != (firstSym == "when") {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(exp[2:], env); err != nil {
+						return nil, err
+					}
+					continue
+
+				case "case":
+					body, err := caseClause(exp, env)
+					if err != nil {
+						return nil, err
+					}
+					if len(body) == 0 {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(body, env); err != nil {
+						return nil, err
+					}
+					continue
+
+				case "typecase":
+					body, err := typecaseClause(exp, env)
+					if err != nil {
+						return nil, err
+					}
+					if len(body) == 0 {
+						return Unspecified, nil
+					}
+					if expr, err = evalBodyInit(body, env); err != nil {
+						return nil, err
+					}
+					continue
+
+				case "guard":
+					return evalGuard(exp, env)
+
+				case "fluid-let":
+					return evalFluidLet(exp, env)
+
+				case "for-range":
+					return evalForRange(exp, env)
+
+				case "receive":
+					body, bodyEnv, err := receiveBody(exp, env)
+					if err != nil {
+						return nil, err
+					}
+					if expr, err = evalBodyInit(body, bodyEnv); err != nil {
+						return nil, err
+					}
+					env = bodyEnv
+					continue
+
+				case "let", "let*":
+					body, bodyEnv, err := letBody(exp, env, firstSym == "let*")
+					if err != nil {
+						return nil, err
+					}
+					if expr, err = evalBodyInit(body, bodyEnv); err != nil {
+						return nil, err
+					}
+					env = bodyEnv
+					continue
+
+				case "letrec", "letrec*":
+					body, bodyEnv, err := letrecBody(exp, env, firstSym == "letrec*")
+					if err != nil {
+						return nil, err
+					}
+					if expr, err = evalBodyInit(body, bodyEnv); err != nil {
+						return nil, err
+					}
+					env = bodyEnv
+					continue
 				}
-				paramList, ok := exp[1].(parser.List)
-				if !ok {
-					return nil, fmt.Errorf("lambda: first argument must be a list of parameters")
+			}
+
+			// 関数適用の場合
+			op, err := evalExpr(exp[0], env)
+			if err != nil {
+				return nil, err
+			}
+
+			// 引数は評価する（スライスは一度に確保する）
+			if err := env.checkArgCount(exp[0], len(exp)-1); err != nil {
+				return nil, err
+			}
+			args := make([]parser.Expr, 0, len(exp)-1)
+			for _, arg := range exp[1:] {
+				evaluatedArg, err := evalExpr(arg, env)
+				if err != nil {
+					return nil, err
 				}
-				var params []parser.Symbol
-				for _, param := range paramList {
-					s, ok := param.(parser.Symbol)
-					if !ok {
-						return nil, fmt.Errorf("lambda: parameters must be symbols")
-					}
-					params = append(params, s)
+				args = append(args, evaluatedArg)
+			}
+
+			// op が Callable インターフェースを実装しているかチェック
+			callable, ok := op.(Callable)
+			if !ok {
+				return nil, notCallableError(exp[0], op)
+			}
+			// apply は展開した手続きと引数の呼び出しに置き換え、末尾呼び出しとして扱えるようにする
+			opForm := exp[0]
+			for callable == Callable(applyBuiltin) {
+				if callable, args, err = spreadApplyArgs(args, env.argLimit()); err != nil {
+					return nil, err
 				}
-				var body parser.Expr
-				if len(exp) == 3 {
-					body = exp[2]
-				} else {
-					body = parser.List(exp[2:])
+				opForm = exp[1]
+			}
+			env.tracer.called(callable)
+			// 評価器で作られたクロージャは、本体を次に評価する式としてループを続ける
+			if c, ok := callable.(*Closure); ok && c.compiled == nil {
+				newEnv, err := c.bind(args)
+				if err != nil {
+					return nil, withCallFrame(err, callable, exp)
 				}
-				return &Closure{
-					params: params,
-					body:   body,
-					env:    env,
-				}, nil
+				frames.push(newCallFrame(callable, opForm, exp))
+				expr, env = c.body, newEnv
+				continue
 			}
+			result, err := callable.Call(args)
+			if err != nil {
+				return nil, withCallFrame(err, callable, exp)
+			}
+			return result, nil
+
+		// コメントはそのまま返す（実行時には無視してもよい）
+		case parser.Comment:
+			return exp, nil
+
+		default:
+			return nil, fmt.Errorf("cannot evaluate expression: %v", expr)
 		}
+	}
+}
 
-		// 関数適用の場合
-		op, err := Eval(exp[0], env)
-		if err != nil {
+// notCallableError は演算子の位置の式 opForm を評価した値 op が手続きでないときのエラーを返します。
+// 演算子が ((match ...) 1 2) のように計算される式の場合でも、どの式が原因かわかるよう opForm を含めます。
+func notCallableError(opForm, op parser.Expr) error {
+	return fmt.Errorf("not a function: %s (operator %s)", WriteString(op), WriteString(opForm))
+}
+
+// evalBody は本体の式を順に評価し、最後の式の値を返します。
+func evalBody(body []parser.Expr, env *Env) (parser.Expr, error) {
+	var result parser.Expr = Unspecified
+	for _, expr := range body {
+		var err error
+		if result, err = evalExpr(expr, env); err != nil {
 			return nil, err
 		}
+	}
+	return result, nil
+}
 
-		// 引数は評価する
-		var args []parser.Expr
-		for _, arg := range exp[1:] {
-			evaluatedArg, err := Eval(arg, env)
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, evaluatedArg)
+// evalBodyInit は空でない本体の最後の式を除いて順に評価し、末尾位置にある最後の式を返します。
+func evalBodyInit(body []parser.Expr, env *Env) (parser.Expr, error) {
+	for _, expr := range body[:len(body)-1] {
+		if _, err := evalExpr(expr, env); err != nil {
+			return nil, err
 		}
+	}
+	return body[len(body)-1], nil
+}
 
-		// op が Callable インターフェースを実装しているかチェック
-		callable, ok := op.(Callable)
-		if !ok {
-			return nil, fmt.Errorf("not a function: %v", op)
+// evalClauses は cond 形式の節 (test expr...) の並びを上から順に試します。
+// test が真になった最初の節の式を順に評価し、その最後の値を返します（式がなければ test の値）。
+// test の位置の else はつねに真とみなします。どの節も選ばれなければ matched は false です。
+func evalClauses(name string, clauses []parser.Expr, env *Env) (result parser.Expr, matched bool, err error) {
+	for _, clause := range clauses {
+		c, ok := clause.(parser.List)
+		if !ok || len(c) == 0 {
+			return nil, false, fmt.Errorf("%s: clause must be a non-empty list, got %s", name, WriteString(clause))
 		}
-		return callable.Call(args)
-
-	// コメントはそのまま返す（実行時には無視してもよい）
-	case parser.Comment:
-		return exp, nil
-
-	default:
-		return nil, fmt.Errorf("cannot evaluate expression: %v", expr)
+		var test parser.Expr = parser.Boolean(true)
+		if c[0] != parser.Symbol("else") {
+			if test, err = evalExpr(c[0], env); err != nil {
+				return nil, false, err
+			}
+		}
+		if !isTrue(test) {
+			continue
+		}
+		if len(c) == 1 {
+			return test, true, nil
+		}
+		result, err = evalBody(c[1:], env)
+		return result, err == nil, err
 	}
+	return nil, false, nil
 }
 
 // EvalAll は複数の式を順次評価し、最後の評価結果を返します。
-func EvalAll(exprs []parser.Expr, env *Env) (parser.Expr, error) {
-	var result parser.Expr
-	var err error
+// コメント（parser.KeepComments で残したもの）は結果に影響しないよう読み飛ばし、
+// 式が1つもない場合（コメントだけの入力を含む）は Unspecified を返します。
+// どの catch にも捕捉されなかった throw はエラーとして返します。
+func EvalAll(exprs []parser.Expr, env *Env) (result parser.Expr, err error) {
+	result = Unspecified
 	for _, expr := range exprs {
+		if _, ok := expr.(parser.Comment); ok {
+			continue
+		}
 		result, err = Eval(expr, env)
 		if err != nil {
 			return nil, err
@@ -230,60 +740,232 @@ func EvalAll(exprs []parser.Expr, env *Env) (parser.Expr, error) {
 	return result, nil
 }
 
+// EvalEach は複数の式を順次評価し、各式の評価結果を順に返します。
+// エラーが発生した場合は、それまでの結果とエラーを返します。
+func EvalEach(exprs []parser.Expr, env *Env) (results []parser.Expr, err error) {
+	results = make([]parser.Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		var result parser.Expr
+		if result, err = Eval(expr, env); err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
 // --- 組み込み関数の実装例 ---
 
-// builtinAdd は "+" を実装します。
-// 整数・浮動小数点数に対して加算を行います。
-func builtinAdd(args []parser.Expr) (parser.Expr, error) {
-	if len(args) == 0 {
-		return parser.Integer(0), nil
+// numberKind は数値の種類です。値が大きいほど一般的な種類で、演算では大きい方にそろえます。
+type numberKind int
+
+const (
+	kindInteger numberKind = iota
+	kindRational
+	kindFloat
+)
+
+// kindOf は数値の種類を返します。数値でなければ name のエラーを返します。
+func kindOf(name string, num parser.Expr) (numberKind, error) {
+	switch num.(type) {
+	case parser.Integer:
+		return kindInteger, nil
+	case parser.Rational:
+		return kindRational, nil
+	case parser.Float:
+		return kindFloat, nil
+	}
+	return 0, fmt.Errorf("%s: invalid argument type %T", name, num)
+}
+
+// arithOp は二項演算を数値の種類ごとに定義したものです。
+type arithOp struct {
+	name string
+	// integer は Integer どうしの演算です。結果が Integer にならない場合（割り算）は Rational を返せます。
+	integer  func(a, b int64) parser.Expr
+	rational func(z, a, b *big.Rat) *big.Rat
+	float    func(a, b float64) float64
+}
+
+// apply は a と b を一般的な方の種類にそろえて演算します。
+// Integer と Rational なら Rational で、どちらかが Float なら Float で計算します。
+func (op arithOp) apply(a, b parser.Expr) (parser.Expr, error) {
+	ka, err := kindOf(op.name, a)
+	if err != nil {
+		return nil, err
+	}
+	kb, err := kindOf(op.name, b)
+	if err != nil {
+		return nil, err
 	}
-	isFloat := false
-	sumInt := int64(0)
-	sumFloat := 0.0
+	switch max(ka, kb) {
+	case kindInteger:
+		return op.integer(int64(a.(parser.Integer)), int64(b.(parser.Integer))), nil
+	case kindRational:
+		return parser.NewRational(op.rational(new(big.Rat), toRat(a), toRat(b))), nil
+	default:
+		return parser.Float(op.float(toFloat(a), toFloat(b))), nil
+	}
+}
+
+// fold は演算を init から args に順に適用した結果を返します。
+func (op arithOp) fold(init parser.Expr, args []parser.Expr) (parser.Expr, error) {
+	result := init
 	for _, arg := range args {
-		switch v := arg.(type) {
-		case parser.Integer:
-			sumInt += int64(v)
-			sumFloat += float64(v)
-		case parser.Float:
-			isFloat = true
-			sumFloat += float64(v)
-		default:
-			return nil, fmt.Errorf("+: invalid argument type %T", arg)
+		var err error
+		if result, err = op.apply(result, arg); err != nil {
+			return nil, err
 		}
 	}
-	if isFloat {
-		return parser.Float(sumFloat), nil
+	return result, nil
+}
+
+var (
+	addOp = arithOp{
+		name:     "+",
+		integer:  func(a, b int64) parser.Expr { return parser.Integer(a + b) },
+		rational: (*big.Rat).Add,
+		float:    func(a, b float64) float64 { return a + b },
+	}
+	mulOp = arithOp{
+		name:     "*",
+		integer:  func(a, b int64) parser.Expr { return parser.Integer(a * b) },
+		rational: (*big.Rat).Mul,
+		float:    func(a, b float64) float64 { return a * b },
+	}
+	subOp = arithOp{
+		name:     "-",
+		integer:  func(a, b int64) parser.Expr { return parser.Integer(a - b) },
+		rational: (*big.Rat).Sub,
+		float:    func(a, b float64) float64 { return a - b },
 	}
-	return parser.Integer(sumInt), nil
+	// divOp は割り切れない Integer どうしの割り算を Rational にします（0 での割り算は呼び出し側で検査します）。
+	divOp = arithOp{
+		name: "/",
+		integer: func(a, b int64) parser.Expr {
+			if a%b == 0 {
+				return parser.Integer(a / b)
+			}
+			return parser.NewRational(big.NewRat(a, b))
+		},
+		rational: (*big.Rat).Quo,
+		float:    func(a, b float64) float64 { return a / b },
+	}
+)
+
+// builtinAdd は "+" を実装します。
+// 整数・分数・浮動小数点数に対して加算を行います。
+func builtinAdd(args []parser.Expr) (parser.Expr, error) {
+	return addOp.fold(parser.Integer(0), args)
 }
 
 // builtinMul は "*" を実装します。
-// 引数が整数または浮動小数点数の場合に乗算を行います。
+// 引数が整数・分数・浮動小数点数の場合に乗算を行います。
 func builtinMul(args []parser.Expr) (parser.Expr, error) {
+	return mulOp.fold(parser.Integer(1), args)
+}
+
+// builtinSub は "-" を実装します。
+// 引数が1つなら符号を反転し、2つ以上なら最初の引数から残りを順に引きます。引数がなければエラーです。
+func builtinSub(args []parser.Expr) (parser.Expr, error) {
 	if len(args) == 0 {
-		return parser.Integer(1), nil
+		return nil, fmt.Errorf("-: wrong number of arguments")
 	}
-	isFloat := false
-	prodInt := int64(1)
-	prodFloat := 1.0
-	for _, arg := range args {
-		switch v := arg.(type) {
-		case parser.Integer:
-			prodInt *= int64(v)
-			prodFloat *= float64(v)
-		case parser.Float:
-			isFloat = true
-			prodFloat *= float64(v)
-		default:
-			return nil, fmt.Errorf("*: invalid argument type %T", arg)
+	if len(args) == 1 {
+		return subOp.apply(parser.Integer(0), args[0])
+	}
+	if _, err := kindOf("-", args[0]); err != nil {
+		return nil, err
+	}
+	return subOp.fold(args[0], args[1:])
+}
+
+// builtinDiv は "/" を実装します。
+// 引数が1つなら逆数を返し、2つ以上なら最初の引数を残りで順に割ります。引数がなければエラーです。
+// 整数どうしで割り切れる場合は整数を、割り切れなければ分数を返します。浮動小数点数を含む場合は浮動小数点数です。
+// 0 による除算は、正確数どうしならエラーに、浮動小数点数を含むなら IEEE 754 の無限大か NaN になります。
+func builtinDiv(args []parser.Expr) (parser.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("/: wrong number of arguments")
+	}
+	if len(args) == 1 {
+		args = []parser.Expr{parser.Integer(1), args[0]}
+	}
+	if _, err := kindOf("/", args[0]); err != nil {
+		return nil, err
+	}
+	result := args[0]
+	for _, arg := range args[1:] {
+		if _, err := kindOf("/", arg); err != nil {
+			return nil, err
+		}
+		// 正確数どうしの 0 による除算はエラー。浮動小数点数を含む場合は IEEE 754 に従い ±inf.0 か +nan.0 になる
+		if _, inexact := result.(parser.Float); arg == parser.Integer(0) && !inexact {
+			return nil, fmt.Errorf("/: division by zero")
+		}
+		var err error
+		if result, err = divOp.apply(result, arg); err != nil {
+			return nil, err
 		}
 	}
-	if isFloat {
-		return parser.Float(prodFloat), nil
+	return result, nil
+}
+
+// toFloat は数値を float64 に変換します。
+func toFloat(num parser.Expr) float64 {
+	switch v := num.(type) {
+	case parser.Integer:
+		return float64(v)
+	case parser.Rational:
+		return v.Float64()
+	case parser.Float:
+		return float64(v)
 	}
-	return parser.Integer(prodInt), nil
+	return 0
+}
+
+// toRat は正確な数（Integer か Rational）を *big.Rat に変換します。
+func toRat(num parser.Expr) *big.Rat {
+	switch v := num.(type) {
+	case parser.Integer:
+		return new(big.Rat).SetInt64(int64(v))
+	case parser.Rational:
+		return v.Rat()
+	}
+	return new(big.Rat)
+}
+
+// compareNumbers は数値 a と b を比較し、a < b なら負、a > b なら正、等しければ 0 を返します。
+// Integer と Rational は正確に比較します。一方だけが Float の場合も、有限の値なら Float を正確な分数に変換して比較するため、
+// 精度が失われることはありません。a と b はどちらも数値でなければなりません。NaN との比較は 0 を返します。
+func compareNumbers(a, b parser.Expr) int {
+	ka, _ := kindOf("", a)
+	kb, _ := kindOf("", b)
+	switch max(ka, kb) {
+	case kindInteger:
+		return cmp.Compare(a.(parser.Integer), b.(parser.Integer))
+	case kindRational:
+		return toRat(a).Cmp(toRat(b))
+	}
+	fa, fb := toFloat(a), toFloat(b)
+	if ka != kb && !math.IsInf(fa, 0) && !math.IsInf(fb, 0) && !math.IsNaN(fa) && !math.IsNaN(fb) {
+		return exactRat(a).Cmp(exactRat(b))
+	}
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// exactRat は有限の数値 num を正確な分数に変換します。Float は丸めずにその値をそのまま表す分数になります。
+func exactRat(num parser.Expr) *big.Rat {
+	if f, ok := num.(parser.Float); ok {
+		return new(big.Rat).SetFloat64(float64(f))
+	}
+	return toRat(num)
 }
 
 // NewGlobalEnv は、組み込み関数などが登録されたグローバル環境を生成して返します。
@@ -298,6 +980,28 @@ func NewGlobalEnv() *Env {
 		Name: "*",
 		Fn:   builtinMul,
 	})
-	// 必要に応じて他の組み込み関数（例: "-", "/" など）を追加可能です。
+	env.Set("-", &Builtin{
+		Name: "-",
+		Fn:   builtinSub,
+	})
+	env.Set("/", &Builtin{
+		Name: "/",
+		Fn:   builtinDiv,
+	})
+	registerHashTableBuiltins(env)
+	registerPrinterBuiltins(env)
+	registerStringBuiltins(env)
+	registerExceptionBuiltins(env)
+	registerNumberBuiltins(env)
+	registerListBuiltins(env)
+	registerVectorBuiltins(env)
+	registerHeapBuiltins(env)
+	registerCatchBuiltins(env)
+	registerValuesBuiltins(env)
+	registerTimeBuiltins(env)
+	registerPortBuiltins(env)
+	registerObjectBuiltins(env)
+	registerProcedureBuiltins(env)
+	env.Set("apply", applyBuiltin)
 	return env
 }
@@ -1,6 +1,10 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -158,3 +162,678 @@ func TestEvaluatorComments(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, result)
 	}
 }
+
+// TestEvaluatorCommentOnly はコメントだけの入力を評価すると、コメントを残した場合も含めて Unspecified になることをテストします。
+func TestEvaluatorCommentOnly(t *testing.T) {
+	for _, input := range []string{"; only a comment", "; only a comment\n", "; one\n; two", "   \n\t", ""} {
+		for _, keep := range []bool{false, true} {
+			exprs, err := parser.NewParser(strings.NewReader(input), parser.KeepComments(keep)).ParseAll()
+			if err != nil {
+				t.Fatalf("%q: ParseAll error: %v", input, err)
+			}
+			result, err := EvalAll(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%q (keep comments %v): unexpected error: %v", input, keep, err)
+				continue
+			}
+			if result != Unspecified {
+				t.Errorf("%q (keep comments %v): expected Unspecified, got %#v", input, keep, result)
+			}
+		}
+	}
+	// 末尾のコメントは最後の式の結果を上書きしない
+	exprs, err := parser.NewParser(strings.NewReader("(+ 1 2) ; trailing"), parser.KeepComments(true)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if result, err := EvalAll(exprs, NewGlobalEnv()); err != nil || result != parser.Integer(3) {
+		t.Errorf("expected 3, got %#v (err %v)", result, err)
+	}
+}
+
+// evalString は入力をパースし、与えられた環境で評価した最後の結果を返すテスト用ヘルパーです。
+func evalString(t *testing.T, env *Env, input string) (parser.Expr, error) {
+	t.Helper()
+	p := parser.NewParser(strings.NewReader(input))
+	exprs, err := p.ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	return EvalAll(exprs, env)
+}
+
+// evalToString は入力を評価し、結果を write 形式の文字列で返すテスト用ヘルパーです。
+func evalToString(t *testing.T, env *Env, input string) string {
+	t.Helper()
+	result, err := evalString(t, env, input)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", input, err)
+	}
+	return WriteString(result)
+}
+
+// evalBenchmarks は BenchmarkEval で評価するプログラムです。
+// setup は一度だけ評価され、expr が各イテレーションで評価されます。
+var evalBenchmarks = []struct {
+	name  string
+	setup string
+	expr  string
+}{
+	{
+		name: "arithmetic",
+		expr: "(+ (* 2 3) (* 4 5 6) (+ 1 2 3 4) (* 1.5 2))",
+	},
+	{
+		name: "closure-calls",
+		setup: `
+		(define (square x) (* x x))
+		(define (sum-of-squares a b c) (+ (square a) (square b) (square c)))
+		(define (twice f) (lambda (x) (f (f x))))
+		`,
+		expr: "((twice (lambda (n) (sum-of-squares n 1 2))) 3)",
+	},
+	{
+		name: "hash-table-update",
+		setup: `
+		(define counts (make-hash-table))
+		(define (inc n) (+ n 1))
+		`,
+		expr: "(hash-table-update! counts 'key inc 0)",
+	},
+}
+
+// BenchmarkEval は代表的なプログラムの評価速度とアロケーション数を測定します。
+func BenchmarkEval(b *testing.B) {
+	for _, bm := range evalBenchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			env := NewGlobalEnv()
+			setup, err := parser.NewParser(strings.NewReader(bm.setup)).ParseAll()
+			if err != nil {
+				b.Fatalf("ParseAll error: %v", err)
+			}
+			if _, err := EvalAll(setup, env); err != nil {
+				b.Fatalf("EvalAll error: %v", err)
+			}
+			expr, err := parser.NewParser(strings.NewReader(bm.expr)).ParseExpr()
+			if err != nil {
+				b.Fatalf("ParseExpr error: %v", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Eval(expr, env); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestEnvMatchesMap は小さなフレーム最適化を行った Env が、素朴な map による実装と
+// 同じ束縛の結果を返すことをテストします（smallFrameSize を超えて map に移る場合を含む）。
+func TestEnvMatchesMap(t *testing.T) {
+	outer := NewEnv(nil)
+	outer.Set("shadowed", parser.String("outer"))
+	env := NewEnv(outer)
+	naive := map[parser.Symbol]parser.Expr{}
+	for i := 0; i < 3*smallFrameSize; i++ {
+		// 既存の束縛の更新と新しい束縛の追加を交互に行う
+		sym := parser.Symbol(fmt.Sprintf("v%d", i%(2*smallFrameSize)))
+		env.Set(sym, parser.Integer(i))
+		naive[sym] = parser.Integer(i)
+	}
+	env.Set("shadowed", parser.String("inner"))
+	naive["shadowed"] = parser.String("inner")
+	for sym, want := range naive {
+		got, ok := env.Get(sym)
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%s): expected %v, got %v (found=%v)", sym, want, got, ok)
+		}
+	}
+	if _, ok := env.Get("missing"); ok {
+		t.Error("expected missing symbol to be unbound")
+	}
+	if got, _ := outer.Get("shadowed"); got != parser.String("outer") {
+		t.Errorf("outer binding was modified: %v", got)
+	}
+}
+
+// TestEvalProgramSuite は評価器の最適化の前後で結果が変わらないことを、代表的なプログラム群でテストします。
+func TestEvalProgramSuite(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(+ (* 2 3) (* 4 5 6) (+ 1 2 3 4) (* 1.5 2))", "139.0"},
+		{"(define (f a b c d e) (+ a b c d e)) (f 1 2 3 4 5)", "15"},
+		{"(define (f a b c d e f2) (* a b c d e f2)) (f 1 2 3 4 5 6)", "720"},
+		{"(define (make-adder n) (lambda (x) (+ x n))) ((make-adder 5) 10)", "15"},
+		{"(define (twice f) (lambda (x) (f (f x)))) ((twice (twice (lambda (x) (* x 2)))) 1)", "16"},
+		{"(define x 1) (define (shadow x) (+ x 100)) (+ (shadow 1) x)", "102"},
+		{"(define h (make-hash-table)) (hash-table-set! h '(a b) 1) (hash-table-update! h '(a b) (lambda (n) (+ n 1)))", "2"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestUnspecifiedResults は値を持たない結果や一致する分岐のない条件式の結果が nil ではなく共有の Unspecified になり、
+// 表示しても何も出力されないことをテストします。
+func TestUnspecifiedResults(t *testing.T) {
+	env := NewGlobalEnv()
+	env.SetOutput(io.Discard)
+	inputs := []string{
+		"",
+		`(display "x")`,
+		`(write "x")`,
+		"(newline)",
+		"(hash-table-set! (make-hash-table) 'k 1)",
+		// 一致する分岐がない場合は、どの形式でも同じ Unspecified になる
+		"(if #f 1)",
+		"(when #f 1)",
+		"(unless #t 1)",
+		"(cond (#f 1))",
+		"(case 2 ((1) 'one))",
+	}
+	for _, input := range inputs {
+		result, err := evalString(t, env, input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", input, err)
+		}
+		if result != Unspecified {
+			t.Errorf("%q: expected Unspecified, got %#v", input, result)
+		}
+		if s := WriteString(result); s != "" {
+			t.Errorf("%q: expected Unspecified to print as empty, got %q", input, s)
+		}
+	}
+}
+
+// TestSpecialFormAsValue は特殊フォームのキーワードを値として参照すると、
+// 未定義シンボルではなく専用のエラーになることをテストします（Compile 経由でも同じ）。
+func TestSpecialFormAsValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if", "if: special form cannot be used as a value"},
+		{"define", "define: special form cannot be used as a value"},
+		{"quote", "quote: special form cannot be used as a value"},
+		{"(equal? quote 1)", "quote: special form cannot be used as a value"},
+		{"undefined-name", "undefined symbol: undefined-name"},
+	}
+	for _, tt := range tests {
+		if _, err := evalString(t, NewGlobalEnv(), tt.input); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+		exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		if _, err := evalCompiled(exprs, NewGlobalEnv()); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: compiled: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestEvalEach は EvalEach が各式の結果を順に返し、エラーの場合はそれまでの結果を返すことをテストします。
+func TestEvalEach(t *testing.T) {
+	exprs, err := parser.NewParser(strings.NewReader(`(define x 2) (* x 3) "done"`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	results, err := EvalEach(exprs, NewGlobalEnv())
+	if err != nil {
+		t.Fatalf("EvalEach error: %v", err)
+	}
+	expected := []parser.Expr{parser.Symbol("x"), parser.Integer(6), parser.String("done")}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+
+	exprs, err = parser.NewParser(strings.NewReader(`1 undefined-name 3`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	results, err = EvalEach(exprs, NewGlobalEnv())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if !reflect.DeepEqual(results, []parser.Expr{parser.Integer(1)}) {
+		t.Errorf("expected partial results [1], got %v", results)
+	}
+}
+
+// TestReservedNamesCannotBeBound は define や lambda の仮引数で特殊フォーム名を束縛できないこと、
+// 組み込み関数名の再定義は引き続き許されることをテストします（Compile 経由でも同じ）。
+func TestReservedNamesCannotBeBound(t *testing.T) {
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define if 1)", "define: cannot bind special form name if"},
+		{"(define (quote x) x)", "define: cannot bind special form name quote"},
+		{"(define (f lambda) lambda)", "define: cannot bind special form name lambda"},
+		{"(lambda (x define) x)", "lambda: cannot bind special form name define"},
+	}
+	for _, tt := range errorTests {
+		if _, err := evalString(t, NewGlobalEnv(), tt.input); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+		expr, err := parser.NewParser(strings.NewReader(tt.input)).ParseExpr()
+		if err != nil {
+			t.Fatalf("ParseExpr error: %v", err)
+		}
+		if _, err := Compile(expr); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: compiled: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+	if got := evalToString(t, NewGlobalEnv(), "(define (+ a b) (* a b)) (+ 3 4)"); got != "12" {
+		t.Errorf("expected redefined + to return 12, got %s", got)
+	}
+}
+
+// TestArithmeticArity は + * - / の引数が0個・1個の場合の振る舞いをテストします。
+// + と * は単位元を返し、- と / は引数がなければエラー、1個なら符号反転・逆数になります。
+func TestArithmeticArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{"(+)", parser.Integer(0)},
+		{"(*)", parser.Integer(1)},
+		{"(+ 5)", parser.Integer(5)},
+		{"(* 5)", parser.Integer(5)},
+		{"(- 5)", parser.Integer(-5)},
+		{"(- 2.5)", parser.Float(-2.5)},
+		{"(/ 1)", parser.Integer(1)},
+		{"(/ 4)", parser.NewRational(big.NewRat(1, 4))},
+		{"(/ 0.5)", parser.Float(2)},
+		{"(- 10 3 2)", parser.Integer(5)},
+		{"(/ 12 2 3)", parser.Integer(2)},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.input, tt.expected, result)
+		}
+	}
+	for _, input := range []string{"(-)", "(/)", "(/ 0)", "(/ 1 0)", "(- 'a)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestSubtraction は - が左から順に引き、Float を含む場合は Float に昇格することをテストします。
+func TestSubtraction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{"(- 1 2 3)", parser.Integer(-4)},
+		{"(- 10 3)", parser.Integer(7)},
+		{"(- 10 2.5)", parser.Float(7.5)},
+		{"(- 5.5 2)", parser.Float(3.5)},
+		{"(- 10 1 0.5)", parser.Float(8.5)},
+		{"(- 1 1.0)", parser.Float(0)},
+		{"(- 1/2 1)", parser.NewRational(big.NewRat(-1, 2))},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.input, tt.expected, result)
+		}
+	}
+	if _, err := evalString(t, NewGlobalEnv(), "(- 1 \"2\")"); err == nil {
+		t.Errorf("expected error for a non-numeric argument, got nil")
+	}
+}
+
+// TestDivision は / が割り切れる整数どうしでは Integer を、割り切れなければ分数を返し、
+// Float を含む場合は Float に昇格すること、0 による除算がエラーになることをテストします。
+func TestDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{"(/ 6 3)", parser.Integer(2)},
+		// 依頼では割り切れない整数の除算を Float (3.5) に、(/ 5) を 0.2 にするとしていたが、
+		// 先に入った有理数の対応で / は正確な有理数を返すようになっているため、その振る舞いを保つ
+		{"(/ 7 2)", parser.NewRational(big.NewRat(7, 2))},
+		{"(/ 7.0 2)", parser.Float(3.5)},
+		{"(/ 7 2.0)", parser.Float(3.5)},
+		{"(/ 5)", parser.NewRational(big.NewRat(1, 5))},
+		{"(/ 5.0)", parser.Float(0.2)},
+		{"(/ 1/2 1/4)", parser.Integer(2)},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.input, tt.expected, result)
+		}
+	}
+	// 浮動小数点数を含む 0 による除算は IEEE 754 に従う
+	inexact := []struct {
+		input    string
+		expected string
+	}{
+		{"(/ 1.0 0)", "+inf.0"},
+		{"(/ 1 0.0)", "+inf.0"},
+		{"(/ -1 0.0)", "-inf.0"},
+		{"(/ 1 -0.0)", "-inf.0"},
+		{"(/ 0.0 0.0)", "+nan.0"},
+		{"(/ 0 0.0)", "+nan.0"},
+		{"(/ 0.0)", "+inf.0"},
+		{"(/ 6 2.0 0)", "+inf.0"},
+	}
+	for _, tt := range inexact {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(/ 1 0)", "(/ 1/2 0)", "(/ 0)", "(/ 6 2 0)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil || err.Error() != "/: division by zero" {
+			t.Errorf("%s: expected division by zero error, got %v", input, err)
+		}
+	}
+}
+
+// TestIf は if が条件の値に応じて一方の分岐だけを評価し、#f 以外の値をすべて真とみなすことをテストします。
+func TestIf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(if #t 1 2)", "1"},
+		{"(if #f 1 2)", "2"},
+		{"(if 0 'yes 'no)", "yes"},
+		{"(if '() 'yes 'no)", "yes"},
+		{`(if "" 'yes 'no)`, "yes"},
+		{"(if (< 1 2) (+ 1 2) (* 2 3))", "3"},
+		// else がなく条件が偽なら未規定値
+		{"(if #f 1)", ""},
+		{"(if #t 1)", "1"},
+		// 選ばれなかった分岐は評価しない
+		{"(define x 'unchanged) (if #t 'then (define x 'else)) x", "unchanged"},
+		{"(define x 'unchanged) (if #f (define x 'then) 'else) x", "unchanged"},
+		{"(if #f (undefined-function) 'safe)", "safe"},
+		{"(define (fact n) (if (= n 0) 1 (* n (fact (- n 1))))) (fact 10)", "3628800"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+		exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		got, err := evalCompiled(exprs, NewGlobalEnv())
+		if err != nil {
+			t.Errorf("%s: compiled: unexpected error: %v", tt.input, err)
+		} else if WriteString(got) != tt.expected {
+			t.Errorf("%s: compiled: expected %s, got %s", tt.input, tt.expected, WriteString(got))
+		}
+	}
+	for _, input := range []string{"(if)", "(if #t)", "(if #t 1 2 3)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil || err.Error() != "if: wrong number of arguments" {
+			t.Errorf("%s: expected wrong number of arguments error, got %v", input, err)
+		}
+	}
+}
+
+// TestComputedOperator は演算子の位置にある式が評価されて手続きになる場合に適用できること、
+// 手続きにならない場合は演算子の式を含むエラーになることをテストします。
+// Eval とコンパイル済みの式の両方で確認します。
+func TestComputedOperator(t *testing.T) {
+	setup := "(define (make-adder n) (lambda (x) (+ x n))) (define flag #t)"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 演算子の位置の式は match のような特殊フォームでもよい
+		{"((match flag (#t +) (_ *)) 3 4)", "7"},
+		{"((match #f (#t +) (_ *)) 3 4)", "12"},
+		{"((lambda (x y) (* x y)) 3 4)", "12"},
+		{"((make-adder 10) 5)", "15"},
+		{"(((lambda () make-adder)) 1)", "#<closure (x)>"},
+		{"((catch 'op (throw 'op +)) 1 2)", "3"},
+	}
+	for _, tt := range tests {
+		exprs, err := parser.NewParser(strings.NewReader(setup + tt.input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+			result, err := run(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", tt.input, name, err)
+				continue
+			}
+			if got := WriteString(result); got != tt.expected {
+				t.Errorf("%s (%s): expected %s, got %s", tt.input, name, tt.expected, got)
+			}
+		}
+	}
+
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"((match 1 (_ 42)) 1 2)", "not a function: 42 (operator (match 1 (_ 42)))"},
+		{`(((lambda () "str")))`, `not a function: "str" (operator ((lambda () "str")))`},
+		{"(define x 5) (x 1)", "not a function: 5 (operator x)"},
+	}
+	for _, tt := range errTests {
+		exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+			_, err := run(exprs, NewGlobalEnv())
+			if err == nil {
+				t.Errorf("%s (%s): expected error, got nil", tt.input, name)
+				continue
+			}
+			if err.Error() != tt.expected {
+				t.Errorf("%s (%s): expected error %q, got %q", tt.input, name, tt.expected, err.Error())
+			}
+		}
+	}
+}
+
+// TestThunkBody は引数のないクロージャ（thunk）の複数の式からなる本体が、begin と同様に順に評価され、
+// 最後の式の値を返すことをテストします。
+func TestThunkBody(t *testing.T) {
+	tests := []struct {
+		input    string
+		output   string
+		expected string
+	}{
+		{"(define (f) (display 1) 42) (f)", "1", "42"},
+		{"((lambda () (display \"a\") (display \"b\") 'done))", "ab", "done"},
+		{"(define (g) (define x 5) (* x 2)) (g)", "", "10"},
+		{"(define (h) 7) (h)", "", "7"},
+		{"(begin (display 1) (display 2) 3)", "12", "3"},
+		{"(begin)", "", ""},
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, tt := range tests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			var out bytes.Buffer
+			env := NewGlobalEnv()
+			env.SetOutput(&out)
+			result, err := run(exprs, env)
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", tt.input, name, err)
+				continue
+			}
+			if got := WriteString(result); got != tt.expected {
+				t.Errorf("%s (%s): expected %s, got %s", tt.input, name, tt.expected, got)
+			}
+			if out.String() != tt.output {
+				t.Errorf("%s (%s): expected output %q, got %q", tt.input, name, tt.output, out.String())
+			}
+		}
+	}
+}
+
+// TestClosureArityError は実引数の数が合わないときのエラーが、仮引数のリストと、
+// define で定義された手続きであればその名前を含むことをテストします。
+func TestClosureArityError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define (square x) (* x x)) (square 1 2)", "square: expected 1 argument (x), got 2"},
+		{"(define (add a b) (+ a b)) (add 1)", "add: expected 2 arguments (a b), got 1"},
+		{"(define (zero) 0) (zero 1)", "zero: expected 0 arguments (), got 1"},
+		{"((lambda (x y) x) 1)", "expected 2 arguments (x y), got 1"},
+		// lambda を変数に define しても名前は付かない
+		{"(define f (lambda (x) x)) (f)", "expected 1 argument (x), got 0"},
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, tt := range tests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			_, err = run(exprs, NewGlobalEnv())
+			if err == nil || innermostError(err).Error() != tt.expected {
+				t.Errorf("%s (%s): expected error %q, got %v", tt.input, name, tt.expected, err)
+			}
+		}
+	}
+}
+
+// TestOptionalParams は #!optional より後の仮引数を省略でき、省略時には既定値（なければ #f）が束縛されることをテストします。
+func TestOptionalParams(t *testing.T) {
+	defs := `
+	(define (greet name #!optional (greeting "hello")) (list greeting name))
+	(define (pair a #!optional b) (list a b))
+	(define (scale x #!optional (factor (* x 2))) (list x factor))
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(greet "bob")`, `("hello" "bob")`},
+		{`(greet "bob" "hi")`, `("hi" "bob")`},
+		{"(pair 1)", "(1 #f)"},
+		{"(pair 1 2)", "(1 2)"},
+		// 既定値は前の仮引数を参照できる
+		{"(scale 3)", "(3 6)"},
+		{"(scale 3 10)", "(3 10)"},
+		{"((lambda (#!optional a) a))", "#f"},
+		{"(lambda (a #!optional (b 1)) a)", "#<closure (a #!optional (b 1))>"},
+	}
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(greet)", `greet: expected 1 to 2 arguments (name #!optional (greeting "hello")), got 0`},
+		{"(pair 1 2 3)", "pair: expected 1 to 2 arguments (a #!optional b), got 3"},
+		{"(lambda (a #!optional (b)) a)", "lambda: optional parameter must be name or (name default), got (b)"},
+		{"(define (f #!optional 1) 1)", "define: optional parameter must be name or (name default), got 1"},
+		{"(lambda (#!optional a #!optional b) a)", "lambda: duplicate #!optional"},
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, tt := range tests {
+			exprs, err := parser.NewParser(strings.NewReader(defs + tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			result, err := run(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", tt.input, name, err)
+				continue
+			}
+			if got := WriteString(result); got != tt.expected {
+				t.Errorf("%s (%s): expected %s, got %s", tt.input, name, tt.expected, got)
+			}
+		}
+		for _, tt := range errTests {
+			exprs, err := parser.NewParser(strings.NewReader(defs + tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			_, err = run(exprs, NewGlobalEnv())
+			if err == nil || innermostError(err).Error() != tt.expected {
+				t.Errorf("%s (%s): expected error %q, got %v", tt.input, name, tt.expected, err)
+			}
+		}
+	}
+}
+
+// TestDestructuringParams は仮引数をリストにすると、リストの引数を分解して束縛することをテストします。
+// 引数の形が合わない場合は、呼び出し時にどの仮引数で失敗したかを示すエラーになります。
+func TestDestructuringParams(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((lambda ((a b) c) (list a b c)) '(1 2) 3)", "(1 2 3)"},
+		{"((lambda (x ((y z) w)) (list x y z w)) 1 '((2 3) 4))", "(1 2 3 4)"},
+		{"((lambda (() x) x) '() 5)", "5"},
+		{"(define (dist (x1 y1) (x2 y2)) (+ (* (- x2 x1) (- x2 x1)) (* (- y2 y1) (- y2 y1)))) (dist '(0 0) '(3 4))", "25"},
+		{"(lambda ((a b) c) a)", "#<closure ((a b) c)>"},
+	}
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"((lambda ((a b) c) a) '(1 2 3) 4)", "expected a list of 2 elements for parameter (a b), got (1 2 3)"},
+		{"((lambda ((a b) c) a) 1 2)", "expected a list of 2 elements for parameter (a b), got 1"},
+		{"((lambda (x ((y z) w)) y) 1 '(2 3))", "expected a list of 2 elements for parameter (y z), got 2"},
+		{"((lambda ((a b) c) a) '(1 2))", "expected 2 arguments ((a b) c), got 1"},
+		{"(lambda ((a 1)) a)", "lambda: parameters must be symbols or lists"},
+		{"(lambda ((a define)) a)", "lambda: cannot bind special form name define"},
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, tt := range tests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			result, err := run(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", tt.input, name, err)
+				continue
+			}
+			if got := WriteString(result); got != tt.expected {
+				t.Errorf("%s (%s): expected %s, got %s", tt.input, name, tt.expected, got)
+			}
+		}
+		for _, tt := range errTests {
+			exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+			if err != nil {
+				t.Fatalf("ParseAll error: %v", err)
+			}
+			_, err = run(exprs, NewGlobalEnv())
+			if err == nil {
+				t.Errorf("%s (%s): expected error, got nil", tt.input, name)
+				continue
+			}
+			if got := innermostError(err).Error(); got != tt.expected {
+				t.Errorf("%s (%s): expected error %q, got %q", tt.input, name, tt.expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// ErrorObject は評価中に発生したエラーを Lisp 側から扱うためのオブジェクト（condition）です。
+type ErrorObject struct {
+	Message   string
+	Irritants []parser.Expr
+}
+
+// signaledError は例外ハンドラへ通知済みのエラーです。
+// 外側の Eval で同じエラーが二重に通知されないよう、元のエラーを包んで伝播させます。
+type signaledError struct {
+	err error
+}
+
+// Error は元のエラーメッセージを返します。
+func (e *signaledError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap は元のエラーを返します。
+func (e *signaledError) Unwrap() error {
+	return e.err
+}
+
+// raisedError は error や raise によって Lisp 側から送出された condition を運ぶエラーです。
+type raisedError struct {
+	condition parser.Expr
+}
+
+// Error は condition のメッセージを返します。エラーオブジェクト以外が raise された場合は、その外部表現を含めます。
+func (e *raisedError) Error() string {
+	obj, ok := e.condition.(*ErrorObject)
+	if !ok {
+		return "raise: " + WriteString(e.condition)
+	}
+	msg := obj.Message
+	for _, irritant := range obj.Irritants {
+		msg += " " + WriteString(irritant)
+	}
+	return msg
+}
+
+// conditionOf は Go のエラーから例外ハンドラに渡す condition を生成します。
+// raise されたエラーであれば、raise された値そのものを返します。
+// メッセージにはバックトレースを含めません。
+func conditionOf(err error) parser.Expr {
+	err = innermostError(err)
+	var raised *raisedError
+	if errors.As(err, &raised) {
+		return raised.condition
+	}
+	return &ErrorObject{Message: err.Error()}
+}
+
+// root は環境の連鎖の最も外側（グローバル環境）を返します。
+func (env *Env) root() *Env {
+	for env.outer != nil {
+		env = env.outer
+	}
+	return env
+}
+
+// SetExceptionHandler は環境全体の既定の例外ハンドラを設定します。
+// ハンドラは捕捉されるかどうかに関わらず、評価中に発生したすべてのエラーの condition を
+// 引数として呼び出されます（ログ記録などの用途）。エラーはその後も通常どおり伝播します。
+func (env *Env) SetExceptionHandler(handler Callable) {
+	env.root().defaultHandler = handler
+}
+
+// signal は未通知のエラーをインストールされている例外ハンドラへ内側から順に通知します。
+// R7RS と同じく、各ハンドラはそれより外側のハンドラだけがインストールされた状態で呼び出すため、
+// ハンドラの中で起きたエラーはそのハンドラ自身ではなく外側のハンドラに通知されます。
+// 既定の例外ハンドラは最も外側のハンドラとして扱い、それ自身の実行中は無効にします。
+func (env *Env) signal(err error) error {
+	var signaled *signaledError
+	if errors.As(err, &signaled) {
+		return err
+	}
+	root := env.root()
+	handlers, defaultHandler := root.handlers, root.defaultHandler
+	if len(handlers) == 0 && defaultHandler == nil {
+		return &signaledError{err: err}
+	}
+	defer func() {
+		root.handlers, root.defaultHandler = handlers, defaultHandler
+	}()
+	condition := conditionOf(err)
+	for i := len(handlers) - 1; i >= 0; i-- {
+		// ハンドラの中でインストールされたハンドラが handlers[i] を上書きしないよう、容量も切り詰める
+		root.handlers = handlers[:i:i]
+		if _, herr := handlers[i].Call([]parser.Expr{condition}); herr != nil {
+			return &signaledError{err: herr}
+		}
+	}
+	if defaultHandler != nil {
+		root.handlers, root.defaultHandler = nil, nil
+		if _, herr := defaultHandler.Call([]parser.Expr{condition}); herr != nil {
+			return &signaledError{err: herr}
+		}
+	}
+	return &signaledError{err: err}
+}
+
+// registerExceptionBuiltins は例外処理関連の組み込み関数を環境に登録します。
+func registerExceptionBuiltins(env *Env) {
+	env.Set("with-exception-handler", &Builtin{
+		Name: "with-exception-handler",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			// (with-exception-handler handler thunk)
+			if len(args) != 2 {
+				return nil, fmt.Errorf("with-exception-handler: wrong number of arguments")
+			}
+			handler, ok := args[0].(Callable)
+			if !ok {
+				return nil, fmt.Errorf("with-exception-handler: handler must be a procedure")
+			}
+			thunk, ok := args[1].(Callable)
+			if !ok {
+				return nil, fmt.Errorf("with-exception-handler: thunk must be a procedure")
+			}
+			root := env.root()
+			root.handlers = append(root.handlers, handler)
+			defer func() {
+				root.handlers = root.handlers[:len(root.handlers)-1]
+			}()
+			return thunk.Call(nil)
+		},
+	})
+	env.Set("error-object-message", makeErrorMessage("error-object-message"))
+	env.Set("error-message", makeErrorMessage("error-message"))
+	env.Set("error", &Builtin{Name: "error", Fn: builtinError})
+	env.Set("raise", &Builtin{Name: "raise", Fn: builtinRaise})
+	env.Set("error?", &Builtin{Name: "error?", Fn: builtinIsError})
+}
+
+// makeErrorMessage はエラーオブジェクトのメッセージを返す組み込み関数を生成します。
+func makeErrorMessage(name string) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			obj, ok := args[0].(*ErrorObject)
+			if !ok {
+				return nil, fmt.Errorf("%s: argument must be an error object", name)
+			}
+			return parser.String(obj.Message), nil
+		},
+	}
+}
+
+// builtinError は "error" を実装します。
+// (error message irritant...) メッセージと付加情報を持つエラーオブジェクトを送出します。
+func builtinError(args []parser.Expr) (parser.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("error: wrong number of arguments")
+	}
+	msg, err := stringArg("error", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	irritants := append([]parser.Expr(nil), args[1:]...)
+	return nil, &raisedError{condition: &ErrorObject{Message: msg, Irritants: irritants}}
+}
+
+// builtinRaise は "raise" を実装します。
+// (raise obj) 任意の値を condition として送出します。
+func builtinRaise(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("raise: wrong number of arguments")
+	}
+	return nil, &raisedError{condition: args[0]}
+}
+
+// builtinIsError は "error?" を実装します。
+func builtinIsError(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("error?: wrong number of arguments")
+	}
+	_, ok := args[0].(*ErrorObject)
+	return parser.Boolean(ok), nil
+}
+
+// evalGuard は (guard (var clause...) body...) を評価します。
+// body の評価中にエラーが発生すると、var を condition に束縛して clause を cond と同じ規則で試します。
+// どの clause にも一致しなければ、元のエラーをそのまま送出し直します。
+func evalGuard(exp parser.List, env *Env) (parser.Expr, error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("guard: too few arguments")
+	}
+	spec, ok := exp[1].(parser.List)
+	if !ok || len(spec) == 0 {
+		return nil, fmt.Errorf("guard: first argument must be (var clause...)")
+	}
+	name, ok := spec[0].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("guard: variable must be a symbol")
+	}
+	result, err := evalBody(exp[2:], env)
+	if err == nil {
+		return result, nil
+	}
+	guardEnv := NewEnv(env)
+	guardEnv.Set(name, conditionOf(err))
+	result, matched, cerr := evalClauses("guard", spec[1:], guardEnv)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if !matched {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestExceptionHandlerObservesDeepError は既定の例外ハンドラが、深い呼び出しの中で発生し
+// トップレベルの guard に捕捉されたエラーを一度だけ観測することをテストします。コンパイルした式でも同じです。
+func TestExceptionHandlerObservesDeepError(t *testing.T) {
+	input := `
+	(define (inner x) (+ x "oops"))
+	(define (middle x) (inner (* x 2)))
+	(define (outer x) (middle (+ x 1)))
+	(guard (e (#t (error-object-message e))) (outer 1))
+	`
+	exprs, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		env := NewGlobalEnv()
+		var seen []*ErrorObject
+		env.SetExceptionHandler(&Builtin{
+			Name: "record",
+			Fn: func(args []parser.Expr) (parser.Expr, error) {
+				seen = append(seen, args[0].(*ErrorObject))
+				return nil, nil
+			},
+		})
+		caught, err := run(exprs, env)
+		if err != nil {
+			t.Fatalf("%s: error: %v", name, err)
+		}
+		msg, ok := caught.(parser.String)
+		if !ok || !strings.Contains(string(msg), "+: invalid argument type") {
+			t.Fatalf("%s: expected the guard to catch the + error, got %s", name, WriteString(caught))
+		}
+		if len(seen) != 1 {
+			t.Fatalf("%s: expected handler to be called once, got %d", name, len(seen))
+		}
+		// ハンドラにはバックトレースを除いた発生源のメッセージが渡され、guard が捕捉するものと同じ
+		if seen[0].Message != string(msg) {
+			t.Errorf("%s: expected handler to see %q, got %q", name, msg, seen[0].Message)
+		}
+	}
+}
+
+// TestExceptionHandlerObservesCompiledError はコンパイルした式の中で発生し、捕捉されなかったエラーも
+// 既定の例外ハンドラが一度だけ観測することをテストします。
+func TestExceptionHandlerObservesCompiledError(t *testing.T) {
+	inputs := []string{
+		`(begin (define (g x) (error "boom" x)) (g 1))`,
+		`(begin (define (h) undefined-var) (h))`,
+		`undefined-var`,
+	}
+	for _, input := range inputs {
+		expr, err := parser.NewParser(strings.NewReader(input)).ParseExpr()
+		if err != nil {
+			t.Fatalf("ParseExpr error: %v", err)
+		}
+		for name, run := range map[string]func(*Env) (parser.Expr, error){
+			"eval": func(env *Env) (parser.Expr, error) { return Eval(expr, env) },
+			"compiled": func(env *Env) (parser.Expr, error) {
+				compiled, err := Compile(expr)
+				if err != nil {
+					t.Fatalf("Compile error: %v", err)
+				}
+				return compiled.Eval(env)
+			},
+		} {
+			env := NewGlobalEnv()
+			var seen []string
+			env.SetExceptionHandler(&Builtin{
+				Name: "record",
+				Fn: func(args []parser.Expr) (parser.Expr, error) {
+					seen = append(seen, args[0].(*ErrorObject).Message)
+					return nil, nil
+				},
+			})
+			if _, err := run(env); err == nil {
+				t.Fatalf("%s (%s): expected error, got nil", input, name)
+			}
+			if len(seen) != 1 {
+				t.Errorf("%s (%s): expected handler to be called once, got %v", input, name, seen)
+			}
+		}
+	}
+}
+
+// TestExceptionInHandler はハンドラの中で起きたエラーがそのハンドラ自身に再び通知されず、
+// 外側のハンドラや guard に伝わることをテストします。
+func TestExceptionInHandler(t *testing.T) {
+	env := NewGlobalEnv()
+	var seen []string
+	env.SetExceptionHandler(&Builtin{
+		Name: "record",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			seen = append(seen, WriteString(args[0]))
+			return nil, nil
+		},
+	})
+	_, err := evalString(t, env, `
+	(with-exception-handler
+	  (lambda (e) (undefined-in-handler))
+	  (lambda () (undefined-fn 1)))`)
+	if err == nil || !strings.Contains(err.Error(), "undefined symbol: undefined-in-handler") {
+		t.Errorf("expected the handler's error, got %v", err)
+	}
+	// 既定のハンドラはハンドラの中で起きたエラーだけを観測する
+	expected := []string{`#<error "undefined symbol: undefined-in-handler">`}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected default handler to see %v, got %v", expected, seen)
+	}
+	if n := len(env.root().handlers); n != 0 || env.root().defaultHandler == nil {
+		t.Errorf("expected handlers to be restored, got %d handlers", n)
+	}
+
+	got := evalToString(t, NewGlobalEnv(), `
+	(guard (e (else e))
+	  (with-exception-handler
+	    (lambda (e) (raise 'inner))
+	    (lambda () (raise 'outer))))`)
+	if got != "inner" {
+		t.Errorf("expected inner, got %s", got)
+	}
+
+	// 外側のハンドラは内側のハンドラで起きたエラーを受け取る
+	env = NewGlobalEnv()
+	got = evalToString(t, env, `
+	(define log (make-hash-table))
+	(guard (e (else e))
+	  (with-exception-handler
+	    (lambda (e) (hash-table-set! log 'outer e))
+	    (lambda ()
+	      (with-exception-handler
+	        (lambda (e) (raise (list 'wrapped e)))
+	        (lambda () (raise 'original))))))`)
+	if got != "(wrapped original)" {
+		t.Errorf("expected (wrapped original), got %s", got)
+	}
+	if got := evalToString(t, env, "(hash-table-ref log 'outer)"); got != "(wrapped original)" {
+		t.Errorf("expected the outer handler to see (wrapped original), got %s", got)
+	}
+}
+
+// TestWithExceptionHandler は with-exception-handler でインストールしたハンドラが
+// condition を受け取り、その後エラーが伝播することをテストします。
+func TestWithExceptionHandler(t *testing.T) {
+	env := NewGlobalEnv()
+	input := `
+	(define log (make-hash-table))
+	(with-exception-handler
+	  (lambda (e) (hash-table-set! log 'message (error-object-message e)))
+	  (lambda () (undefined-function 1)))
+	`
+	if _, err := evalString(t, env, input); err == nil {
+		t.Fatal("expected error to propagate, got nil")
+	}
+	result, err := evalString(t, env, "(hash-table-ref log 'message)")
+	if err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	expected := parser.String("undefined symbol: undefined-function")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	// ハンドラはサンクの実行後に取り除かれる
+	if n := len(env.root().handlers); n != 0 {
+		t.Errorf("expected handler stack to be empty, got %d", n)
+	}
+}
+
+// TestGuard は guard が送出されたエラーを捕捉してメッセージを取り出せること、
+// どの節にも一致しなければ再送出されることをテストします。
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(guard (e (#t 'caught)) (error "boom"))`, "caught"},
+		{`(guard (e ((error? e) (error-message e))) (error "boom" 1 2))`, `"boom"`},
+		{`(guard (e ((error? e) (error-message e))) (undefined-function 1))`, `"undefined symbol: undefined-function"`},
+		{`(guard (e ((symbol? e) 'symbol) ((string? e) e)) (raise "raised"))`, `"raised"`},
+		{`(guard (e ((error? e) 'error) (else 'other)) (raise 42))`, "other"},
+		{`(guard (e ((error? e))) (error "x"))`, "#t"},
+		// 内側の guard で一致しなかったエラーは外側の guard で捕捉される
+		{`(guard (outer (#t (error-message outer))) (guard (inner ((string? inner) 'no)) (error "deep")))`, `"deep"`},
+		// エラーが発生しなければ本体の最後の値を返す
+		{`(guard (e (#t 'caught)) 1 2)`, "2"},
+	}
+	env := NewGlobalEnv()
+	env.Set("symbol?", &Builtin{Name: "symbol?", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		_, ok := args[0].(parser.Symbol)
+		return parser.Boolean(ok), nil
+	}})
+	env.Set("string?", &Builtin{Name: "string?", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		_, ok := args[0].(parser.String)
+		return parser.Boolean(ok), nil
+	}})
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// どの節にも一致しなければ元のエラーが再送出される
+	reraised := []struct {
+		input    string
+		expected string
+	}{
+		{`(guard (e ((string? e) 'string)) (error "boom" 'x 1))`, "boom x 1"},
+		{`(guard (e ((error? e) 'error)) (raise 'oops))`, "raise: oops"},
+	}
+	for _, tt := range reraised {
+		if _, err := evalString(t, env, tt.input); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
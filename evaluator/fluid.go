@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// frameOf はシンボルを束縛している最も内側の環境を返します。束縛がなければ nil を返します。
+func (env *Env) frameOf(sym parser.Symbol) *Env {
+	for e := env; e != nil; e = e.outer {
+		if _, ok := e.lookup(sym); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// fluidBinding は fluid-let で一時的に書き換えた束縛と、その元の値です。
+type fluidBinding struct {
+	frame *Env
+	name  parser.Symbol
+	old   parser.Expr
+}
+
+// evalFluidLet は (fluid-let ((var val) ...) body...) を評価します。
+// 既存の束縛を新しい値に書き換えて body を評価し、終了後（エラーや throw で抜けた場合も）元の値に戻します。
+// let と異なり新しいスコープは作らないため、body から呼び出した関数にも書き換えた値が見えます。
+func evalFluidLet(exp parser.List, env *Env) (parser.Expr, error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("fluid-let: too few arguments")
+	}
+	specs, ok := exp[1].(parser.List)
+	if !ok {
+		return nil, fmt.Errorf("fluid-let: bindings must be a list")
+	}
+	// 新しい値はすべて書き換える前に評価する
+	bindings := make([]fluidBinding, 0, len(specs))
+	values := make([]parser.Expr, 0, len(specs))
+	for _, spec := range specs {
+		pair, ok := spec.(parser.List)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("fluid-let: each binding must be (var val), got %s", WriteString(spec))
+		}
+		name, ok := pair[0].(parser.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("fluid-let: variable must be a symbol, got %s", WriteString(pair[0]))
+		}
+		frame := env.frameOf(name)
+		if frame == nil {
+			return nil, fmt.Errorf("fluid-let: unbound variable: %s", name)
+		}
+		if err := frame.checkAssignable("fluid-let", name); err != nil {
+			return nil, err
+		}
+		val, err := evalExpr(pair[1], env)
+		if err != nil {
+			return nil, err
+		}
+		old, _ := frame.lookup(name)
+		bindings = append(bindings, fluidBinding{frame: frame, name: name, old: old})
+		values = append(values, val)
+	}
+	for i, b := range bindings {
+		b.frame.Set(b.name, values[i])
+	}
+	defer func() {
+		for i := len(bindings) - 1; i >= 0; i-- {
+			bindings[i].frame.Set(bindings[i].name, bindings[i].old)
+		}
+	}()
+	return evalBody(exp[2:], env)
+}
@@ -0,0 +1,46 @@
+package evaluator
+
+import "testing"
+
+// TestFluidLet は fluid-let が本体の間だけグローバル変数の値を書き換え、
+// 終了後（エラーで抜けた場合も）元の値に戻すことをテストします。
+func TestFluidLet(t *testing.T) {
+	env := NewGlobalEnv()
+	setup := `
+(define depth 1)
+(define (current-depth) depth)
+`
+	if _, err := evalString(t, env, setup); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 本体から呼び出した関数にも書き換えた値が見える
+		{"(fluid-let ((depth 2)) (current-depth))", "2"},
+		{"(fluid-let ((depth (+ depth 10))) (fluid-let ((depth (* depth 2))) (current-depth)))", "22"},
+		{"depth", "1"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	if _, err := evalString(t, env, `(fluid-let ((depth 5)) (error "fail"))`); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := evalToString(t, env, "depth"); got != "1" {
+		t.Errorf("expected depth to be restored to 1 after an error, got %s", got)
+	}
+	if got := evalToString(t, env, "(catch 'out (fluid-let ((depth 5)) (throw 'out depth)))"); got != "5" {
+		t.Errorf("expected thrown value 5, got %s", got)
+	}
+	if got := evalToString(t, env, "depth"); got != "1" {
+		t.Errorf("expected depth to be restored to 1 after a throw, got %s", got)
+	}
+	if _, err := evalString(t, env, "(fluid-let ((unbound-var 1)) 1)"); err == nil {
+		t.Error("expected error for an unbound variable, got nil")
+	}
+}
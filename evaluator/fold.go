@@ -0,0 +1,198 @@
+package evaluator
+
+import (
+	"sync"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// 定数畳み込み
+//
+// FoldConstants は評価前の AST を変換し、純粋な組み込み関数をリテラルだけに適用している式を
+// あらかじめ計算した値に置き換えます。例えば (* 2 (+ 1 2)) は 6 になります。
+// 畳み込むのは foldableBuiltins にある副作用のない組み込み関数だけで、シンボルを含む式、
+// quote や define などの特殊フォーム自体、入出力を行う関数の呼び出しはそのまま残します。
+// 組み込み関数がトップレベルで再定義されていないことを前提とします。
+
+// foldableBuiltins は畳み込んでよい純粋な組み込み関数の名前です。
+var foldableBuiltins = map[parser.Symbol]bool{
+	// 数値演算
+	"+":                  true,
+	"-":                  true,
+	"*":                  true,
+	"/":                  true,
+	"quotient":           true,
+	"remainder":          true,
+	"modulo":             true,
+	"truncate-quotient":  true,
+	"truncate-remainder": true,
+	"floor-quotient":     true,
+	"floor-remainder":    true,
+	"round-to":           true,
+	"number->string":     true,
+	"=":                  true,
+	"<":                  true,
+	">":                  true,
+	"<=":                 true,
+	">=":                 true,
+	// 数値の述語
+	"number?":                    true,
+	"complex?":                   true,
+	"real?":                      true,
+	"rational?":                  true,
+	"integer?":                   true,
+	"exact-integer?":             true,
+	"exact-nonnegative-integer?": true,
+	"even?":                      true,
+	"odd?":                       true,
+	"nan?":                       true,
+	"infinite?":                  true,
+	"finite?":                    true,
+	// 文字と文字列
+	"string=?":          true,
+	"string<?":          true,
+	"string-ci=?":       true,
+	"string-ci<?":       true,
+	"char=?":            true,
+	"char-ci=?":         true,
+	"char-foldcase":     true,
+	"string-reverse":    true,
+	"string-trim":       true,
+	"string-trim-left":  true,
+	"string-trim-right": true,
+}
+
+var (
+	foldEnvOnce sync.Once
+	foldEnv     *Env
+)
+
+// foldBuiltin は畳み込みに使う組み込み関数を返します。
+func foldBuiltin(name parser.Symbol) (Callable, bool) {
+	foldEnvOnce.Do(func() { foldEnv = NewGlobalEnv() })
+	val, ok := foldEnv.Get(name)
+	if !ok {
+		return nil, false
+	}
+	b, ok := val.(*Builtin)
+	return b, ok
+}
+
+// isFoldLiteral は式が評価しても自分自身になるリテラルであるかを判定します。
+// 畳み込みの引数と結果はこれに限ります（リストは関数適用として評価されてしまうため含めません）。
+func isFoldLiteral(expr parser.Expr) bool {
+	switch expr.(type) {
+	case parser.Integer, parser.Rational, parser.Float, parser.String, parser.Boolean, parser.Char:
+		return true
+	}
+	return false
+}
+
+// FoldConstants は expr の中の、純粋な組み込み関数をリテラルに適用した部分式を計算済みの値に置き換えた式を返します。
+// expr 自体は変更しません。
+func FoldConstants(expr parser.Expr) parser.Expr {
+	return foldExpr(expr, nil)
+}
+
+// foldExpr は expr を畳み込みます。shadowed は lambda の仮引数や内部の define によって
+// 組み込み関数が隠されている名前です。
+func foldExpr(expr parser.Expr, shadowed map[parser.Symbol]bool) parser.Expr {
+	list, ok := expr.(parser.List)
+	if !ok || len(list) == 0 {
+		return expr
+	}
+	if head, ok := list[0].(parser.Symbol); ok && !shadowed[head] {
+		switch head {
+		case "lambda":
+			// (lambda params body...)
+			if len(list) < 3 {
+				return expr
+			}
+			return foldBinding(list, 2, list[1], shadowed)
+		case "define":
+			if len(list) < 3 {
+				return expr
+			}
+			// (define (name params...) body...)
+			if sig, ok := list[1].(parser.List); ok && len(sig) > 0 {
+				return foldBinding(list, 2, sig[1:], shadowed)
+			}
+			// (define var expr)
+			result := append(parser.List{}, list[:2]...)
+			for _, e := range list[2:] {
+				result = append(result, foldExpr(e, shadowed))
+			}
+			return result
+		case "begin", "if":
+			// (begin expr...) や (if test then else) の各式は通常の式として畳み込む
+			result := parser.List{head}
+			for _, e := range list[1:] {
+				result = append(result, foldExpr(e, shadowed))
+			}
+			return result
+		}
+		// quote を含む他の特殊フォームは、部分式の意味（パターンなど）が異なるため変換しない
+		if specialForms[head] {
+			return expr
+		}
+	}
+
+	folded := make(parser.List, len(list))
+	allLiteral := true
+	for i, e := range list {
+		folded[i] = foldExpr(e, shadowed)
+		if i > 0 && !isFoldLiteral(folded[i]) {
+			allLiteral = false
+		}
+	}
+	head, ok := folded[0].(parser.Symbol)
+	if !ok || !allLiteral || shadowed[head] || !foldableBuiltins[head] {
+		return folded
+	}
+	fn, ok := foldBuiltin(head)
+	if !ok {
+		return folded
+	}
+	result, err := fn.Call(folded[1:])
+	// (/ 1 0) のようにエラーになる式は、実行時に同じエラーが起きるよう残す
+	if err != nil || !isFoldLiteral(result) {
+		return folded
+	}
+	return result
+}
+
+// foldBinding は lambda や関数定義の本体 list[bodyStart:] を、params と本体の内部 define で
+// 束縛される名前を隠したうえで畳み込みます。
+func foldBinding(list parser.List, bodyStart int, params parser.Expr, shadowed map[parser.Symbol]bool) parser.Expr {
+	inner := make(map[parser.Symbol]bool, len(shadowed))
+	for name := range shadowed {
+		inner[name] = true
+	}
+	addBoundNames(inner, params)
+	for _, e := range list[bodyStart:] {
+		if def, ok := e.(parser.List); ok && len(def) >= 2 && def[0] == parser.Symbol("define") {
+			if sig, ok := def[1].(parser.List); ok && len(sig) > 0 {
+				addBoundNames(inner, sig[0])
+			} else {
+				addBoundNames(inner, def[1])
+			}
+		}
+	}
+	result := append(parser.List{}, list[:bodyStart]...)
+	for _, e := range list[bodyStart:] {
+		result = append(result, foldExpr(e, inner))
+	}
+	return result
+}
+
+// addBoundNames は仮引数（シンボル、またはネストしたリスト）で束縛されるシンボルを names に加えます。
+func addBoundNames(names map[parser.Symbol]bool, params parser.Expr) {
+	switch p := params.(type) {
+	case parser.Symbol:
+		names[p] = true
+	case parser.List:
+		for _, elem := range p {
+			addBoundNames(names, elem)
+		}
+	}
+}
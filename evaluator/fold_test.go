@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestFoldConstants は純粋な組み込み関数をリテラルに適用した式だけが畳み込まれることをテストします。
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(* 2 (+ 1 2))", "6"},
+		{"(+ 1.5 (/ 6 4))", "3.0"},
+		{`(string=? "a" "a")`, "#t"},
+		{"(even? (* 3 4))", "#t"},
+		// シンボルを含む式は残し、その中のリテラルだけの部分式は畳み込む
+		{"(+ x 1)", "(+ x 1)"},
+		{"(+ x (* 2 3))", "(+ x 6)"},
+		{"(define y (+ 1 2))", "(define y 3)"},
+		{"(define (f x) (* x (+ 1 1)))", "(define (f x) (* x 2))"},
+		// 入出力を行う関数や未知の関数の呼び出しは残す
+		{"(display (+ 1 2))", "(display 3)"},
+		{"(list 1 2)", "(list 1 2)"},
+		// quote や他の特殊フォームの中は変換しない
+		{"'(+ 1 2)", "'(+ 1 2)"},
+		{"(match 3 ((+ 1 2) 'list) (_ 'other))", "(match 3 ((+ 1 2) 'list) (_ 'other))"},
+		// begin の各式は通常の式として畳み込む
+		{"(begin (display (+ 1 2)) (* 2 3))", "(begin (display 3) 6)"},
+		{"(if (< 1 2) (+ 1 2) (display (* 2 3)))", "(if #t 3 (display 6))"},
+		// 仮引数や内部の define で組み込み関数が隠されている場合は畳み込まない
+		{"(lambda (+) (+ 1 2))", "(lambda (+) (+ 1 2))"},
+		{"(lambda ((a +)) (+ 1 2))", "(lambda ((a +)) (+ 1 2))"},
+		{"(lambda (x) (define (* a b) a) (* 1 2))", "(lambda (x) (define (* a b) a) (* 1 2))"},
+		{"(lambda (x) (- 5 2))", "(lambda (x) 3)"},
+		// エラーになる式は実行時に同じエラーが起きるよう残す
+		{"(/ 1 0)", "(/ 1 0)"},
+		// 結果が文字列などのリテラルであれば同様に畳み込む
+		{`(number->string 10 2)`, `"1010"`},
+	}
+	for _, tt := range tests {
+		expr, err := parser.NewParser(strings.NewReader(tt.input)).ParseExpr()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", tt.input, err)
+		}
+		original := WriteString(expr)
+		if got := WriteString(FoldConstants(expr)); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+		if WriteString(expr) != original {
+			t.Errorf("%s: FoldConstants modified its input", tt.input)
+		}
+	}
+}
+
+// TestFoldConstantsPreservesResults は畳み込んだプログラムの評価結果が元のプログラムと一致することをテストします。
+func TestFoldConstantsPreservesResults(t *testing.T) {
+	program := `
+	(define (area r) (* r r (/ 314 100)))
+	(define k (- 10 (* 2 3)))
+	(area k)
+	`
+	exprs, err := parser.NewParser(strings.NewReader(program)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	folded := make([]parser.Expr, len(exprs))
+	for i, expr := range exprs {
+		folded[i] = FoldConstants(expr)
+	}
+	want, err := EvalAll(exprs, NewGlobalEnv())
+	if err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	got, err := EvalAll(folded, NewGlobalEnv())
+	if err != nil {
+		t.Fatalf("EvalAll (folded) error: %v", err)
+	}
+	if WriteString(got) != WriteString(want) {
+		t.Errorf("expected %s, got %s", WriteString(want), WriteString(got))
+	}
+}
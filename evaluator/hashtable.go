@@ -0,0 +1,382 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// HashTable は Scheme のハッシュテーブルを表します。
+// キーの比較は equal? 相当（構造が等しければ同じキー）で行い、挿入順を保持します。
+type HashTable struct {
+	index   map[any]int
+	entries []hashEntry
+}
+
+// hashEntry はハッシュテーブルの1エントリ（キーと値の組）です。
+type hashEntry struct {
+	key   parser.Expr
+	value parser.Expr
+}
+
+// NewHashTable は空のハッシュテーブルを生成します。
+func NewHashTable() *HashTable {
+	return &HashTable{index: make(map[any]int)}
+}
+
+// hashKey は Expr から Go の map のキーとして使える値を求めます。
+// 比較可能な値はそのまま、リストやベクタなど isEqual が要素を比較する値は isEqual で等しいものが同じ文字列になるよう、構造を表す文字列に変換します。
+func hashKey(expr parser.Expr) any {
+	if r, ok := expr.(parser.Rational); ok {
+		return rationalKey(r.String())
+	}
+	if f, ok := expr.(parser.Float); ok {
+		// NaN は == で自分自身とも等しくならず map から引けないため、すべての NaN を1つのキーにまとめる。
+		// -0.0 は equal? で 0.0 と等しいため 0.0 にそろえる
+		switch {
+		case math.IsNaN(float64(f)):
+			return nanKey{}
+		case f == 0:
+			return parser.Float(0)
+		}
+		return f
+	}
+	if _, ok := expr.(*Vector); !ok && (expr == nil || reflect.TypeOf(expr).Comparable()) {
+		return expr
+	}
+	var sb strings.Builder
+	writeStructuralKey(&sb, expr, make(map[any]int))
+	return listKey(sb.String())
+}
+
+// writeStructuralKey はリストのキーとなる文字列を sb に書き込みます。
+// 要素は型を表す接頭辞を付けて書くため、(a) と ("a")、(1) と (1.0) は別のキーになります。
+// 文字列とシンボルは引用符で囲むため、要素の区切りと紛れることはありません。
+// 手続きやハッシュテーブルなど、isEqual が同一性で比較する値はアドレスで区別します。
+// path は書き込み中のリスト（listIdentity）とベクタ（*Vector）と、その外側からの深さです。循環する構造は、
+// たどっている途中のリストやベクタに戻る位置を深さの番号 #n# で書くため停止し、同じ形の循環は同じキーになります。
+func writeStructuralKey(sb *strings.Builder, expr parser.Expr, path map[any]int) {
+	if key, _, ok := labelKey(expr); ok {
+		if depth, ok := path[key]; ok {
+			fmt.Fprintf(sb, "#%d#", depth)
+			return
+		}
+		path[key] = len(path)
+		defer delete(path, key)
+	}
+	switch v := expr.(type) {
+	case parser.List:
+		writeStructuralElems(sb, "(", v, path)
+	case *Vector:
+		writeStructuralElems(sb, "#(", v.Elems, path)
+	case parser.Integer:
+		fmt.Fprintf(sb, "i%d", int64(v))
+	case parser.Float:
+		// 0.0 と -0.0 は == で等しいため同じキーにする
+		f := float64(v)
+		if f == 0 {
+			f = 0
+		}
+		sb.WriteString("f" + strconv.FormatFloat(f, 'g', -1, 64))
+	case parser.Rational:
+		sb.WriteString("r" + v.String())
+	case parser.String:
+		sb.WriteString("s" + strconv.Quote(string(v)))
+	case parser.Symbol:
+		sb.WriteString("y" + strconv.Quote(string(v)))
+	case parser.Char:
+		fmt.Fprintf(sb, "c%d", rune(v))
+	case parser.Boolean:
+		if v {
+			sb.WriteString("#t")
+		} else {
+			sb.WriteString("#f")
+		}
+	case nil:
+		sb.WriteString("nil")
+	default:
+		if reflect.TypeOf(v).Kind() == reflect.Pointer {
+			fmt.Fprintf(sb, "<%T %p>", v, v)
+		} else {
+			fmt.Fprintf(sb, "<%T %v>", v, v)
+		}
+	}
+}
+
+// writeStructuralElems はリストやベクタの要素のキーを、open と ")" で囲んで sb に書き込みます。
+func writeStructuralElems(sb *strings.Builder, open string, elems []parser.Expr, path map[any]int) {
+	sb.WriteString(open)
+	for i, elem := range elems {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		writeStructuralKey(sb, elem, path)
+	}
+	sb.WriteByte(')')
+}
+
+// listKey はリストのキーを他の文字列リテラルと区別するための型です。
+type listKey string
+
+// nanKey は NaN のキーです。
+type nanKey struct{}
+
+// rationalKey は分数のキーです。Rational はポインタを含むため、値を表す文字列をキーにします。
+type rationalKey string
+
+// Get はキーに対応する値を返します。
+func (h *HashTable) Get(key parser.Expr) (parser.Expr, bool) {
+	i, ok := h.index[hashKey(key)]
+	if !ok {
+		return nil, false
+	}
+	return h.entries[i].value, true
+}
+
+// Set はキーに値を設定します。既存のキーの場合は値を置き換えます。
+func (h *HashTable) Set(key, value parser.Expr) {
+	k := hashKey(key)
+	if i, ok := h.index[k]; ok {
+		h.entries[i].value = value
+		return
+	}
+	h.index[k] = len(h.entries)
+	h.entries = append(h.entries, hashEntry{key: key, value: value})
+}
+
+// Len はハッシュテーブルのエントリ数を返します。
+func (h *HashTable) Len() int {
+	return len(h.entries)
+}
+
+// builtinMakeHashTable は "make-hash-table" を実装します。
+func builtinMakeHashTable(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("make-hash-table: wrong number of arguments")
+	}
+	return NewHashTable(), nil
+}
+
+// builtinHashTableSet は "hash-table-set!" を実装します。
+// (hash-table-set! table key value)
+func builtinHashTableSet(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("hash-table-set!: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-set!: first argument must be a hash table")
+	}
+	table.Set(args[1], args[2])
+	return Unspecified, nil
+}
+
+// builtinHashTableRef は "hash-table-ref" を実装します。
+// (hash-table-ref table key [thunk]) キーが存在しない場合、thunk があればその結果を返します。
+func builtinHashTableRef(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("hash-table-ref: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-ref: first argument must be a hash table")
+	}
+	if val, ok := table.Get(args[1]); ok {
+		return val, nil
+	}
+	if len(args) == 3 {
+		thunk, ok := args[2].(Callable)
+		if !ok {
+			return nil, fmt.Errorf("hash-table-ref: third argument must be a procedure")
+		}
+		return thunk.Call(nil)
+	}
+	return nil, fmt.Errorf("hash-table-ref: key not found: %v", args[1])
+}
+
+// builtinHashTableRefDefault は "hash-table-ref/default" を実装します。
+// (hash-table-ref/default table key default) キーが存在しない場合は default を返します。
+func builtinHashTableRefDefault(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("hash-table-ref/default: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-ref/default: first argument must be a hash table")
+	}
+	if val, ok := table.Get(args[1]); ok {
+		return val, nil
+	}
+	return args[2], nil
+}
+
+// builtinHashTableRefBang は "hash-table-ref!" を実装します。
+// (hash-table-ref! table key thunk) キーが存在すればその値を返し、存在しなければ thunk を呼び出して
+// 結果をキーに格納してから返します。メモ化の基本操作として使えます。
+func builtinHashTableRefBang(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("hash-table-ref!: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-ref!: first argument must be a hash table")
+	}
+	thunk, ok := args[2].(Callable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-ref!: third argument must be a procedure")
+	}
+	if val, ok := table.Get(args[1]); ok {
+		return val, nil
+	}
+	val, err := thunk.Call(nil)
+	if err != nil {
+		return nil, err
+	}
+	table.Set(args[1], val)
+	return val, nil
+}
+
+// builtinHashTableUpdate は "hash-table-update!" を実装します。
+// (hash-table-update! table key proc [default]) 現在の値（なければ default）に proc を適用し、結果を格納します。
+func builtinHashTableUpdate(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, fmt.Errorf("hash-table-update!: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-update!: first argument must be a hash table")
+	}
+	proc, ok := args[2].(Callable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-update!: third argument must be a procedure")
+	}
+	current, ok := table.Get(args[1])
+	if !ok {
+		if len(args) != 4 {
+			return nil, fmt.Errorf("hash-table-update!: key not found: %v", args[1])
+		}
+		current = args[3]
+	}
+	updated, err := proc.Call([]parser.Expr{current})
+	if err != nil {
+		return nil, err
+	}
+	table.Set(args[1], updated)
+	return updated, nil
+}
+
+// builtinFrequencies は "frequencies" を実装します。
+// (frequencies list) 各要素（equal? で区別）をその出現回数に対応付けたハッシュテーブルを返します。
+// キーはリスト中で最初に現れた順に並びます。
+func builtinFrequencies(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("frequencies: wrong number of arguments")
+	}
+	list, err := listArg("frequencies", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	table := NewHashTable()
+	for _, elem := range list {
+		count, ok := table.Get(elem)
+		if !ok {
+			count = parser.Integer(0)
+		}
+		table.Set(elem, count.(parser.Integer)+1)
+	}
+	return table, nil
+}
+
+// builtinHashTableForEach は "hash-table-for-each" を実装します。
+// (hash-table-for-each table proc) 各エントリについて、挿入順に (proc key value) を副作用のために呼び出します。
+// proc の中でテーブルを変更しても、呼び出し開始時点のエントリだけを順に処理します。
+func builtinHashTableForEach(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("hash-table-for-each: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-for-each: first argument must be a hash table")
+	}
+	proc, err := procArg("hash-table-for-each", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range append([]hashEntry(nil), table.entries...) {
+		if _, err := proc.Call([]parser.Expr{e.key, e.value}); err != nil {
+			return nil, err
+		}
+	}
+	return Unspecified, nil
+}
+
+// builtinHashTableFold は "hash-table-fold" を実装します。
+// (hash-table-fold table proc init) 各エントリについて挿入順に acc = (proc key value acc) を計算し、最後の acc を返します。
+func builtinHashTableFold(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("hash-table-fold: wrong number of arguments")
+	}
+	table, ok := args[0].(*HashTable)
+	if !ok {
+		return nil, fmt.Errorf("hash-table-fold: first argument must be a hash table")
+	}
+	proc, err := procArg("hash-table-fold", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	acc := args[2]
+	for _, e := range append([]hashEntry(nil), table.entries...) {
+		if acc, err = proc.Call([]parser.Expr{e.key, e.value, acc}); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// builtinMemoize は "memoize" を実装します。
+// (memoize proc) proc と同じ結果を返す新しい手続きを返します。
+// 結果は引数のリストをキー（equal? で比較）としてハッシュテーブルに記録し、
+// 同じ引数で再び呼ばれたときは proc を呼び出さずに記録した結果を返します。
+func builtinMemoize(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("memoize: wrong number of arguments")
+	}
+	proc, err := procArg("memoize", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	cache := NewHashTable()
+	return &Builtin{
+		Name: "memoized",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			key := append(parser.List{}, args...)
+			if val, ok := cache.Get(key); ok {
+				return val, nil
+			}
+			val, err := proc.Call(args)
+			if err != nil {
+				return nil, err
+			}
+			cache.Set(key, val)
+			return val, nil
+		},
+	}, nil
+}
+
+// registerHashTableBuiltins はハッシュテーブル関連の組み込み関数を環境に登録します。
+func registerHashTableBuiltins(env *Env) {
+	env.Set("make-hash-table", &Builtin{Name: "make-hash-table", Fn: builtinMakeHashTable})
+	env.Set("hash-table-set!", &Builtin{Name: "hash-table-set!", Fn: builtinHashTableSet})
+	env.Set("hash-table-ref", &Builtin{Name: "hash-table-ref", Fn: builtinHashTableRef})
+	env.Set("hash-table-ref/default", &Builtin{Name: "hash-table-ref/default", Fn: builtinHashTableRefDefault})
+	env.Set("hash-table-ref!", &Builtin{Name: "hash-table-ref!", Fn: builtinHashTableRefBang})
+	env.Set("hash-table-update!", &Builtin{Name: "hash-table-update!", Fn: builtinHashTableUpdate})
+	env.Set("hash-table-for-each", &Builtin{Name: "hash-table-for-each", Fn: builtinHashTableForEach})
+	env.Set("hash-table-fold", &Builtin{Name: "hash-table-fold", Fn: builtinHashTableFold})
+	env.Set("memoize", &Builtin{Name: "memoize", Fn: builtinMemoize})
+	env.Set("frequencies", &Builtin{Name: "frequencies", Fn: builtinFrequencies})
+}
@@ -0,0 +1,371 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestHashTableUpdateCounting は hash-table-update! と既定値 0 を使って
+// リスト中のシンボルの出現回数を数えられることをテストします。
+func TestHashTableUpdateCounting(t *testing.T) {
+	env := NewGlobalEnv()
+	setup := `
+	(define counts (make-hash-table))
+	(define inc (lambda (n) (+ n 1)))
+	(define words '(a b a c a b))
+	`
+	if _, err := evalString(t, env, setup); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+
+	// words を畳み込みながら、各シンボルについて hash-table-update! を適用する
+	words, _ := env.Get("words")
+	update, _ := env.Get("hash-table-update!")
+	counts, _ := env.Get("counts")
+	inc, _ := env.Get("inc")
+	for _, word := range words.(parser.List) {
+		if _, err := update.(Callable).Call([]parser.Expr{counts, word, inc, parser.Integer(0)}); err != nil {
+			t.Fatalf("hash-table-update! error: %v", err)
+		}
+	}
+
+	expected := map[parser.Symbol]parser.Integer{"a": 3, "b": 2, "c": 1}
+	for sym, want := range expected {
+		result, err := evalString(t, env, "(hash-table-ref counts '"+string(sym)+")")
+		if err != nil {
+			t.Fatalf("EvalAll error: %v", err)
+		}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("count of %s: expected %v, got %v", sym, want, result)
+		}
+	}
+	if n := counts.(*HashTable).Len(); n != 3 {
+		t.Errorf("expected 3 entries, got %d", n)
+	}
+}
+
+// TestHashTableUpdateMissingKey は既定値なしで存在しないキーを更新するとエラーになることをテストします。
+func TestHashTableUpdateMissingKey(t *testing.T) {
+	input := `
+	(define table (make-hash-table))
+	(hash-table-update! table 'missing (lambda (n) (+ n 1)))
+	`
+	_, err := evalString(t, NewGlobalEnv(), input)
+	if err == nil {
+		t.Fatal("expected error for missing key without default, got nil")
+	}
+	if !strings.Contains(err.Error(), "key not found") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+// TestHashTableRefDefault は hash-table-ref/default が存在しないキーに対してエラーにせず既定値を返すことをテストします。
+func TestHashTableRefDefault(t *testing.T) {
+	env := NewGlobalEnv()
+	if _, err := evalString(t, env, "(define table (make-hash-table)) (hash-table-set! table 'a 1)"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(hash-table-ref/default table 'a 0)", "1"},
+		{"(hash-table-ref/default table 'b 0)", "0"},
+		{"(hash-table-ref/default table '(x y) 'none)", "none"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	// 既定値を返してもテーブルは変更されない
+	if _, err := evalString(t, env, "(hash-table-ref table 'b)"); err == nil {
+		t.Error("expected hash-table-ref/default not to store the default")
+	}
+}
+
+// TestHashTableRefBang は hash-table-ref! が最初の参照時だけ thunk を呼び出して結果を格納し、
+// 以降の参照では格納した値を返すことをテストします。
+func TestHashTableRefBang(t *testing.T) {
+	env := NewGlobalEnv()
+	calls := 0
+	// compute は呼び出し回数を数え、その回数に 10 を掛けた値を返します
+	env.Set("compute", &Builtin{Name: "compute", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		calls++
+		return parser.Integer(calls * 10), nil
+	}})
+	if _, err := evalString(t, env, "(define table (make-hash-table))"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	steps := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		{"(hash-table-ref! table 'k compute)", "10", 1},
+		{"(hash-table-ref table 'k)", "10", 1},
+		{"(hash-table-ref! table 'k compute)", "10", 1},
+		{"(hash-table-ref! table 'other compute)", "20", 2},
+		{"(hash-table-ref! table 'other compute)", "20", 2},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+		if calls != step.calls {
+			t.Errorf("%s: expected %d thunk calls, got %d", step.input, step.calls, calls)
+		}
+	}
+}
+
+// TestFrequencies は frequencies が各要素の出現回数を数えたハッシュテーブルを返すことをテストします。
+func TestFrequencies(t *testing.T) {
+	env := NewGlobalEnv()
+	if _, err := evalString(t, env, "(define counts (frequencies '(a 1 b a 2 1 a (x) (x))))"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"'a", "3"},
+		{"'b", "1"},
+		{"1", "2"},
+		{"2", "1"},
+		// リストも equal? で同じキーとみなす
+		{"'(x)", "2"},
+		{"'c", "0"},
+	}
+	for _, tt := range tests {
+		input := "(hash-table-ref/default counts " + tt.key + " 0)"
+		if got := evalToString(t, env, input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", input, tt.expected, got)
+		}
+	}
+	counts, _ := env.Get("counts")
+	if n := counts.(*HashTable).Len(); n != 5 {
+		t.Errorf("expected 5 entries, got %d", n)
+	}
+
+	empty, err := evalString(t, env, "(frequencies '())")
+	if err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if n := empty.(*HashTable).Len(); n != 0 {
+		t.Errorf("expected empty table, got %d entries", n)
+	}
+}
+
+// TestHashTableFoldAndForEach は hash-table-fold と hash-table-for-each がエントリを挿入順に処理することをテストします。
+func TestHashTableFoldAndForEach(t *testing.T) {
+	env := NewGlobalEnv()
+	input := `
+	(define table (make-hash-table))
+	(hash-table-set! table 'b 2)
+	(hash-table-set! table 'a 1)
+	(hash-table-set! table 'c 3)
+	(hash-table-set! table 'a 10)
+	`
+	if _, err := evalString(t, env, input); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(hash-table-fold table (lambda (k v acc) (+ v acc)) 0)", "15"},
+		// 既存のキーへの設定では順序は変わらない
+		{"(hash-table-fold table (lambda (k v acc) (cons* k acc)) '())", "(c a b)"},
+		{"(hash-table-fold (make-hash-table) (lambda (k v acc) (+ v acc)) 0)", "0"},
+		{`(define out (open-output-string))
+		  (hash-table-for-each table (lambda (k v) (write k out)))
+		  (get-output-string out)`, `"bac"`},
+		// 処理中に追加したエントリは対象にならない
+		{`(hash-table-for-each table (lambda (k v) (hash-table-set! table (list k) v)))
+		  (hash-table-fold table (lambda (k v acc) (+ 1 acc)) 0)`, "6"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(hash-table-fold '() + 0)", "(hash-table-fold table 1 0)", "(hash-table-for-each table)", "(hash-table-for-each table (lambda (k) k))"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestMemoize は memoize した手続きが異なる引数ごとに一度だけ元の手続きを呼び出すことをテストします。
+func TestMemoize(t *testing.T) {
+	env := NewGlobalEnv()
+	calls := map[string]int{}
+	// slow-square は引数ごとの呼び出し回数を数える、計算に時間のかかる手続きの代わりです
+	env.Set("slow-square", &Builtin{Name: "slow-square", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		calls[WriteString(parser.List(args))]++
+		n := args[0].(parser.Integer)
+		return n * n, nil
+	}})
+	if _, err := evalString(t, env, "(define square (memoize slow-square))"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{"(square 3)", "9"},
+		{"(square 3)", "9"},
+		{"(square 4)", "16"},
+		{"(square 3)", "9"},
+		{"(square 4)", "16"},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+	}
+	expected := map[string]int{"(3)": 1, "(4)": 1}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+
+	// 再帰する手続きを memoize すると、再帰呼び出しもキャッシュを通る
+	input := `
+	(define counter (open-output-string))
+	(define (sum-to n)
+	  (list-ref (list (write-string "." counter)
+	                  (match n (0 0) (_ (+ n (sum-to (- n 1))))))
+	            1))
+	(define sum-to (memoize sum-to))
+	(sum-to 5)
+	(sum-to 6)
+	(get-output-string counter)
+	`
+	// 本体は引数 0〜6 について1回ずつ、合計7回だけ実行される
+	if got := evalToString(t, NewGlobalEnv(), input); got != `"......."` {
+		t.Errorf("expected the body to run once per distinct argument, got %s", got)
+	}
+}
+
+// TestHashTableListKeyTypes はリストのキーが要素の型も区別し、シンボルと文字列、整数と浮動小数点数を
+// 要素とするリストが別のキーになることをテストします。
+func TestHashTableListKeyTypes(t *testing.T) {
+	env := NewGlobalEnv()
+	input := `
+	(define h (make-hash-table))
+	(hash-table-set! h (list 'a) 'symbol)
+	(hash-table-set! h (list "a") 'string)
+	(hash-table-set! h (list 1) 'integer)
+	(hash-table-set! h (list 1.0) 'float)
+	(hash-table-set! h (list (list 'a "a")) 'nested)
+	`
+	if _, err := evalString(t, env, input); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(hash-table-ref h (list 'a))", "symbol"},
+		{`(hash-table-ref h (list "a"))`, "string"},
+		{"(hash-table-ref h (list 1))", "integer"},
+		{"(hash-table-ref h (list 1.0))", "float"},
+		{`(hash-table-ref h '((a "a")))`, "nested"},
+		{`(hash-table-ref/default h '(("a" a)) 'none)`, "none"},
+		{"(hash-table-ref/default h '(|a b|) 'none)", "none"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	counts, err := evalString(t, env, `(frequencies '((1) (1.0) ("x") (x)))`)
+	if err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if n := counts.(*HashTable).Len(); n != 4 {
+		t.Errorf("expected 4 entries, got %d", n)
+	}
+
+	// memoize も引数の型を区別する
+	memo := `
+	(define f (memoize (lambda (x) (list x))))
+	(f (list 'a))
+	(f (list "a"))
+	`
+	if got := evalToString(t, env, memo); got != `(("a"))` {
+		t.Errorf("expected ((\"a\")), got %s", got)
+	}
+	if got := evalToString(t, env, `(f 'a) (f "a")`); got != `("a")` {
+		t.Errorf("expected (\"a\"), got %s", got)
+	}
+}
+
+// TestHashTableNestedRationalKey はリストの中の分数も値で比較され、同じ値の分数を含むリストで引けることをテストします。
+func TestHashTableNestedRationalKey(t *testing.T) {
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define h (make-hash-table))", "h"},
+		{"(hash-table-set! h (list 1/2) 'half)", ""},
+		{"(hash-table-ref h (list 1/2))", "half"},
+		{"(hash-table-ref h (list (/ 2 4)))", "half"},
+		{"(hash-table-ref/default h (list 0.5) 'none)", "none"},
+		{"(hash-table-set! h (list 'a (list 1/3)) 'third)", ""},
+		{"(hash-table-ref h '(a (1/3)))", "third"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// 分数を引数とする memoize した手続きは、同じ値の引数でキャッシュを使う
+	calls := 0
+	env.Set("slow-double", &Builtin{Name: "slow-double", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		calls++
+		return builtinAdd([]parser.Expr{args[0], args[0]})
+	}})
+	if got := evalToString(t, env, "(define double (memoize slow-double)) (double 1/3) (double (/ 1 3))"); got != "2/3" {
+		t.Errorf("expected 2/3, got %s", got)
+	}
+	if calls != 1 {
+		t.Errorf("expected slow-double to be called once, got %d", calls)
+	}
+}
+
+// TestHashTableFloatKeys は NaN をキーにしても引けること、-0.0 と 0.0 が equal? と同じく同じキーになることをテストします。
+func TestHashTableFloatKeys(t *testing.T) {
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define h (make-hash-table))", "h"},
+		{"(hash-table-set! h +nan.0 'nan)", ""},
+		{"(hash-table-set! h -nan.0 'nan-again)", ""},
+		{"(hash-table-ref h +nan.0)", "nan-again"},
+		{"(hash-table-ref/default h (/ 0.0 0.0) 'none)", "nan-again"},
+		{"(hash-table-set! h -0.0 'zero)", ""},
+		{"(hash-table-ref h 0.0)", "zero"},
+		{"(hash-table-ref/default h 0 'none)", "none"},
+		{"(hash-table-set! h (list +nan.0 -0.0) 'list)", ""},
+		{"(hash-table-ref h (list -nan.0 0.0))", "list"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	// NaN どうしと 0.0 どうしは上書きされ、到達できない項目は増えない
+	h, _ := env.Get("h")
+	if n := h.(*HashTable).Len(); n != 3 {
+		t.Errorf("expected 3 entries, got %d", n)
+	}
+}
@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// ベクタを二分ヒープとして扱う操作です。
+// 比較手続き less は (less a b) が #f 以外を返すとき a が b より先に取り出されるものとします。
+// 先頭（添字 0）の要素が常に最小になるよう、push と pop のたびにヒープ条件を保ちます。
+
+// heapLess は比較手続きを呼び出し、a が b より小さいかを返します。
+func heapLess(less Callable, a, b parser.Expr) (bool, error) {
+	result, err := less.Call([]parser.Expr{a, b})
+	if err != nil {
+		return false, err
+	}
+	return isTrue(result), nil
+}
+
+// heapUp は添字 i の要素を親と比較しながら上へ移動させます。
+func heapUp(elems []parser.Expr, i int, less Callable) error {
+	for i > 0 {
+		parent := (i - 1) / 2
+		ok, err := heapLess(less, elems[i], elems[parent])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		elems[i], elems[parent] = elems[parent], elems[i]
+		i = parent
+	}
+	return nil
+}
+
+// heapDown は添字 i の要素を子と比較しながら下へ移動させます。
+func heapDown(elems []parser.Expr, i int, less Callable) error {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child >= len(elems) {
+				continue
+			}
+			ok, err := heapLess(less, elems[child], elems[smallest])
+			if err != nil {
+				return err
+			}
+			if ok {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return nil
+		}
+		elems[i], elems[smallest] = elems[smallest], elems[i]
+		i = smallest
+	}
+}
+
+// builtinHeapPush は "heap-push!" を実装します。
+// (heap-push! vec item less) item をヒープに追加します。
+func builtinHeapPush(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("heap-push!: wrong number of arguments")
+	}
+	v, err := vectorArg("heap-push!", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	less, err := procArg("heap-push!", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	v.Elems = append(v.Elems, args[1])
+	if err := heapUp(v.Elems, len(v.Elems)-1, less); err != nil {
+		return nil, err
+	}
+	return Unspecified, nil
+}
+
+// builtinHeapPop は "heap-pop!" を実装します。
+// (heap-pop! vec less) 最小の要素を取り除いて返します。
+func builtinHeapPop(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("heap-pop!: wrong number of arguments")
+	}
+	v, err := vectorArg("heap-pop!", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	less, err := procArg("heap-pop!", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Elems) == 0 {
+		return nil, fmt.Errorf("heap-pop!: heap is empty")
+	}
+	top := v.Elems[0]
+	last := len(v.Elems) - 1
+	v.Elems[0] = v.Elems[last]
+	v.Elems[last] = nil
+	v.Elems = v.Elems[:last]
+	if err := heapDown(v.Elems, 0, less); err != nil {
+		return nil, err
+	}
+	return top, nil
+}
+
+// builtinHeapPeek は "heap-peek" を実装します。
+// (heap-peek vec) 最小の要素を取り除かずに返します。
+func builtinHeapPeek(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("heap-peek: wrong number of arguments")
+	}
+	v, err := vectorArg("heap-peek", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Elems) == 0 {
+		return nil, fmt.Errorf("heap-peek: heap is empty")
+	}
+	return v.Elems[0], nil
+}
+
+// registerHeapBuiltins はヒープ操作の組み込み関数を環境に登録します。
+func registerHeapBuiltins(env *Env) {
+	env.Set("heap-push!", &Builtin{Name: "heap-push!", Fn: builtinHeapPush})
+	env.Set("heap-pop!", &Builtin{Name: "heap-pop!", Fn: builtinHeapPop})
+	env.Set("heap-peek", &Builtin{Name: "heap-peek", Fn: builtinHeapPeek})
+}
@@ -0,0 +1,37 @@
+package evaluator
+
+import "testing"
+
+// TestHeapPushPop はバラバラの順序で追加した整数が、heap-pop! で昇順に取り出されることをテストします。
+func TestHeapPushPop(t *testing.T) {
+	env := NewGlobalEnv()
+	setup := `
+	(define h (vector))
+	(heap-push! h 5 <)
+	(heap-push! h 1 <)
+	(heap-push! h 8 <)
+	(heap-push! h 3 <)
+	(heap-push! h 9 <)
+	(heap-push! h 2 <)
+	(heap-push! h 7 <)
+	`
+	if _, err := evalString(t, env, setup); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if got := evalToString(t, env, "(heap-peek h)"); got != "1" {
+		t.Errorf("heap-peek: expected 1, got %s", got)
+	}
+	for _, expected := range []string{"1", "2", "3", "5", "7", "8", "9"} {
+		if got := evalToString(t, env, "(heap-pop! h <)"); got != expected {
+			t.Errorf("heap-pop!: expected %s, got %s", expected, got)
+		}
+	}
+	if got := evalToString(t, env, "(vector-length h)"); got != "0" {
+		t.Errorf("vector-length: expected 0, got %s", got)
+	}
+	for _, input := range []string{"(heap-pop! h <)", "(heap-peek h)", "(heap-push! '(1) 2 <)", "(heap-push! h 1 2)"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
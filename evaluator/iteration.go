@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// evalForRange は (for-range (var start end [step]) body...) を評価します。
+// var を start から end の手前まで step（既定は 1）ずつ変化させながら、副作用のために body を評価します。
+// step が負なら値を減らしながら end より大きい間だけ繰り返します。
+// 繰り返しは Go のループで行うため、回数が多くてもスタックは伸びません。結果は Unspecified です。
+func evalForRange(exp parser.List, env *Env) (parser.Expr, error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("for-range: too few arguments")
+	}
+	spec, ok := exp[1].(parser.List)
+	if !ok || len(spec) < 3 || len(spec) > 4 {
+		return nil, fmt.Errorf("for-range: first argument must be (var start end [step])")
+	}
+	name, ok := spec[0].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("for-range: variable must be a symbol, got %s", WriteString(spec[0]))
+	}
+	if err := checkBindable("for-range", name); err != nil {
+		return nil, err
+	}
+	bounds := [3]int64{0, 0, 1}
+	for i, expr := range spec[1:] {
+		val, err := evalExpr(expr, env)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := val.(parser.Integer)
+		if !ok {
+			return nil, fmt.Errorf("for-range: bounds and step must be integers, got %s", WriteString(val))
+		}
+		bounds[i] = int64(n)
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, fmt.Errorf("for-range: step must not be zero")
+	}
+	// 残りの幅と step の大きさは uint64 で表せるため、i += step があふれる前に終わりを判定できる
+	stride := uint64(step)
+	if step < 0 {
+		stride = uint64(-step)
+	}
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		// 本体で作ったクロージャがそれぞれの値を捕捉できるよう、繰り返しごとに環境を作る
+		loopEnv := NewEnv(env)
+		loopEnv.Set(name, parser.Integer(i))
+		if _, err := evalBody(exp[2:], loopEnv); err != nil {
+			return nil, err
+		}
+		remaining := uint64(end - i)
+		if step < 0 {
+			remaining = uint64(i - end)
+		}
+		if stride >= remaining {
+			break
+		}
+	}
+	return Unspecified, nil
+}
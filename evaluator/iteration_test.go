@@ -0,0 +1,66 @@
+package evaluator
+
+import "testing"
+
+// TestForRange は for-range が start から end の手前まで繰り返すこと（負の step を含む）をテストします。
+func TestForRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`(define sum (make-hash-table))
+			 (for-range (i 0 5) (hash-table-update! sum 'total (lambda (s) (+ s i)) 0))
+			 (hash-table-ref sum 'total)`,
+			"10",
+		},
+		{
+			`(define out (open-output-string))
+			 (for-range (i 10 0 -3) (write i out) (write-string " " out))
+			 (get-output-string out)`,
+			`"10 7 4 1 "`,
+		},
+		{
+			`(define out (open-output-string))
+			 (for-range (i 0 10 (+ 1 2)) (write i out))
+			 (get-output-string out)`,
+			`"0369"`,
+		},
+		// i に step を足すと int64 の範囲を超える場合も、あふれて繰り返し続けずに終わる
+		{
+			`(define out (open-output-string))
+			 (for-range (i 9223372036854775806 9223372036854775807 2) (write i out))
+			 (get-output-string out)`,
+			`"9223372036854775806"`,
+		},
+		{
+			`(define out (open-output-string))
+			 (for-range (i -9223372036854775806 -9223372036854775807 -3) (write i out))
+			 (get-output-string out)`,
+			`"-9223372036854775806"`,
+		},
+		{
+			`(define out (open-output-string))
+			 (for-range (i 1 10 9223372036854775807) (write i out))
+			 (get-output-string out)`,
+			`"1"`,
+		},
+		// 範囲が空なら本体は評価されない
+		{`(for-range (i 5 5) (error "not reached")) 'done`, "done"},
+		{`(for-range (i 0 5 -1) (error "not reached")) 'done`, "done"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	// 繰り返し回数が多くてもスタックを消費しない
+	if got := evalToString(t, NewGlobalEnv(), "(for-range (i 0 100000) i) 'ok"); got != "ok" {
+		t.Errorf("expected ok, got %s", got)
+	}
+	for _, input := range []string{"(for-range (i 0 5 0) i)", "(for-range (i 0 1.5) i)", "(for-range (1 0 5) 1)", "(for-range (i 0) i)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// optionalMarker は仮引数リストで、以降の仮引数を省略可能にする区切りです。
+//
+//	(lambda (required... #!optional optional...) body...)
+//
+// 省略可能な仮引数は name か (name default) で、呼び出しで省略された場合は default を、
+// default がなければ #f を束縛します。default は呼び出しのたびに、それより前の仮引数を束縛した環境で評価します。
+// 省略可能な仮引数はリストで分解できません。
+const optionalMarker = parser.Symbol("#!optional")
+
+// lambdaParams は仮引数リストの各要素が束縛可能なシンボルか、それを要素とする（ネストした）リストであることを確認して返します。
+// #!optional より後の要素は、シンボルか (name default) でなければなりません。
+// シンボルでもリストでもない要素があれば errMsg を、特殊フォーム名があれば form のエラーを返します。
+func lambdaParams(form, errMsg string, list []parser.Expr) ([]parser.Expr, error) {
+	params := make([]parser.Expr, 0, len(list))
+	optional := false
+	for _, param := range list {
+		if param == optionalMarker {
+			if optional {
+				return nil, fmt.Errorf("%s: duplicate %s", form, optionalMarker)
+			}
+			optional = true
+			params = append(params, param)
+			continue
+		}
+		if optional {
+			if _, _, err := optionalParam(form, param); err != nil {
+				return nil, err
+			}
+			params = append(params, param)
+			continue
+		}
+		switch p := param.(type) {
+		case parser.Symbol:
+			if err := checkBindable(form, p); err != nil {
+				return nil, err
+			}
+		case parser.List:
+			if _, err := lambdaParams(form, errMsg, p); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New(errMsg)
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// optionalParam は #!optional より後の仮引数 name か (name default) から、名前と省略時の値の式を返します。
+// default がない場合、省略時の値は #f です。
+func optionalParam(form string, param parser.Expr) (parser.Symbol, parser.Expr, error) {
+	var name parser.Expr = param
+	var init parser.Expr = parser.Boolean(false)
+	if list, ok := param.(parser.List); ok {
+		if len(list) != 2 {
+			return "", nil, fmt.Errorf("%s: optional parameter must be name or (name default), got %s", form, WriteString(param))
+		}
+		name, init = list[0], list[1]
+	}
+	sym, ok := name.(parser.Symbol)
+	if !ok {
+		return "", nil, fmt.Errorf("%s: optional parameter must be name or (name default), got %s", form, WriteString(param))
+	}
+	if err := checkBindable(form, sym); err != nil {
+		return "", nil, err
+	}
+	return sym, init, nil
+}
+
+// lambdaBody は lambda や関数定義の本体部分を取り出します。
+// 本体が複数の式からなる場合は、それらを順に評価する (begin expr...) にまとめます。
+func lambdaBody(exp parser.List) parser.Expr {
+	if len(exp) == 3 {
+		return exp[2]
+	}
+	return append(parser.List{parser.Symbol("begin")}, exp[2:]...)
+}
@@ -0,0 +1,50 @@
+package evaluator
+
+import "github.com/Warashi/lispish/parser"
+
+// letBody は (let ((var init) ...) body...) と let* の束縛を作り、その環境と body を返します。
+// body の評価は呼び出し側の eval が行います。
+// sequential が偽（let）の場合はすべての init を外側の環境で評価してから新しい環境に束縛するため、
+// どの init からも他の変数は参照できません。真（let*）の場合は束縛ごとに環境を作って順に束縛するため、
+// 前の変数は後の init から参照でき、同じ名前を束縛し直すこともできます。
+func letBody(exp parser.List, env *Env, sequential bool) ([]parser.Expr, *Env, error) {
+	form := "let"
+	scope := scopeParallel
+	if sequential {
+		form, scope = "let*", scopeSequential
+	}
+	specs, names, inits, err := parseBindings(form, exp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env.warnUnusedBindings(form, specs, exp[2:], scope)
+	if sequential {
+		for i, init := range inits {
+			val, err := evalExpr(init, env)
+			if err != nil {
+				return nil, nil, err
+			}
+			env = NewEnv(env)
+			env.Set(names[i], val)
+		}
+		// 束縛がなくても body は新しい環境で評価する
+		if len(inits) == 0 {
+			env = NewEnv(env)
+		}
+		return exp[2:], env, nil
+	}
+	values := make([]parser.Expr, len(inits))
+	for i, init := range inits {
+		val, err := evalExpr(init, env)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = val
+	}
+	newEnv := NewEnv(env)
+	for i, name := range names {
+		newEnv.Set(name, values[i])
+	}
+	return exp[2:], newEnv, nil
+}
@@ -0,0 +1,36 @@
+package evaluator
+
+import "testing"
+
+// TestLet は let がすべての init を外側の環境で評価してから束縛し、
+// let* が前の変数を後の init から参照できるように順に束縛することをテストします。
+func TestLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(let ((x 1) (y 2)) (+ x y))", "3"},
+		{"(let* ((x 1) (y (+ x 1))) (list x y))", "(1 2)"},
+		// let の init は外側の変数を参照する
+		{"(define x 10) (let ((x 1) (y x)) (list x y))", "(1 10)"},
+		{"(define x 10) (let* ((x 1) (y x)) (list x y))", "(1 1)"},
+		{"(let* ((x 1) (x (+ x 1))) x)", "2"},
+		// 変数は let の内側だけで有効
+		{"(define x 'outer) (let ((x 'inner)) x) x", "outer"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{"(let () 1 2 3)", "3"},
+		{"(let* () (define y 5) y)", "5"},
+		// 束縛ごとの値を捕捉したクロージャ
+		{"(define fs (let* ((a 1) (f (lambda () a)) (a 2)) (list f a))) (list ((list-ref fs 0)) (list-ref fs 1))", "(1 2)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(let)", "(let ((x 1)))", "(let (x) x)", "(let ((1 2)) 1)", "(let* ((x)) x)", "(let ((if 1)) 1)", "(let x 1)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
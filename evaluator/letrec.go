@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// unassignedValue は letrec の変数に初期値を代入するまでの間、束縛しておく仮の値です。
+// 初期化の途中でこの値を参照すると、未規定の値を黙って使う代わりに lookupSymbol がエラーにします。
+// クロージャが変数を捕捉するだけであれば参照は呼び出し時まで起きないため、相互再帰は問題なく定義できます。
+type unassignedValue struct {
+	form string
+}
+
+// letrecBody は (letrec ((var init) ...) body...) と letrec* の束縛を作り、その環境と body を返します。
+// body の評価は呼び出し側の eval が行います。
+// 新しい環境にすべての変数を仮の値で束縛してから、その環境で init を順に評価します。
+// sequential が偽（letrec）の場合はすべての init を評価し終えてから代入するため、
+// どの init からも他の変数の値は参照できません。真（letrec*）の場合は init を評価するたびに代入するため、
+// 前の変数の値は後の init から参照できます。
+func letrecBody(exp parser.List, env *Env, sequential bool) ([]parser.Expr, *Env, error) {
+	form := "letrec"
+	if sequential {
+		form = "letrec*"
+	}
+	specs, names, inits, err := parseBindings(form, exp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env.warnUnusedBindings(form, specs, exp[2:], scopeRecursive)
+	newEnv := NewEnv(env)
+	for _, name := range names {
+		newEnv.Set(name, unassignedValue{form: form})
+	}
+	values := make([]parser.Expr, len(inits))
+	for i, init := range inits {
+		val, err := evalExpr(init, newEnv)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sequential {
+			newEnv.Set(names[i], val)
+		}
+		values[i] = val
+	}
+	if !sequential {
+		for i, name := range names {
+			newEnv.Set(name, values[i])
+		}
+	}
+	return exp[2:], newEnv, nil
+}
+
+// parseBindings は (form ((var init) ...) body...) の束縛の並び specs を検査し、変数と init を順に返します。
+// let、let*、letrec、letrec* で共有します。
+func parseBindings(form string, exp parser.List) (specs parser.List, names []parser.Symbol, inits []parser.Expr, err error) {
+	if len(exp) < 3 {
+		return nil, nil, nil, fmt.Errorf("%s: too few arguments", form)
+	}
+	specs, ok := exp[1].(parser.List)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%s: bindings must be a list", form)
+	}
+	names = make([]parser.Symbol, 0, len(specs))
+	inits = make([]parser.Expr, 0, len(specs))
+	for _, spec := range specs {
+		pair, ok := spec.(parser.List)
+		if !ok || len(pair) != 2 {
+			return nil, nil, nil, fmt.Errorf("%s: each binding must be (var init), got %s", form, WriteString(spec))
+		}
+		name, ok := pair[0].(parser.Symbol)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%s: variable must be a symbol, got %s", form, WriteString(pair[0]))
+		}
+		if err := checkBindable(form, name); err != nil {
+			return nil, nil, nil, err
+		}
+		names = append(names, name)
+		inits = append(inits, pair[1])
+	}
+	return specs, names, inits, nil
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLetrec は letrec と letrec* で相互再帰する手続きを定義できること、
+// letrec* では前の変数の値を後の初期化式から参照できることをテストします。
+func TestLetrec(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(letrec ((ev? (lambda (n) (match n (0 #t) (_ (od? (- n 1))))))
+		           (od? (lambda (n) (match n (0 #f) (_ (ev? (- n 1)))))))
+		   (list (ev? 10) (od? 7) (ev? 3)))`, "(#t #t #f)"},
+		{"(letrec* ((a 1) (b (+ a 1))) (list a b))", "(1 2)"},
+		{"(letrec ((x 5)) x)", "5"},
+		// 変数は letrec の内側だけで有効
+		{"(define x 'outer) (letrec ((x 'inner)) x) x", "outer"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestLetrecUseBeforeDefinition は初期化式が未初期化の変数を直接参照した場合に、
+// 仮の値を漏らさず、どの変数かを示すエラーになることをテストします。
+func TestLetrecUseBeforeDefinition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(letrec ((x (+ y 1)) (y 2)) x)", "letrec: variable y used before its definition"},
+		{"(letrec* ((x (+ y 1)) (y 2)) x)", "letrec*: variable y used before its definition"},
+		// letrec ではすべての初期化が済むまで代入しないため、前の変数も参照できない
+		{"(letrec ((a 1) (b (+ a 1))) b)", "letrec: variable a used before its definition"},
+		// 自分自身の初期化式で自分を呼び出す
+		{"(letrec ((f (f))) f)", "letrec: variable f used before its definition"},
+	}
+	for _, tt := range tests {
+		_, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tt.input)
+			continue
+		}
+		if got := innermostError(err).Error(); got != tt.expected {
+			t.Errorf("%s: expected error %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(letrec (x 1) x)", "(letrec ((1 2)) 1)", "(letrec ((define 1)) 1)", "(letrec ((x 1)))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil || strings.Contains(err.Error(), "used before") {
+			t.Errorf("%s: expected syntax error, got %v", input, err)
+		}
+	}
+}
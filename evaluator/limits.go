@@ -0,0 +1,33 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// DefaultMaxArgs は SetMaxArgs で設定しない場合の、1回の手続き呼び出しに渡せる引数の最大数です。
+const DefaultMaxArgs = 1 << 20
+
+// SetMaxArgs は1回の手続き呼び出し（apply で展開した引数を含む）に渡せる引数の最大数を設定します。
+// 設定はこの環境と、以後この環境の内側に作られる環境に適用されます。n が 0 以下なら DefaultMaxArgs に戻します。
+// 信頼できない入力を評価する場合に、巨大な引数リストによるメモリの消費を抑えるために使います。
+func (env *Env) SetMaxArgs(n int) {
+	env.maxArgs = n
+}
+
+// argLimit は環境で有効な引数の最大数を返します。
+func (env *Env) argLimit() int {
+	if env.maxArgs > 0 {
+		return env.maxArgs
+	}
+	return DefaultMaxArgs
+}
+
+// checkArgCount は opForm を演算子とする呼び出しの引数の数 n が上限を超えていればエラーを返します。
+func (env *Env) checkArgCount(opForm parser.Expr, n int) error {
+	if limit := env.argLimit(); n > limit {
+		return fmt.Errorf("too many arguments: %d exceeds the limit of %d (operator %s)", n, limit, WriteString(opForm))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestMaxArgs は SetMaxArgs で設定した上限を超える引数の呼び出しが、apply による展開を含めてエラーになることをテストします。
+func TestMaxArgs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(+ 1 2 3 4)", "too many arguments: 4 exceeds the limit of 3 (operator +)"},
+		{"(apply + '(1 2 3 4))", "apply: too many arguments: 4 exceeds the limit of 3"},
+		{"(apply + 1 '(2 3 4))", "apply: too many arguments: 4 exceeds the limit of 3"},
+		// 上限は手続きの中で作られる環境にも引き継がれる
+		{"(define (f xs) (apply list xs)) (f (tabulate 10 (lambda (i) i)))", "apply: too many arguments: 10 exceeds the limit of 3"},
+	}
+	for _, tt := range tests {
+		env := NewGlobalEnv()
+		env.SetMaxArgs(3)
+		if _, err := evalString(t, env, tt.input); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+		exprs, err := parser.NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll error: %v", err)
+		}
+		env = NewGlobalEnv()
+		env.SetMaxArgs(3)
+		if _, err := evalCompiled(exprs, env); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: compiled: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	env := NewGlobalEnv()
+	env.SetMaxArgs(3)
+	if got := evalToString(t, env, "(list (+ 1 2 3) (apply + 1 '(2 3)))"); got != "(6 6)" {
+		t.Errorf("expected (6 6), got %s", got)
+	}
+	// 0 を設定すると既定の上限に戻る
+	env.SetMaxArgs(0)
+	if got := evalToString(t, env, "(apply + (tabulate 100 (lambda (i) 1)))"); got != "100" {
+		t.Errorf("expected 100, got %s", got)
+	}
+}
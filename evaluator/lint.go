@@ -0,0 +1,252 @@
+package evaluator
+
+import "github.com/Warashi/lispish/parser"
+
+// WarnFunc はリント用の警告を受け取るコールバックです。
+// form は警告の原因となったソース上の式です。AST は位置を持たないため、span には form を含む
+// トップレベルの式のソース上の範囲を渡します。EvalAt 以外で評価した場合の span はゼロ値です。
+type WarnFunc func(span parser.Span, form parser.Expr, format string, args ...any)
+
+// SetWarnf はリント警告のコールバックを設定します。nil の場合（既定）は警告を出しません。
+func (env *Env) SetWarnf(warnf WarnFunc) {
+	env.root().warnf = warnf
+}
+
+// EvalAt は ParseExprAt で読んだトップレベルの式 expr を Eval と同じく評価します。
+// 評価中のリント警告には、その式のソース上の範囲 span を渡します。
+func EvalAt(expr parser.Expr, span parser.Span, env *Env) (parser.Expr, error) {
+	root := env.root()
+	saved := root.warnSpan
+	root.warnSpan = span
+	defer func() { root.warnSpan = saved }()
+	return Eval(expr, env)
+}
+
+// warn はコールバックが設定されていれば警告を通知します。
+func (env *Env) warn(form parser.Expr, format string, args ...any) {
+	root := env.root()
+	if root.warnf != nil {
+		root.warnf(root.warnSpan, form, format, args...)
+	}
+}
+
+// warnShadowing は define による束縛が組み込み関数や外側の束縛を隠す場合に警告します。
+func (env *Env) warnShadowing(name parser.Symbol, form parser.Expr) {
+	if env.root().warnf == nil {
+		return
+	}
+	if val, ok := env.Get(name); ok {
+		if _, isBuiltin := val.(*Builtin); isBuiltin {
+			env.warn(form, "define: %s shadows a builtin", name)
+			return
+		}
+	}
+	if _, local := env.lookup(name); !local && env.outer != nil {
+		if _, ok := env.outer.Get(name); ok {
+			env.warn(form, "define: %s shadows an outer binding", name)
+		}
+	}
+}
+
+// bindingScope は束縛の変数を、他の束縛の初期化式のうちどれから参照できるかの種類です。
+type bindingScope int
+
+const (
+	// scopeParallel（let）では、どの初期化式からも参照できません。
+	scopeParallel bindingScope = iota
+	// scopeSequential（let*）では、後の束縛の初期化式から参照できます。
+	scopeSequential
+	// scopeRecursive（letrec と letrec*）では、自分以外のすべての初期化式から参照できます。
+	scopeRecursive
+)
+
+// warnUnusedBindings は let や letrec などの束縛 specs のうち、変数が body からも、
+// scope に従って参照できる他の束縛の初期化式からも参照されないものを警告します。警告の form はその束縛 (var init) です。
+// 自分自身の初期化式からの参照（再帰）だけでは使われたとみなしません。
+// let* で後の束縛が同じ名前を束縛し直した場合、それより後の初期化式と body からは参照できません。
+func (env *Env) warnUnusedBindings(form string, specs parser.List, body []parser.Expr, scope bindingScope) {
+	if env.root().warnf == nil {
+		return
+	}
+	for i, spec := range specs {
+		name := spec.(parser.List)[0].(parser.Symbol)
+		used, visible := false, true
+		for j, other := range specs {
+			pair := other.(parser.List)
+			sees := (scope == scopeRecursive && j != i) || (scope == scopeSequential && j > i && visible)
+			if sees && !used {
+				used = referencesSymbol(pair[1], name)
+			}
+			if scope == scopeSequential && j > i && pair[0] == name {
+				visible = false
+			}
+		}
+		if !used && visible {
+			used = referencesAny(body, name)
+		}
+		if !used {
+			env.warn(spec, "%s: %s is never referenced", form, name)
+		}
+	}
+}
+
+// referencesSymbol は式 expr が変数 name を参照するかを判定します。
+// quote の中身は参照とみなさず、lambda の仮引数や match のパターン変数などで name が束縛し直される範囲は除きます。
+func referencesSymbol(expr parser.Expr, name parser.Symbol) bool {
+	switch e := expr.(type) {
+	case parser.Symbol:
+		return e == name
+	case parser.List:
+		if len(e) == 0 {
+			return false
+		}
+		head, _ := e[0].(parser.Symbol)
+		switch {
+		case head == "quote":
+			return false
+		case head == "lambda" && len(e) >= 2:
+			return paramsReference(e[1], e[2:], name)
+		case head == "define" && len(e) >= 2:
+			// (define (fun params...) body...) の仮引数は本体の中だけで有効
+			if sig, ok := e[1].(parser.List); ok && len(sig) > 0 {
+				return paramsReference(sig[1:], e[2:], name)
+			}
+			return referencesAny(e[2:], name)
+		case head == "let" && len(e) >= 2:
+			// 初期化式は外側で評価し、変数は body の中だけで有効
+			specs, _ := e[1].(parser.List)
+			bound := false
+			for _, spec := range specs {
+				if pair, ok := spec.(parser.List); ok && len(pair) > 0 {
+					if referencesAny(pair[1:], name) {
+						return true
+					}
+					bound = bound || pair[0] == name
+				}
+			}
+			return !bound && referencesAny(e[2:], name)
+		case head == "let*" && len(e) >= 2:
+			// 変数はそれより後の初期化式と body の中で有効
+			specs, _ := e[1].(parser.List)
+			for _, spec := range specs {
+				if pair, ok := spec.(parser.List); ok && len(pair) > 0 {
+					if referencesAny(pair[1:], name) {
+						return true
+					}
+					if pair[0] == name {
+						return false
+					}
+				}
+			}
+			return referencesAny(e[2:], name)
+		case (head == "letrec" || head == "letrec*") && len(e) >= 2:
+			specs, _ := e[1].(parser.List)
+			for _, spec := range specs {
+				if pair, ok := spec.(parser.List); ok && len(pair) > 0 && pair[0] == name {
+					return false
+				}
+			}
+			return referencesSymbol(e[1], name) || referencesAny(e[2:], name)
+		case head == "receive" && len(e) >= 3:
+			return referencesSymbol(e[2], name) || (!bindsSymbol(e[1], name) && referencesAny(e[3:], name))
+		case head == "match" && len(e) >= 2:
+			if referencesSymbol(e[1], name) {
+				return true
+			}
+			for _, clause := range e[2:] {
+				if c, ok := clause.(parser.List); ok && len(c) > 0 && !bindsSymbol(c[0], name) && referencesAny(c[1:], name) {
+					return true
+				}
+			}
+			return false
+		case head == "case" && len(e) >= 2:
+			// 節の先頭にある datum のリストは quote と同じく参照ではない
+			if referencesSymbol(e[1], name) {
+				return true
+			}
+			for _, clause := range e[2:] {
+				if c, ok := clause.(parser.List); ok && len(c) > 0 && referencesAny(c[1:], name) {
+					return true
+				}
+			}
+			return false
+		case head == "guard" && len(e) >= 2:
+			// (guard (var clause...) body...) の var は clause の中だけで有効
+			if referencesAny(e[2:], name) {
+				return true
+			}
+			spec, ok := e[1].(parser.List)
+			return ok && len(spec) > 0 && spec[0] != name && referencesAny(spec[1:], name)
+		case head == "for-range" && len(e) >= 2:
+			// (for-range (var start end [step]) body...) の var は body の中だけで有効
+			spec, ok := e[1].(parser.List)
+			if !ok || len(spec) == 0 {
+				return referencesAny(e[1:], name)
+			}
+			return referencesAny(spec[1:], name) || (spec[0] != name && referencesAny(e[2:], name))
+		}
+		return referencesAny(e, name)
+	}
+	return false
+}
+
+// referencesAny は exprs のいずれかが変数 name を参照するかを判定します。
+func referencesAny(exprs []parser.Expr, name parser.Symbol) bool {
+	for _, expr := range exprs {
+		if referencesSymbol(expr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramsReference は仮引数 params と本体 body を持つ手続きが、外側の変数 name を参照するかを判定します。
+// #!optional の (name default) の default は、それより前の仮引数だけを束縛した環境で評価されるため、
+// name を束縛する仮引数より前にある default の参照は外側の name への参照です。
+func paramsReference(params parser.Expr, body []parser.Expr, name parser.Symbol) bool {
+	list, ok := params.(parser.List)
+	if !ok {
+		return !bindsSymbol(params, name) && referencesAny(body, name)
+	}
+	optional := false
+	for _, param := range list {
+		if param == optionalMarker {
+			optional = true
+			continue
+		}
+		if spec, ok := param.(parser.List); ok && optional && len(spec) == 2 {
+			if referencesSymbol(spec[1], name) {
+				return true
+			}
+			param = spec[0]
+		}
+		if bindsSymbol(param, name) {
+			return false
+		}
+	}
+	return referencesAny(body, name)
+}
+
+// bindsSymbol は仮引数やパターン params が name を束縛するかを判定します。
+// #!optional より後の (name default) は name だけを束縛し、default は束縛とみなしません。
+func bindsSymbol(params parser.Expr, name parser.Symbol) bool {
+	switch p := params.(type) {
+	case parser.Symbol:
+		return p == name
+	case parser.List:
+		optional := false
+		for _, elem := range p {
+			if elem == optionalMarker {
+				optional = true
+				continue
+			}
+			if spec, ok := elem.(parser.List); ok && optional && len(spec) == 2 {
+				elem = spec[0]
+			}
+			if bindsSymbol(elem, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// recordedWarning は記録された警告です。
+type recordedWarning struct {
+	span    parser.Span
+	form    parser.Expr
+	message string
+}
+
+// recordWarnings は警告を記録するコールバックを環境に設定し、記録先を返します。
+func recordWarnings(env *Env) *[]recordedWarning {
+	var warnings []recordedWarning
+	env.SetWarnf(func(span parser.Span, form parser.Expr, format string, args ...any) {
+		warnings = append(warnings, recordedWarning{span: span, form: form, message: fmt.Sprintf(format, args...)})
+	})
+	return &warnings
+}
+
+// TestWarnfShadowingBuiltin は組み込み関数を define で隠すと警告が記録されることをテストします。
+func TestWarnfShadowingBuiltin(t *testing.T) {
+	env := NewGlobalEnv()
+	warnings := recordWarnings(env)
+	if _, err := evalString(t, env, "(define + 1)"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if len(*warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %d", len(*warnings))
+	}
+	w := (*warnings)[0]
+	if !strings.Contains(w.message, "shadows a builtin") {
+		t.Errorf("unexpected warning message: %q", w.message)
+	}
+	if got := WriteString(w.form); got != "(define + 1)" {
+		t.Errorf("expected warning form (define + 1), got %s", got)
+	}
+}
+
+// TestWarnfShadowingOuterBinding は外側の束縛を隠す define のみが警告されることをテストします。
+func TestWarnfShadowingOuterBinding(t *testing.T) {
+	env := NewGlobalEnv()
+	warnings := recordWarnings(env)
+	input := `
+	(define x 1)
+	(define x 2)
+	(define (f) (define x 3))
+	(f)
+	`
+	if _, err := evalString(t, env, input); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if len(*warnings) != 1 || !strings.Contains((*warnings)[0].message, "x shadows an outer binding") {
+		t.Errorf("expected a single outer-binding warning, got %v", *warnings)
+	}
+}
+
+// TestWarnfDisabledByDefault はコールバック未設定時に警告が出ない（エラーにもならない）ことをテストします。
+func TestWarnfDisabledByDefault(t *testing.T) {
+	if _, err := evalString(t, NewGlobalEnv(), "(define + 1)"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+}
+
+// TestWarnfUnusedLetrecBindings は letrec と letrec* の束縛のうち、参照されない変数だけが警告されることをテストします。
+// 内側で束縛し直された同じ名前の参照や、quote の中のシンボルは参照とみなしません。
+func TestWarnfUnusedLetrecBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"(letrec ((used 1) (unused 2)) used)", []string{"(unused 2): letrec: unused is never referenced"}},
+		{"(letrec* ((a 1) (b (+ a 1))) b)", nil},
+		// 相互再帰で他の束縛から参照される変数は使われている
+		{`(letrec ((ev? (lambda (n) (match n (0 #t) (_ (od? (- n 1))))))
+		           (od? (lambda (n) (match n (0 #f) (_ (ev? (- n 1)))))))
+		   (ev? 4))`, nil},
+		// 自分自身からの参照だけでは使われていない
+		{"(letrec ((loop (lambda (n) (loop n)))) 1)", []string{"(loop (lambda (n) (loop n))): letrec: loop is never referenced"}},
+		{"(letrec ((x 1)) ((lambda (x) x) 2))", []string{"(x 1): letrec: x is never referenced"}},
+		{"(letrec ((x 1)) (match 2 (x x)))", []string{"(x 1): letrec: x is never referenced"}},
+		{"(letrec ((x 1)) 'x)", []string{"(x 1): letrec: x is never referenced"}},
+		{"(letrec ((x 1)) (case 2 ((x) 'a)))", []string{"(x 1): letrec: x is never referenced"}},
+		// #!optional の省略時の値は外側の変数を参照する
+		{"(letrec ((x 1)) ((lambda (#!optional (y x)) y)))", nil},
+		{"(letrec ((x 1)) (define (f #!optional (y x)) y) (f))", nil},
+		{"(letrec ((x 1)) ((lambda (x #!optional (y x)) y) 2))", []string{"(x 1): letrec: x is never referenced"}},
+		{"(letrec ((y 1)) ((lambda (#!optional (y 2)) y)))", []string{"(y 1): letrec: y is never referenced"}},
+		{"(letrec ((x 1)) (match 2 (y (list x y))))", nil},
+		{"(letrec* ((x 1) (y 2)) (receive (y) (values x) y))", []string{"(y 2): letrec*: y is never referenced"}},
+	}
+	for _, tt := range tests {
+		env := NewGlobalEnv()
+		warnings := recordWarnings(env)
+		if _, err := evalString(t, env, tt.input); err != nil {
+			t.Fatalf("%s: EvalAll error: %v", tt.input, err)
+		}
+		var got []string
+		for _, w := range *warnings {
+			got = append(got, WriteString(w.form)+": "+w.message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected warnings %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestWarnfUnusedLetBindings は let と let* の束縛のうち、参照されない変数だけが警告されることをテストします。
+// let の変数は他の束縛の初期化式からは参照できず、let* の変数は後の初期化式から参照できます。
+func TestWarnfUnusedLetBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"(let ((used 1) (unused 2)) used)", []string{"(unused 2): let: unused is never referenced"}},
+		{"(let ((a 1) (b 2)) (+ a b))", nil},
+		{"(let* ((a 1) (b (+ a 1))) b)", nil},
+		{"(let* ((a 1) (b 2)) a)", []string{"(b 2): let*: b is never referenced"}},
+		// let* で束縛し直された変数は、それより後から参照できない
+		{"(let* ((x 1) (x 2)) x)", []string{"(x 1): let*: x is never referenced"}},
+		{"(let* ((x 1) (x (+ x 1))) x)", nil},
+		// 内側の let で束縛し直された同じ名前の参照は、外側の変数への参照ではない
+		{"(let ((x 1)) (let ((x 2)) x))", []string{"(x 1): let: x is never referenced"}},
+		{"(let ((x 1)) (let ((y x)) y))", nil},
+		{"(let ((x 1)) (let* ((y 2) (x y)) x))", []string{"(x 1): let: x is never referenced"}},
+		{"(letrec ((x 1)) (let ((x 2)) x))", []string{"(x 1): letrec: x is never referenced"}},
+	}
+	for _, tt := range tests {
+		env := NewGlobalEnv()
+		warnings := recordWarnings(env)
+		if _, err := evalString(t, env, tt.input); err != nil {
+			t.Fatalf("%s: EvalAll error: %v", tt.input, err)
+		}
+		var got []string
+		for _, w := range *warnings {
+			got = append(got, WriteString(w.form)+": "+w.message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected warnings %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestWarnfSpan は EvalAt で評価したトップレベルの式の範囲が、その中で出た警告に渡されることをテストします。
+func TestWarnfSpan(t *testing.T) {
+	env := NewGlobalEnv()
+	warnings := recordWarnings(env)
+	p := parser.NewParser(strings.NewReader("(define x 1)\n  (let ((unused 2))\n    x)\n(define + 1)"))
+	var spans []parser.Span
+	for {
+		expr, span, err := p.ParseExprAt()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ParseExprAt error: %v", err)
+		}
+		spans = append(spans, span)
+		if _, err := EvalAt(expr, span, env); err != nil {
+			t.Fatalf("EvalAt error: %v", err)
+		}
+	}
+	if len(*warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", *warnings)
+	}
+	for i, expected := range []parser.Span{spans[1], spans[2]} {
+		if got := (*warnings)[i].span; got != expected {
+			t.Errorf("warning %d: expected span %v, got %v", i, expected, got)
+		}
+	}
+	if start := (*warnings)[0].span.Start; start.Line != 2 || start.Column != 3 {
+		t.Errorf("expected the let warning at line 2 col 3, got line %d col %d", start.Line, start.Column)
+	}
+	// EvalAt の外で出た警告の範囲はゼロ値
+	if _, err := evalString(t, env, "(let ((y 1)) 2)"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	if got := (*warnings)[2].span; got != (parser.Span{}) {
+		t.Errorf("expected a zero span outside EvalAt, got %v", got)
+	}
+}
@@ -0,0 +1,990 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// isEqual は equal? の意味で2つの値が等しいかを判定します。
+// リストとベクタは要素ごとに再帰的に比較し、分数は値で、それ以外の比較可能な値は == で、
+// 比較できない値（手続きなど）は同一性で比較します。
+// データラベルや list-set! で作った循環するリストも、比較中のリストの組を記録することで停止します。
+func isEqual(a, b parser.Expr) bool {
+	return equalSeen(a, b, nil)
+}
+
+// seenPair は比較中の2つのリスト（listIdentity）またはベクタ（*Vector）の組です。
+type seenPair struct {
+	a, b any
+}
+
+// equalSeen は isEqual の本体です。seen は比較を始めたリストやベクタの組で、同じ組に再び出会ったら
+// その組は（他の要素で違いが見つからない限り）等しいとみなします。seen は必要になってから作ります。
+func equalSeen(a, b parser.Expr, seen map[seenPair]bool) bool {
+	switch va := a.(type) {
+	case parser.List:
+		vb, ok := b.(parser.List)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		if len(va) == 0 {
+			return true
+		}
+		return equalElems(seenPair{identityOf(va), identityOf(vb)}, va, vb, seen)
+	case *Vector:
+		vb, ok := b.(*Vector)
+		if !ok || len(va.Elems) != len(vb.Elems) {
+			return false
+		}
+		return equalElems(seenPair{va, vb}, va.Elems, vb.Elems, seen)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	if ra, ok := a.(parser.Rational); ok {
+		rb, ok := b.(parser.Rational)
+		return ok && ra.Equal(rb)
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// equalElems は pair の2つのリストまたはベクタの要素 ea と eb を順に比較します。
+func equalElems(pair seenPair, ea, eb []parser.Expr, seen map[seenPair]bool) bool {
+	if seen[pair] {
+		return true
+	}
+	if seen == nil {
+		seen = make(map[seenPair]bool)
+	}
+	seen[pair] = true
+	for i := range ea {
+		if !equalSeen(ea[i], eb[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// listIdentity はリストの同一性を表します。リストは Go のスライスなので、先頭要素のアドレスと長さで区別します。
+type listIdentity struct {
+	head *parser.Expr
+	len  int
+}
+
+// identityOf は空でないリストの同一性を返します。
+func identityOf(list parser.List) listIdentity {
+	return listIdentity{head: &list[0], len: len(list)}
+}
+
+// checkAcyclic は式の評価される位置に循環するリストがないことを確認します。
+// データラベルで #0=(list #0#) のように書いた式は、評価すると終わらずに Go のスタックを使い果たすため、
+// 評価の前にエラーにします。quote の中身は評価しないため循環していてもかまいません。
+// 共有されているだけの部分式は一度だけ調べます。
+func checkAcyclic(expr parser.Expr) error {
+	if _, ok := expr.(parser.List); !ok {
+		return nil
+	}
+	onPath := make(map[listIdentity]bool)
+	done := make(map[listIdentity]bool)
+	var walk func(expr parser.Expr) error
+	walk = func(expr parser.Expr) error {
+		list, ok := expr.(parser.List)
+		if !ok || len(list) == 0 || list[0] == parser.Symbol("quote") {
+			return nil
+		}
+		id := identityOf(list)
+		if onPath[id] {
+			return fmt.Errorf("cannot evaluate a cyclic expression")
+		}
+		if done[id] {
+			return nil
+		}
+		onPath[id] = true
+		for _, elem := range list {
+			if err := walk(elem); err != nil {
+				return err
+			}
+		}
+		delete(onPath, id)
+		done[id] = true
+		return nil
+	}
+	return walk(expr)
+}
+
+// isEq は eq? の意味で2つの値が同一かを判定します。シンボルや整数などの比較可能な値は == で比較し、
+// リストは要素を比較せず、同じリスト（#1=(a b) #1# のように共有されたものなど）か、どちらも空リストの場合だけ同一とみなします。
+func isEq(a, b parser.Expr) bool {
+	if la, ok := a.(parser.List); ok {
+		lb, ok := b.(parser.List)
+		if !ok || len(la) != len(lb) {
+			return false
+		}
+		return len(la) == 0 || identityOf(la) == identityOf(lb)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// listArg は args[i] がリストであることを確認して返します。
+func listArg(name string, args []parser.Expr, i int) (parser.List, error) {
+	list, ok := args[i].(parser.List)
+	if !ok {
+		return nil, fmt.Errorf("%s: argument %d must be a list, got %T", name, i+1, args[i])
+	}
+	return list, nil
+}
+
+// procArg は args[i] が手続きであることを確認して返します。
+func procArg(name string, args []parser.Expr, i int) (Callable, error) {
+	proc, ok := args[i].(Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s: argument %d must be a procedure, got %T", name, i+1, args[i])
+	}
+	return proc, nil
+}
+
+// alistEntry は連想リストの要素がキーを先頭に持つリストであることを確認して返します。
+// 連想リストの各要素は (key value ...) の形のリストで表します。
+func alistEntry(name string, entry parser.Expr) (parser.List, error) {
+	pair, ok := entry.(parser.List)
+	if !ok || len(pair) == 0 {
+		return nil, fmt.Errorf("%s: association list entries must be non-empty lists, got %v", name, WriteString(entry))
+	}
+	return pair, nil
+}
+
+// builtinEqual は "equal?" を実装します。
+func builtinEqual(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("equal?: wrong number of arguments")
+	}
+	return parser.Boolean(isEqual(args[0], args[1])), nil
+}
+
+// builtinEq は "eq?" を実装します。
+func builtinEq(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("eq?: wrong number of arguments")
+	}
+	return parser.Boolean(isEq(args[0], args[1])), nil
+}
+
+// builtinList は "list" を実装します。
+// (list elem...) 引数を要素とする新しいリストを返します。
+func builtinList(args []parser.Expr) (parser.Expr, error) {
+	return append(parser.List{}, args...), nil
+}
+
+// builtinListStar は "list*"（別名 "cons*"）を実装します。
+// (list* elem... tail) 最後以外の引数を tail の前に並べたリストを返します。入れ子の cons と同じ結果です。
+// tail がリストでなければ、リーダーと同じく "." を挟んだドット対のリスト (1 2 . 3) になります。
+// 引数が tail だけの場合は tail をそのまま返します。
+func builtinListStar(args []parser.Expr) (parser.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("list*: wrong number of arguments")
+	}
+	heads, tail := args[:len(args)-1], args[len(args)-1]
+	if len(heads) == 0 {
+		return tail, nil
+	}
+	result := append(parser.List{}, heads...)
+	if list, ok := tail.(parser.List); ok {
+		return append(result, list...), nil
+	}
+	return append(result, parser.Symbol("."), tail), nil
+}
+
+// builtinListSet は "list-set!" を実装します。
+// (list-set! list k val) k 番目（0 始まり）の要素をその場で val に置き換えます。
+// リストは要素を共有するため、同じリストを参照している他の束縛からも変更が見えます。
+func builtinListSet(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("list-set!: wrong number of arguments")
+	}
+	list, err := listArg("list-set!", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := indexArg("list-set!", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if k >= len(list) {
+		return nil, fmt.Errorf("list-set!: index %d out of range for length %d", k, len(list))
+	}
+	list[k] = args[2]
+	return Unspecified, nil
+}
+
+// builtinListRef は "list-ref" を実装します。
+// (list-ref list k) k 番目（0 始まり）の要素を返します。
+func builtinListRef(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("list-ref: wrong number of arguments")
+	}
+	list, err := listArg("list-ref", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := indexArg("list-ref", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if k >= len(list) {
+		return nil, fmt.Errorf("list-ref: index %d out of range for length %d", k, len(list))
+	}
+	return list[k], nil
+}
+
+// builtinDelAssoc は "del-assoc" を実装します。
+// (del-assoc key alist) キーが equal? で等しい要素を取り除いた新しい連想リストを返します。
+func builtinDelAssoc(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("del-assoc: wrong number of arguments")
+	}
+	alist, err := listArg("del-assoc", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	result := parser.List{}
+	for _, entry := range alist {
+		pair, err := alistEntry("del-assoc", entry)
+		if err != nil {
+			return nil, err
+		}
+		if !isEqual(pair[0], args[0]) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// builtinAlistUpdate は "alist-update" を実装します。
+// (alist-update key val alist) 最初に見つかったキーの要素を (key val) に置き換えた新しい連想リストを返します。
+// キーが存在しない場合は末尾に (key val) を追加します。
+func builtinAlistUpdate(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("alist-update: wrong number of arguments")
+	}
+	alist, err := listArg("alist-update", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	updated := parser.List{args[0], args[1]}
+	result := make(parser.List, 0, len(alist)+1)
+	found := false
+	for _, entry := range alist {
+		pair, err := alistEntry("alist-update", entry)
+		if err != nil {
+			return nil, err
+		}
+		if !found && isEqual(pair[0], args[0]) {
+			result = append(result, updated)
+			found = true
+			continue
+		}
+		result = append(result, entry)
+	}
+	if !found {
+		result = append(result, updated)
+	}
+	return result, nil
+}
+
+// builtinMergeAlists は "merge-alists" を実装します。
+// (merge-alists alist...) 連想リストを1つにまとめます。同じキー（equal? で比較）の要素は
+// 後の連想リストのものが優先されます。キーは最初に現れた位置の順に並びます。
+// 各連想リストの中で同じキーが重なる場合は、assoc と同じく最初の要素をそのリストの値とみなします。
+func builtinMergeAlists(args []parser.Expr) (parser.Expr, error) {
+	result := parser.List{}
+	positions := NewHashTable()
+	for i := range args {
+		alist, err := listArg("merge-alists", args, i)
+		if err != nil {
+			return nil, err
+		}
+		seen := NewHashTable()
+		for _, entry := range alist {
+			pair, err := alistEntry("merge-alists", entry)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := seen.Get(pair[0]); dup {
+				continue
+			}
+			seen.Set(pair[0], parser.Boolean(true))
+			if pos, ok := positions.Get(pair[0]); ok {
+				result[pos.(parser.Integer)] = entry
+				continue
+			}
+			positions.Set(pair[0], parser.Integer(len(result)))
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// builtinGroupBy は "group-by" を実装します。
+// (group-by key-proc list) 各要素に key-proc を適用し、キーごとに要素のリストをまとめたハッシュテーブルを返します。
+// キーは最初に現れた順に並び、各グループ内の要素は元のリストの順序を保ちます。
+func builtinGroupBy(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("group-by: wrong number of arguments")
+	}
+	keyProc, err := procArg("group-by", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	list, err := listArg("group-by", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	table := NewHashTable()
+	for _, elem := range list {
+		key, err := keyProc.Call([]parser.Expr{elem})
+		if err != nil {
+			return nil, err
+		}
+		group, ok := table.Get(key)
+		if !ok {
+			table.Set(key, parser.List{elem})
+			continue
+		}
+		// グループのリストはこの関数の中でしか参照されないため、そのまま末尾に追加してよい
+		table.Set(key, append(group.(parser.List), elem))
+	}
+	return table, nil
+}
+
+// builtinFindMap は "find-map" を実装します。
+// (find-map proc list) 要素に順に proc を適用し、最初に #f 以外を返したときの値を返します。
+// それ以降の要素には proc を適用しません。どの要素でも #f であれば #f を返します。
+func builtinFindMap(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("find-map: wrong number of arguments")
+	}
+	proc, err := procArg("find-map", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	list, err := listArg("find-map", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range list {
+		result, err := proc.Call([]parser.Expr{elem})
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(result) {
+			return result, nil
+		}
+	}
+	return parser.Boolean(false), nil
+}
+
+// plistArg は args[i] が、キーと値を交互に並べた要素数が偶数のリスト（属性リスト）であることを確認して返します。
+func plistArg(name string, args []parser.Expr, i int) (parser.List, error) {
+	plist, err := listArg(name, args, i)
+	if err != nil {
+		return nil, err
+	}
+	if len(plist)%2 != 0 {
+		return nil, fmt.Errorf("%s: property list must have an even number of elements, got %s", name, WriteString(plist))
+	}
+	return plist, nil
+}
+
+// builtinPlistGet は "plist-get" を実装します。
+// (plist-get plist key) (a 1 b 2) のような属性リストから、キーが eq? で等しい最初の値を返します。
+// キーがなければ #f を返します。
+func builtinPlistGet(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("plist-get: wrong number of arguments")
+	}
+	plist, err := plistArg("plist-get", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(plist); i += 2 {
+		if isEq(plist[i], args[1]) {
+			return plist[i+1], nil
+		}
+	}
+	return parser.Boolean(false), nil
+}
+
+// builtinPlistPut は "plist-put" を実装します。
+// (plist-put plist key val) 最初に見つかったキーの値を val に置き換えた新しい属性リストを返します。
+// キーが存在しない場合は末尾に key と val を追加します。
+func builtinPlistPut(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("plist-put: wrong number of arguments")
+	}
+	plist, err := plistArg("plist-put", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make(parser.List, len(plist), len(plist)+2)
+	copy(result, plist)
+	for i := 0; i < len(result); i += 2 {
+		if isEq(result[i], args[1]) {
+			result[i+1] = args[2]
+			return result, nil
+		}
+	}
+	return append(result, args[1], args[2]), nil
+}
+
+// equalityArg は省略可能な比較手続き args[i] を解釈します。省略時は equal? で比較します。
+func equalityArg(name string, args []parser.Expr, i int) (func(a, b parser.Expr) (bool, error), error) {
+	if len(args) <= i {
+		return func(a, b parser.Expr) (bool, error) { return isEqual(a, b), nil }, nil
+	}
+	eq, err := procArg(name, args, i)
+	if err != nil {
+		return nil, err
+	}
+	return func(a, b parser.Expr) (bool, error) {
+		result, err := eq.Call([]parser.Expr{a, b})
+		if err != nil {
+			return false, err
+		}
+		return isTrue(result), nil
+	}, nil
+}
+
+// builtinDelete は "delete" を実装します。
+// (delete x list [eq]) x と等しい（既定は equal?）要素をすべて取り除いた新しいリストを返します。
+func builtinDelete(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("delete: wrong number of arguments")
+	}
+	list, err := listArg("delete", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	eq, err := equalityArg("delete", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	result := parser.List{}
+	for _, elem := range list {
+		same, err := eq(args[0], elem)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			result = append(result, elem)
+		}
+	}
+	return result, nil
+}
+
+// builtinDeleteDuplicates は "delete-duplicates" を実装します。
+// (delete-duplicates list [eq]) 等しい要素のうち最初に現れたものだけを残した新しいリストを返します。
+func builtinDeleteDuplicates(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("delete-duplicates: wrong number of arguments")
+	}
+	list, err := listArg("delete-duplicates", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	eq, err := equalityArg("delete-duplicates", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	result := parser.List{}
+	for _, elem := range list {
+		seen := false
+		for _, kept := range result {
+			if seen, err = eq(kept, elem); err != nil {
+				return nil, err
+			}
+			if seen {
+				break
+			}
+		}
+		if !seen {
+			result = append(result, elem)
+		}
+	}
+	return result, nil
+}
+
+// builtinFlatten は "flatten" を実装します。
+// (flatten list) 入れ子のリストを、要素のアトムを左から順に並べた1段のリストにします。
+// 空リストは要素を持たないため結果から消えます。深い入れ子でも Go のスタックを消費しないよう、
+// 走査中のリストと位置を明示的なスタックで管理します。
+// スタック上のリストが自分自身を含む循環したリストは平らにできないため、エラーにします。
+func builtinFlatten(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("flatten: wrong number of arguments")
+	}
+	list, err := listArg("flatten", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	type frame struct {
+		list parser.List
+		i    int
+	}
+	result := parser.List{}
+	stack := []frame{{list: list}}
+	// onStack はスタック上にある空でないリストの集合です
+	onStack := make(map[listIdentity]bool)
+	if len(list) > 0 {
+		onStack[identityOf(list)] = true
+	}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.i == len(top.list) {
+			if len(top.list) > 0 {
+				delete(onStack, identityOf(top.list))
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		elem := top.list[top.i]
+		top.i++
+		if sub, ok := elem.(parser.List); ok {
+			if len(sub) > 0 {
+				if onStack[identityOf(sub)] {
+					return nil, fmt.Errorf("flatten: cannot flatten a cyclic list")
+				}
+				onStack[identityOf(sub)] = true
+			}
+			stack = append(stack, frame{list: sub})
+			continue
+		}
+		result = append(result, elem)
+	}
+	return result, nil
+}
+
+// makeTabulate は (name n proc) で (proc 0) から (proc n-1) までの結果を順に並べたリストを返す組み込み関数を作ります。
+// tabulate と、SRFI-1 の名前である list-tabulate で共有します。
+func makeTabulate(name string) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			n, err := indexArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			proc, err := procArg(name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			result := make(parser.List, 0, n)
+			for i := 0; i < n; i++ {
+				val, err := proc.Call([]parser.Expr{parser.Integer(i)})
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, val)
+			}
+			return result, nil
+		},
+	}
+}
+
+// builtinUnfold は "unfold" を実装します（SRFI-1）。
+// (unfold stop? mapper successor seed [tail-gen]) (stop? seed) が #f の間、(mapper seed) を集めながら
+// seed を (successor seed) に進め、集めた値のリストを返します。
+// tail-gen を指定した場合は、停止したときの seed に適用した結果（リスト）を末尾に連結します。
+func builtinUnfold(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, fmt.Errorf("unfold: wrong number of arguments")
+	}
+	procs := make([]Callable, 0, 4)
+	for _, i := range []int{0, 1, 2, 4} {
+		if i >= len(args) {
+			break
+		}
+		proc, err := procArg("unfold", args, i)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, proc)
+	}
+	stop, mapper, successor := procs[0], procs[1], procs[2]
+	seed := args[3]
+	var result parser.List
+	for {
+		done, err := stop.Call([]parser.Expr{seed})
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(done) {
+			break
+		}
+		val, err := mapper.Call([]parser.Expr{seed})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, val)
+		if seed, err = successor.Call([]parser.Expr{seed}); err != nil {
+			return nil, err
+		}
+	}
+	if len(procs) == 3 {
+		if result == nil {
+			return parser.List{}, nil
+		}
+		return result, nil
+	}
+	tail, err := procs[3].Call([]parser.Expr{seed})
+	if err != nil {
+		return nil, err
+	}
+	tailList, ok := tail.(parser.List)
+	if !ok {
+		return nil, fmt.Errorf("unfold: tail-gen must return a list, got %s", WriteString(tail))
+	}
+	return append(append(parser.List{}, result...), tailList...), nil
+}
+
+// numericListArg は (name list) の list が、数値だけを要素とするリストであることを確認して返します。
+// 要素に Float があるかも返します。
+func numericListArg(name string, args []parser.Expr) (list parser.List, inexact bool, err error) {
+	if len(args) != 1 {
+		return nil, false, fmt.Errorf("%s: wrong number of arguments", name)
+	}
+	if list, err = listArg(name, args, 0); err != nil {
+		return nil, false, err
+	}
+	if inexact, err = numericElems(name, list); err != nil {
+		return nil, false, err
+	}
+	return list, inexact, nil
+}
+
+// makeListReduction は (name list) で数値のリストの要素に op を init から順に適用した結果を返す
+// sum や product を作ります。数値の種類は + や * と同じ規則でそろえ、空のリストでは init を返します。
+func makeListReduction(name string, op arithOp, init parser.Expr) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			list, _, err := numericListArg(name, args)
+			if err != nil {
+				return nil, err
+			}
+			return op.fold(init, list)
+		},
+	}
+}
+
+// builtinAverage は "average" を実装します。
+// (average list) 数値のリストの平均を Float で返します。空のリストはエラーです。
+func builtinAverage(args []parser.Expr) (parser.Expr, error) {
+	list, _, err := numericListArg("average", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("average: empty list")
+	}
+	sum, err := addOp.fold(parser.Integer(0), list)
+	if err != nil {
+		return nil, err
+	}
+	return parser.Float(toFloat(sum) / float64(len(list))), nil
+}
+
+// makeListExtremum は list-max や list-min を作ります。規則は vector-max や vector-min と同じです。
+func makeListExtremum(name string, better func(c int) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			list, inexact, err := numericListArg(name, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(list) == 0 {
+				return nil, fmt.Errorf("%s: empty list", name)
+			}
+			return extremum(list, inexact, better), nil
+		},
+	}
+}
+
+// makeIndexedTraversal は (name proc list) の各要素の添字（0 始まり）と要素で (proc index elem) を順に呼び出す
+// 組み込み関数を作ります。collect が真なら結果のリストを、偽なら副作用のためだけに呼び出して Unspecified を返します。
+func makeIndexedTraversal(name string, collect bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			proc, err := procArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			list, err := listArg(name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			var result parser.List
+			if collect {
+				result = make(parser.List, 0, len(list))
+			}
+			for i, elem := range list {
+				val, err := proc.Call([]parser.Expr{parser.Integer(i), elem})
+				if err != nil {
+					return nil, err
+				}
+				if collect {
+					result = append(result, val)
+				}
+			}
+			if !collect {
+				return Unspecified, nil
+			}
+			return result, nil
+		},
+	}
+}
+
+// builtinPartition は "partition" を実装します。
+// (partition pred list) pred を満たす要素のリストと満たさない要素のリストを、元の順序を保ったまま2つの値として返します。
+func builtinPartition(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("partition: wrong number of arguments")
+	}
+	pred, err := procArg("partition", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	list, err := listArg("partition", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	in, out := parser.List{}, parser.List{}
+	for _, elem := range list {
+		result, err := pred.Call([]parser.Expr{elem})
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(result) {
+			in = append(in, elem)
+		} else {
+			out = append(out, elem)
+		}
+	}
+	return MultipleValues{in, out}, nil
+}
+
+// makeListQuantifier は every や any を作ります。
+// (name pred list...) 各リストの同じ位置の要素を引数に pred を先頭から順に呼び、最も短いリストの長さで打ち切ります。
+// every は pred が #f を返した時点で #f を、すべての要素で真なら最後の pred の値（要素がなければ #t）を返します。
+// any は pred が #f 以外を返した時点でその値を、どの要素でも #f なら #f を返します。
+func makeListQuantifier(name string, every bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			pred, err := procArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			lists := make([]parser.List, len(args)-1)
+			n := -1
+			for i := range lists {
+				if lists[i], err = listArg(name, args, i+1); err != nil {
+					return nil, err
+				}
+				if n < 0 || len(lists[i]) < n {
+					n = len(lists[i])
+				}
+			}
+			var result parser.Expr = parser.Boolean(every)
+			for i := 0; i < n; i++ {
+				predArgs := make([]parser.Expr, len(lists))
+				for j, list := range lists {
+					predArgs[j] = list[i]
+				}
+				if result, err = pred.Call(predArgs); err != nil {
+					return nil, err
+				}
+				if isTrue(result) != every {
+					return result, nil
+				}
+			}
+			return result, nil
+		},
+	}
+}
+
+// builtinZip は "zip" を実装します。
+// (zip list1 list2 ...) 各リストの同じ位置の要素をまとめたリストのリストを返します。最も短いリストの長さで打ち切ります。
+func builtinZip(args []parser.Expr) (parser.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("zip: wrong number of arguments")
+	}
+	lists := make([]parser.List, len(args))
+	n := -1
+	for i := range args {
+		list, err := listArg("zip", args, i)
+		if err != nil {
+			return nil, err
+		}
+		lists[i] = list
+		if n < 0 || len(list) < n {
+			n = len(list)
+		}
+	}
+	result := make(parser.List, n)
+	for i := range result {
+		group := make(parser.List, len(lists))
+		for j, list := range lists {
+			group[j] = list[i]
+		}
+		result[i] = group
+	}
+	return result, nil
+}
+
+// builtinUnzip は "unzip" を実装します。
+// (unzip list) zip の逆で、同じ長さのリストを要素とするリストから、各位置の要素を集めたリストを多値として返します。
+// (unzip '((1 a) (2 b))) は (1 2) と (a b) の2つの値を返すため、call-with-values で zip に渡すと元に戻ります。
+// 空のリストは値を返しません。
+func builtinUnzip(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("unzip: wrong number of arguments")
+	}
+	groups, err := listArg("unzip", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var columns []parser.Expr
+	for i, elem := range groups {
+		group, ok := elem.(parser.List)
+		if !ok {
+			return nil, fmt.Errorf("unzip: element %d must be a list, got %s", i, WriteString(elem))
+		}
+		if i == 0 {
+			columns = make([]parser.Expr, len(group))
+			for j := range columns {
+				columns[j] = make(parser.List, 0, len(groups))
+			}
+		} else if len(group) != len(columns) {
+			return nil, fmt.Errorf("unzip: element %d must have %d elements, got %s", i, len(columns), WriteString(elem))
+		}
+		for j, x := range group {
+			columns[j] = append(columns[j].(parser.List), x)
+		}
+	}
+	return makeValues(columns), nil
+}
+
+// splitWhile は (name pred list) の引数を解釈し、pred の結果が holds と異なる最初の要素の添字を返します。
+// すべての要素で pred の結果が holds と一致する場合はリストの長さを返します。
+func splitWhile(name string, args []parser.Expr, holds bool) (parser.List, int, error) {
+	if len(args) != 2 {
+		return nil, 0, fmt.Errorf("%s: wrong number of arguments", name)
+	}
+	pred, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := listArg(name, args, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, elem := range list {
+		result, err := pred.Call([]parser.Expr{elem})
+		if err != nil {
+			return nil, 0, err
+		}
+		if isTrue(result) != holds {
+			return list, i, nil
+		}
+	}
+	return list, len(list), nil
+}
+
+// makeListSplitter は splitWhile で求めた位置でリストを分割する組み込み関数を生成します。
+// 分割したそれぞれの部分は元のリストと構造を共有しない新しいリストで、
+// result が前半と後半から返す値を組み立てます。
+func makeListSplitter(name string, holds bool, result func(prefix, suffix parser.List) parser.Expr) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			list, i, err := splitWhile(name, args, holds)
+			if err != nil {
+				return nil, err
+			}
+			prefix := append(parser.List{}, list[:i]...)
+			suffix := append(parser.List{}, list[i:]...)
+			return result(prefix, suffix), nil
+		},
+	}
+}
+
+// registerListBuiltins はリスト関連の組み込み関数を環境に登録します。
+func registerListBuiltins(env *Env) {
+	env.Set("eq?", &Builtin{Name: "eq?", Fn: builtinEq})
+	env.Set("equal?", &Builtin{Name: "equal?", Fn: builtinEqual})
+	env.Set("list", &Builtin{Name: "list", Fn: builtinList})
+	env.Set("list*", &Builtin{Name: "list*", Fn: builtinListStar})
+	env.Set("cons*", &Builtin{Name: "cons*", Fn: builtinListStar})
+	env.Set("list-ref", &Builtin{Name: "list-ref", Fn: builtinListRef})
+	env.Set("list-set!", &Builtin{Name: "list-set!", Fn: builtinListSet})
+	env.Set("deep-copy", &Builtin{Name: "deep-copy", Fn: builtinDeepCopy})
+	env.Set("del-assoc", &Builtin{Name: "del-assoc", Fn: builtinDelAssoc})
+	env.Set("alist-update", &Builtin{Name: "alist-update", Fn: builtinAlistUpdate})
+	env.Set("plist-get", &Builtin{Name: "plist-get", Fn: builtinPlistGet})
+	env.Set("plist-put", &Builtin{Name: "plist-put", Fn: builtinPlistPut})
+	env.Set("merge-alists", &Builtin{Name: "merge-alists", Fn: builtinMergeAlists})
+	env.Set("group-by", &Builtin{Name: "group-by", Fn: builtinGroupBy})
+	env.Set("find-map", &Builtin{Name: "find-map", Fn: builtinFindMap})
+	env.Set("delete", &Builtin{Name: "delete", Fn: builtinDelete})
+	env.Set("delete-duplicates", &Builtin{Name: "delete-duplicates", Fn: builtinDeleteDuplicates})
+	env.Set("flatten", &Builtin{Name: "flatten", Fn: builtinFlatten})
+	env.Set("tabulate", makeTabulate("tabulate"))
+	env.Set("list-tabulate", makeTabulate("list-tabulate"))
+	env.Set("unfold", &Builtin{Name: "unfold", Fn: builtinUnfold})
+	env.Set("sum", makeListReduction("sum", addOp, parser.Integer(0)))
+	env.Set("product", makeListReduction("product", mulOp, parser.Integer(1)))
+	env.Set("average", &Builtin{Name: "average", Fn: builtinAverage})
+	env.Set("list-max", makeListExtremum("list-max", func(c int) bool { return c > 0 }))
+	env.Set("list-min", makeListExtremum("list-min", func(c int) bool { return c < 0 }))
+	env.Set("map-indexed", makeIndexedTraversal("map-indexed", true))
+	env.Set("for-each-indexed", makeIndexedTraversal("for-each-indexed", false))
+	// (take-while pred list) pred が成り立つ間の先頭部分を返します
+	env.Set("partition", &Builtin{Name: "partition", Fn: builtinPartition})
+	env.Set("every", makeListQuantifier("every", true))
+	env.Set("any", makeListQuantifier("any", false))
+	env.Set("zip", &Builtin{Name: "zip", Fn: builtinZip})
+	env.Set("unzip", &Builtin{Name: "unzip", Fn: builtinUnzip})
+	env.Set("take-while", makeListSplitter("take-while", true, func(prefix, _ parser.List) parser.Expr { return prefix }))
+	// (drop-while pred list) take-while が返す先頭部分を除いた残りを返します
+	env.Set("drop-while", makeListSplitter("drop-while", true, func(_, suffix parser.List) parser.Expr { return suffix }))
+	// (span pred list) pred が初めて成り立たなくなる位置で分割し、前半と後半を多値で返します
+	env.Set("span", makeListSplitter("span", true, func(prefix, suffix parser.List) parser.Expr {
+		return MultipleValues{prefix, suffix}
+	}))
+	// (break pred list) pred が初めて成り立つ位置で分割し、前半と後半を多値で返します
+	env.Set("break", makeListSplitter("break", false, func(prefix, suffix parser.List) parser.Expr {
+		return MultipleValues{prefix, suffix}
+	}))
+}
@@ -0,0 +1,676 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestDelAssocAndAlistUpdate は del-assoc と alist-update が新しい連想リストを返し、
+// 元の連想リストを変更しないことをテストします。
+func TestDelAssocAndAlistUpdate(t *testing.T) {
+	env := NewGlobalEnv()
+	if _, err := evalString(t, env, `(define alist '((a 1) (b 2) ((c) 3)))`); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(del-assoc 'b alist)", "((a 1) ((c) 3))"},
+		{"(del-assoc '(c) alist)", "((a 1) (b 2))"},
+		{"(del-assoc 'missing alist)", "((a 1) (b 2) ((c) 3))"},
+		{"(alist-update 'a 10 alist)", "((a 10) (b 2) ((c) 3))"},
+		{"(alist-update 'd 4 alist)", "((a 1) (b 2) ((c) 3) (d 4))"},
+		{"(alist-update 'a 1 '())", "((a 1))"},
+		// 元の連想リストは変更されない
+		{"alist", "((a 1) (b 2) ((c) 3))"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestAlistErrors は連想リストでない引数がエラーになることをテストします。
+func TestAlistErrors(t *testing.T) {
+	inputs := []string{"(del-assoc 'a 1)", "(del-assoc 'a '(1 2))", "(alist-update 'a 1 '(()))"}
+	for _, input := range inputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestGroupBy は group-by がキーごとに要素をまとめ、キーとグループ内の順序を保つことをテストします。
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		input    string
+		keys     string
+		expected map[string]string
+	}{
+		{
+			input:    "(group-by even? '(1 2 3 4 5 6 7))",
+			keys:     "(#f #t)",
+			expected: map[string]string{"#f": "(1 3 5 7)", "#t": "(2 4 6)"},
+		},
+		{
+			input:    "(group-by odd? '(-2 -1 0))",
+			keys:     "(#f #t)",
+			expected: map[string]string{"#f": "(-2 0)", "#t": "(-1)"},
+		},
+		{
+			input:    "(group-by (lambda (n) (remainder n 3)) '(3 4 5 6 7 8 9))",
+			keys:     "(0 1 2)",
+			expected: map[string]string{"0": "(3 6 9)", "1": "(4 7)", "2": "(5 8)"},
+		},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.input, err)
+		}
+		table, ok := result.(*HashTable)
+		if !ok {
+			t.Fatalf("%s: expected a hash table, got %T", tt.input, result)
+		}
+		var keys parser.List
+		for _, entry := range table.entries {
+			keys = append(keys, entry.key)
+			if got := WriteString(entry.value); got != tt.expected[WriteString(entry.key)] {
+				t.Errorf("%s: group %s: expected %s, got %s", tt.input, WriteString(entry.key), tt.expected[WriteString(entry.key)], got)
+			}
+		}
+		if got := WriteString(keys); got != tt.keys {
+			t.Errorf("%s: expected keys %s, got %s", tt.input, tt.keys, got)
+		}
+	}
+	for _, input := range []string{"(group-by even? '(1 a))", "(group-by 1 '(1))", "(even? 1.5)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestFindMap は find-map が最初に真となった値を返し、それ以降の要素を処理しないことをテストします。
+func TestFindMap(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		// 3番目の要素で見つかるので、4番目以降には適用されない
+		{"(find-map big-square '(1 2 4 5 6))", "16", 3},
+		{"(find-map big-square '(1 2 3))", "#f", 3},
+		{"(find-map big-square '())", "#f", 0},
+	}
+	for _, tt := range tests {
+		env := NewGlobalEnv()
+		calls := 0
+		// big-square は呼び出し回数を数え、2乗が 10 を超えればその値を、そうでなければ #f を返します
+		env.Set("big-square", &Builtin{Name: "big-square", Fn: func(args []parser.Expr) (parser.Expr, error) {
+			calls++
+			n := args[0].(parser.Integer)
+			if n*n > 10 {
+				return n * n, nil
+			}
+			return parser.Boolean(false), nil
+		}})
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: expected %d calls, got %d", tt.input, tt.calls, calls)
+		}
+	}
+}
+
+// TestListSet は list-set! がリストをその場で変更し、別の束縛からも変更が見えることをテストします。
+func TestListSet(t *testing.T) {
+	env := NewGlobalEnv()
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{"(define xs (list 1 2 3 4))", "xs"},
+		{"(define ys xs)", "ys"},
+		{"(list-set! xs 2 'changed)", ""},
+		{"xs", "(1 2 changed 4)"},
+		{"ys", "(1 2 changed 4)"},
+		{"(list-ref ys 2)", "changed"},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+	}
+	for _, input := range []string{"(list-set! xs 4 0)", "(list-set! xs -1 0)", "(list-set! 5 0 0)", "(list-set! (list) 0 0)"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestDeleteAndDeleteDuplicates は delete と delete-duplicates を、既定の equal? と
+// 独自の比較手続きの両方でテストします。どちらも元のリストを変更しません。
+func TestDeleteAndDeleteDuplicates(t *testing.T) {
+	env := NewGlobalEnv()
+	env.Set("same-parity?", &Builtin{Name: "same-parity?", Fn: func(args []parser.Expr) (parser.Expr, error) {
+		return parser.Boolean(args[0].(parser.Integer)%2 == args[1].(parser.Integer)%2), nil
+	}})
+	if _, err := evalString(t, env, "(define xs (list 1 2 1 3 2 '(4) 1 '(4)))"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(delete 1 xs)", "(2 3 2 (4) (4))"},
+		{"(delete '(4) xs)", "(1 2 1 3 2 1)"},
+		{"(delete 9 xs)", "(1 2 1 3 2 (4) 1 (4))"},
+		{"(delete-duplicates xs)", "(1 2 3 (4))"},
+		{"(delete-duplicates '())", "()"},
+		{"(delete 1 '(1 2 3 4 5) same-parity?)", "(2 4)"},
+		{"(delete-duplicates '(3 5 4 7 6) same-parity?)", "(3 4)"},
+		// 元のリストは変更されない
+		{"xs", "(1 2 1 3 2 (4) 1 (4))"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(delete 1 2)", "(delete-duplicates xs 1)", "(delete 1)"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestTakeDropWhile は take-while・drop-while・span・break がリストを正しい位置で分割することをテストします。
+func TestTakeDropWhile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(take-while small? '(1 2 3 4 1 2))", "(1 2 3)"},
+		{"(drop-while small? '(1 2 3 4 1 2))", "(4 1 2)"},
+		{"(span small? '(1 2 3 4 1 2))", "(1 2 3) (4 1 2)"},
+		// break は pred が初めて成り立つ位置で分割する
+		{"(break big? '(1 2 3 4 1 2))", "(1 2 3) (4 1 2)"},
+		{"(break small? '(1 2 3 4 1 2))", "() (1 2 3 4 1 2)"},
+		{"(take-while small? '(5 6))", "()"},
+		{"(drop-while small? '(1 2))", "()"},
+		{"(span small? '())", "() ()"},
+		{"(call-with-values (lambda () (span small? '(1 5 2))) list)", "((1) (5 2))"},
+	}
+	for _, tt := range tests {
+		env := NewGlobalEnv()
+		evalString(t, env, "(define (small? x) (< x 4))")
+		evalString(t, env, "(define (big? x) (< 3 x))")
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(take-while 1 '(1 2))", "(span (lambda (x) x) 3)", "(break (lambda (x) x))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestTabulate は tabulate が 0 から n-1 までの各添字に手続きを適用したリストを返すことをテストします。
+func TestTabulate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(tabulate 4 (lambda (i) (* i i)))", "(0 1 4 9)"},
+		{"(tabulate 3 (lambda (i) i))", "(0 1 2)"},
+		{"(tabulate 0 (lambda (i) i))", "()"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(tabulate -1 (lambda (i) i))", "(tabulate 1.5 (lambda (i) i))", "(tabulate 3 4)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestPartition は partition が述語を満たす要素と満たさない要素を2つの値として返すことをテストします。
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(partition even? '(1 2 3 4 5 6))", "(2 4 6) (1 3 5)"},
+		{"(call-with-values (lambda () (partition even? '(1 2 3 4 5))) list)", "((2 4) (1 3 5))"},
+		{"(receive (evens odds) (partition even? '(3 8 5 10)) (list odds evens))", "((3 5) (8 10))"},
+		{"(partition even? '())", "() ()"},
+		{"(partition even? '(1 3))", "() (1 3)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(partition even?)", "(partition 1 '(1))", "(partition even? 1)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestEveryAny は every と any が述語の結果を返し、結果が決まった時点で打ち切ることをテストします。
+func TestEveryAny(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(every even? '(2 4 6))", "#t"},
+		{"(every even? '(2 3 6))", "#f"},
+		{"(every even? '())", "#t"},
+		// every はすべて真なら最後の述語の値を返す
+		{"(every (lambda (x) (* x 10)) '(1 2 3))", "30"},
+		{"(any odd? '(2 4 5))", "#t"},
+		{"(any (lambda (x) (if (odd? x) (* x 10) #f)) '(2 4 5 7))", "50"},
+		{"(any odd? '(2 4))", "#f"},
+		{"(any odd? '())", "#f"},
+		// 複数のリストは同じ位置の要素を述語に渡し、最も短いリストで打ち切る
+		{"(every < '(1 2 3) '(2 3 4))", "#t"},
+		{"(any > '(1 5) '(2 3 0))", "#t"},
+		{"(every < '(1 2 9) '(2 3))", "#t"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// 結果が決まった後の要素には述語を適用しない
+	shortCircuit := []struct {
+		input string
+		calls string
+	}{
+		{"(every counting-even? '(2 3 4 6))", "2"},
+		{"(any counting-even? '(1 2 3 5))", "2"},
+	}
+	for _, tt := range shortCircuit {
+		env := NewGlobalEnv()
+		evalString(t, env, `
+(define calls (list 0))
+(define (counting-even? x) (list-set! calls 0 (+ (list-ref calls 0) 1)) (even? x))`)
+		evalString(t, env, tt.input)
+		if got := evalToString(t, env, "(list-ref calls 0)"); got != tt.calls {
+			t.Errorf("%s: expected %s calls, got %s", tt.input, tt.calls, got)
+		}
+	}
+	for _, input := range []string{"(every even?)", "(any 1 '(1))", "(every even? 1)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestZip は zip が対応する要素をまとめ、unzip がその逆になることをテストします。
+func TestZip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(zip '(1 2 3) '(a b c))", "((1 a) (2 b) (3 c))"},
+		// 最も短いリストで打ち切る
+		{"(zip '(1 2 3) '(a b))", "((1 a) (2 b))"},
+		{"(zip '(1 2) '(a b) '(x y))", "((1 a x) (2 b y))"},
+		{"(zip '(1 2))", "((1) (2))"},
+		{"(zip '() '(a))", "()"},
+		{"(unzip '((1 a) (2 b) (3 c)))", "(1 2 3) (a b c)"},
+		{"(call-with-values (lambda () (unzip (zip '(1 2 3) '(a b c)))) zip)", "((1 a) (2 b) (3 c))"},
+		{"(receive (nums syms) (unzip '((1 a) (2 b))) syms)", "(a b)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(zip)", "(zip '(1) 2)", "(unzip '((1 a) (2)))", "(unzip '(1 2))", "(unzip 1)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestPlist は plist-get と plist-put が属性リストの値を取得・設定することをテストします。
+func TestPlist(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(plist-get '(a 1 b 2) 'b)", "2"},
+		{"(plist-get '(a 1 b 2) 'c)", "#f"},
+		{"(plist-get '() 'a)", "#f"},
+		// キーと同じ値を持つ要素にはマッチしない
+		{"(plist-get '(a b b c) 'b)", "c"},
+		{"(plist-put '(a 1 b 2) 'b 3)", "(a 1 b 3)"},
+		{"(plist-put '(a 1 b 2) 'c 3)", "(a 1 b 2 c 3)"},
+		{"(plist-put '() 'a 1)", "(a 1)"},
+		// 元の属性リストは変更しない
+		{"(define p '(a 1)) (plist-put p 'a 2) p", "(a 1)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(plist-get '(a 1 b) 'a)", "(plist-get 1 'a)", "(plist-put '(a) 'a 1)", "(plist-put '(a 1) 'a)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestUnfold は unfold が seed を進めながら値を集めたリストを返すことと、list-tabulate をテストします。
+func TestUnfold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(unfold (lambda (x) (equal? x 6)) (lambda (x) x) (lambda (x) (+ x 1)) 1)", "(1 2 3 4 5)"},
+		{"(unfold (lambda (x) (equal? x 4)) (lambda (x) (* x x)) (lambda (x) (+ x 1)) 1)", "(1 4 9)"},
+		// stop? が最初から真なら空リスト
+		{"(unfold (lambda (x) #t) (lambda (x) x) (lambda (x) x) 1)", "()"},
+		// tail-gen の結果は末尾に連結される
+		{"(unfold (lambda (x) (equal? x 3)) (lambda (x) x) (lambda (x) (+ x 1)) 1 (lambda (x) (list 'end x)))", "(1 2 end 3)"},
+		{"(list-tabulate 3 (lambda (i) (* i 10)))", "(0 10 20)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{
+		"(unfold (lambda (x) #t) (lambda (x) x) (lambda (x) x))",
+		"(unfold 1 (lambda (x) x) (lambda (x) x) 1)",
+		"(unfold (lambda (x) #t) (lambda (x) x) (lambda (x) x) 1 (lambda (x) 2))",
+		"(list-tabulate -1 (lambda (i) i))",
+	} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestListStatistics は sum・product・average・list-max・list-min が数値のリストを集計することをテストします。
+func TestListStatistics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(sum '(1 2 3 4))", "10"},
+		{"(sum '(1 2.5))", "3.5"},
+		{"(sum '(1/2 1/3))", "5/6"},
+		{"(sum '())", "0"},
+		{"(product '(2 3 4))", "24"},
+		{"(product '(2 0.5))", "1.0"},
+		{"(product '())", "1"},
+		{"(average '(1 2 3 4))", "2.5"},
+		{"(average '(2 4))", "3.0"},
+		{"(average '(1 2.5 1/2))", "1.3333333333333333"},
+		{"(list-max '(3 1 4 1 5))", "5"},
+		{"(list-min '(3 1 4 1 5))", "1"},
+		// Float が含まれれば結果も Float
+		{"(list-max '(3 1.5 2))", "3.0"},
+		{"(list-min '(3 1/2 2.0))", "0.5"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(average '())", "average: empty list"},
+		{"(list-max '())", "list-max: empty list"},
+		{"(list-min '())", "list-min: empty list"},
+		{"(sum '(1 a))", "sum: element 1 must be a number, got a"},
+		{"(product 1)", "product: argument 1 must be a list"},
+		{"(average '(1) '(2))", "average: wrong number of arguments"},
+	}
+	for _, tt := range errorTests {
+		if _, err := evalString(t, NewGlobalEnv(), tt.input); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestMapIndexed は map-indexed と for-each-indexed が各要素を添字とともに手続きに渡すことをテストします。
+func TestMapIndexed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(map-indexed list '(a b c))", "((0 a) (1 b) (2 c))"},
+		{"(map-indexed (lambda (i x) (* i x)) '(5 6 7))", "(0 6 14)"},
+		{"(map-indexed list '())", "()"},
+		{`(define out (open-output-string))
+		  (for-each-indexed (lambda (i x) (list-ref (list (write i out) (write x out)) 1)) '("a" "b"))
+		  (get-output-string out)`, `"0\"a\"1\"b\""`},
+		{"(for-each-indexed list '(1 2))", ""},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(map-indexed list 1)", "(map-indexed 1 '(1))", "(for-each-indexed list)", "(map-indexed (lambda (x) x) '(1))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestListStar は list*（cons*）が最後の引数を末尾として残りの引数を前に並べることをテストします。
+func TestListStar(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(list* 1 2 '(3 4))", "(1 2 3 4)"},
+		{"(cons* 1 2 '(3 4))", "(1 2 3 4)"},
+		{"(list* 1 '())", "(1)"},
+		{"(list* '(1 2))", "(1 2)"},
+		{"(list* 5)", "5"},
+		// 末尾がリストでなければドット対になる
+		{"(list* 1 2 3)", "(1 2 . 3)"},
+		{"(list* 1 '(2 . 3))", "(1 2 . 3)"},
+		// 元のリストは変更されない
+		{"(define xs '(3 4)) (list* 1 xs) xs", "(3 4)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	if _, err := evalString(t, NewGlobalEnv(), "(list*)"); err == nil {
+		t.Error("(list*): expected error, got nil")
+	}
+}
+
+// TestFlatten は flatten が入れ子のリストを順序を保って1段にし、空リストを取り除くことをテストします。
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(flatten '(1 (2 (3 4)) 5))", "(1 2 3 4 5)"},
+		{"(flatten '((((((a))))) b ((c) d)))", "(a b c d)"},
+		{`(flatten '(1 () (2 ()) (() ()) "s"))`, `(1 2 "s")`},
+		{"(flatten '())", "()"},
+		{"(flatten '(((()))))", "()"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// 深く入れ子になったリスト (0 (1 (2 ... (depth-1)))) も平坦にできる
+	const depth = 100000
+	var nested parser.List
+	for i := depth - 1; i >= 0; i-- {
+		if nested == nil {
+			nested = parser.List{parser.Integer(i)}
+		} else {
+			nested = parser.List{parser.Integer(i), nested}
+		}
+	}
+	result, err := builtinFlatten([]parser.Expr{nested})
+	if err != nil {
+		t.Fatalf("flatten error: %v", err)
+	}
+	flat := result.(parser.List)
+	if len(flat) != depth || flat[0] != parser.Integer(0) || flat[depth-1] != parser.Integer(depth-1) {
+		t.Errorf("unexpected result of length %d", len(flat))
+	}
+
+	if _, err := evalString(t, NewGlobalEnv(), "(flatten 5)"); err == nil {
+		t.Error("(flatten 5): expected error, got nil")
+	}
+}
+
+// TestMergeAlists は merge-alists が後の連想リストのキーを優先し、キーを最初に現れた順に並べることをテストします。
+func TestMergeAlists(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"(merge-alists '((host \"localhost\") (port 80) (debug #f)) '((port 8080) (user admin)) '((debug #t) (timeout 30)))",
+			`((host "localhost") (port 8080) (debug #t) (user admin) (timeout 30))`,
+		},
+		// 3つ目のリストが2つ目の上書きをさらに上書きする
+		{"(merge-alists '((a 1)) '((a 2) (b 2)) '((a 3)))", "((a 3) (b 2))"},
+		// 同じリストの中で重なるキーは最初の要素が有効
+		{"(merge-alists '((a 1)) '((a 2) (a 9)))", "((a 2))"},
+		{"(merge-alists '(((x y) 1)) '(((x y) 2)))", "(((x y) 2))"},
+		{"(merge-alists '((a 1)) '())", "((a 1))"},
+		{"(merge-alists)", "()"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(merge-alists '((a 1)) 5)", "(merge-alists '(a))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestCyclicEquality は equal? やハッシュテーブルのキーが、データラベルや list-set! で作った
+// 循環するリストでも停止し、構造を比較することをテストします。
+func TestCyclicEquality(t *testing.T) {
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define x '#0=(1 #0#))", "x"},
+		{"(define y '#0=(1 #0#))", "y"},
+		{"(equal? x x)", "#t"},
+		{"(equal? x y)", "#t"},
+		{"(equal? x '#0=(2 #0#))", "#f"},
+		{"(equal? x '(1 (1 2)))", "#f"},
+		// list-set! で作った循環
+		{"(define z (list 1 2))", "z"},
+		{"(list-set! z 1 z)", ""},
+		{"(equal? x z)", "#t"},
+		{"(delete-duplicates (list x y z '(1 2)))", "(#0=(1 #0#) (1 2))"},
+		{"(define h (make-hash-table))", "h"},
+		{"(hash-table-set! h x 'cyclic)", ""},
+		{"(hash-table-ref h x)", "cyclic"},
+		{"(hash-table-ref h y)", "cyclic"},
+		{"(hash-table-ref h z)", "cyclic"},
+		{"(hash-table-ref/default h '#0=(2 #0#) 'none)", "none"},
+		// 循環せずに共有されているだけのリストは平らにできる
+		{"(define s '(1 2))", "s"},
+		{"(flatten (list s (list s)))", "(1 2 1 2)"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(flatten x)", "(flatten (list 0 z))", "(flatten '(a #0=(b #0#)))"} {
+		if _, err := evalString(t, env, input); err == nil || !strings.Contains(err.Error(), "cyclic") {
+			t.Errorf("%s: expected a cyclic list error, got %v", input, err)
+		}
+	}
+}
+
+// TestCyclicForm は評価する位置に循環するリストを含む式が、スタックを使い果たさずにエラーになり、
+// quote の中の循環や共有されているだけの部分式は評価できることをテストします。
+func TestCyclicForm(t *testing.T) {
+	for name, run := range map[string]func([]parser.Expr, *Env) (parser.Expr, error){"eval": EvalAll, "compiled": evalCompiled} {
+		for _, input := range []string{"(list 1 #0=(list #0#))", "#0=(begin #0#)", "(if #t #0=(list 1 #0#) 2)"} {
+			exprs, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+			if err != nil {
+				t.Fatalf("%s: parse error: %v", input, err)
+			}
+			if _, err := run(exprs, NewGlobalEnv()); err == nil || !strings.Contains(err.Error(), "cyclic expression") {
+				t.Errorf("%s (%s): expected a cyclic expression error, got %v", input, name, err)
+			}
+		}
+		for input, expected := range map[string]string{
+			"(list-ref '#0=(1 #0#) 0)":  "1",
+			"(list #0=(+ 1 2) #0# #0#)": "(3 3 3)",
+		} {
+			exprs, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+			if err != nil {
+				t.Fatalf("%s: parse error: %v", input, err)
+			}
+			result, err := run(exprs, NewGlobalEnv())
+			if err != nil {
+				t.Errorf("%s (%s): unexpected error: %v", input, name, err)
+			} else if got := WriteString(result); got != expected {
+				t.Errorf("%s (%s): expected %s, got %s", input, name, expected, got)
+			}
+		}
+	}
+}
+
+// TestVectorEquality は equal? がベクタを要素ごとに比較し、delete-duplicates やハッシュテーブルのキーでも
+// 同じ要素のベクタを等しいとみなすことをテストします。
+func TestVectorEquality(t *testing.T) {
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(equal? (vector 1 2) (vector 1 2))", "#t"},
+		{"(equal? (vector 1 (list 'a (vector \"b\"))) (vector 1 (list 'a (vector \"b\"))))", "#t"},
+		{"(equal? (vector 1 2) (vector 1 2.0))", "#f"},
+		{"(equal? (vector 1 2) (vector 1))", "#f"},
+		{"(equal? (vector 1 2) (list 1 2))", "#f"},
+		{"(equal? (vector) (vector))", "#t"},
+		{"(delete-duplicates (list (vector 1) (vector 1) (vector 2)))", "(#(1) #(2))"},
+		{"(define h (make-hash-table))", "h"},
+		{"(hash-table-set! h (vector 1 'a) 'found)", ""},
+		{"(hash-table-ref h (vector 1 'a))", "found"},
+		{"(hash-table-ref/default h (list 1 'a) 'none)", "none"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// パターンマッチ（match 特殊フォーム）
+//
+//	(match expr (pattern body...) ...)
+//
+// expr を評価し、上から順に pattern と照合して最初に一致した節の body を評価します。
+// パターンには次のものが使えます。
+//   - _            : 任意の値に一致する（束縛しない）
+//   - シンボル     : 任意の値に一致し、その値に束縛する
+//   - 'datum       : datum と equal? で等しい値に一致する
+//   - リテラル     : 数値・文字列・真偽値・文字。equal? で等しい値に一致する
+//   - (p1 p2 ...)  : 同じ長さのリストで、各要素がそれぞれのパターンに一致するものに一致する
+
+// matchPattern は値がパターンに一致するかを判定し、一致した場合はパターン変数を bindings に設定します。
+func matchPattern(pattern, val parser.Expr, bindings *Env) (bool, error) {
+	switch p := pattern.(type) {
+	case parser.Symbol:
+		if p != "_" {
+			bindings.Set(p, val)
+		}
+		return true, nil
+	case parser.List:
+		if len(p) == 2 && p[0] == parser.Symbol("quote") {
+			return isEqual(p[1], val), nil
+		}
+		list, ok := val.(parser.List)
+		if !ok || len(list) != len(p) {
+			return false, nil
+		}
+		for i := range p {
+			ok, err := matchPattern(p[i], list[i], bindings)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case parser.Integer, parser.Rational, parser.Float, parser.String, parser.Boolean, parser.Char:
+		return isEqual(p, val), nil
+	default:
+		return false, fmt.Errorf("match: invalid pattern %s", WriteString(pattern))
+	}
+}
+
+// matchClause は (match expr (pattern body...) ...) の expr を評価し、最初に一致した節の body と、
+// パターン変数を束縛した環境を返します。body は末尾位置にあるため、評価は呼び出し側の eval が行います。
+// どの節にも一致しない場合はエラーを返します。
+func matchClause(exp parser.List, env *Env) ([]parser.Expr, *Env, error) {
+	if len(exp) < 2 {
+		return nil, nil, fmt.Errorf("match: too few arguments")
+	}
+	val, err := evalExpr(exp[1], env)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, clause := range exp[2:] {
+		c, ok := clause.(parser.List)
+		if !ok || len(c) == 0 {
+			return nil, nil, fmt.Errorf("match: clause must be a non-empty list, got %s", WriteString(clause))
+		}
+		bindings := NewEnv(env)
+		ok, err := matchPattern(c[0], val, bindings)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return c[1:], bindings, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("match: no clause matches %s", WriteString(val))
+}
@@ -0,0 +1,34 @@
+package evaluator
+
+import "testing"
+
+// TestMatch は match 特殊フォームによるリストの分解、ワイルドカード、全捕捉節への
+// フォールスルーをテストします。
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(match '(1 2) ((a b) (+ a b)))", "3"},
+		{"(match '(1 (2 3)) ((a (b c)) (* a b c)))", "6"},
+		{"(match '(1 2 3) ((_ x _) x))", "2"},
+		{"(match '(1 2 3) ((a b) 'two) ((a b c) 'three))", "three"},
+		{"(match 42 (0 'zero) (\"42\" 'string) (42 'answer))", "answer"},
+		{"(match '(add 1 2) (('sub a b) 'sub) (('add a b) (+ a b)))", "3"},
+		{"(match 'other ('foo 1) ('bar 2) (_ 'fallback))", "fallback"},
+		{"(match '(1) ((a b) 'pair) (x x))", "(1)"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{"(match 5 (x (define y (* x 2)) (+ y 1)))", "11"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	// 一致する節がない場合や、節の形式が不正な場合はエラーになる
+	for _, input := range []string{"(match 1 (2 'two))", "(match 1 x)", "(match)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
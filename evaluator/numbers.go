@@ -0,0 +1,386 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// makeNumberPredicate は数値の種類を判定する述語（number? など）を生成します。
+// 数値以外の引数に対しては #f を返します。
+func makeNumberPredicate(name string, pred func(parser.Expr) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			return parser.Boolean(pred(args[0])), nil
+		},
+	}
+}
+
+// isNumber は Integer・Rational・Float のいずれかであるかを判定します。
+// 複素数型はないため、complex? と real? もこれと同じ判定になります。
+func isNumber(expr parser.Expr) bool {
+	switch expr.(type) {
+	case parser.Integer, parser.Rational, parser.Float:
+		return true
+	}
+	return false
+}
+
+// isRational は有理数であるかを判定します。
+// 有限の Float はすべて有理数として正確に表現できるため真とし、無限大と NaN のみ偽とします。
+func isRational(expr parser.Expr) bool {
+	switch v := expr.(type) {
+	case parser.Integer, parser.Rational:
+		return true
+	case parser.Float:
+		return !math.IsInf(float64(v), 0) && !math.IsNaN(float64(v))
+	}
+	return false
+}
+
+// isInteger は整数であるかを判定します。3.0 のように整数値を持つ Float も真とします。
+func isInteger(expr parser.Expr) bool {
+	switch v := expr.(type) {
+	case parser.Integer:
+		return true
+	case parser.Float:
+		f := float64(v)
+		return !math.IsInf(f, 0) && f == math.Trunc(f)
+	}
+	return false
+}
+
+// isExactInteger は正確な整数（Integer）であるかを判定します。3.0 のような Float は偽とします。
+func isExactInteger(expr parser.Expr) bool {
+	_, ok := expr.(parser.Integer)
+	return ok
+}
+
+// isExactNonnegativeInteger は 0 以上の正確な整数であるかを判定します。
+func isExactNonnegativeInteger(expr parser.Expr) bool {
+	n, ok := expr.(parser.Integer)
+	return ok && n >= 0
+}
+
+// indexArg は args[i] が添字として使える 0 以上の正確な整数であることを確認して返します。
+func indexArg(name string, args []parser.Expr, i int) (int, error) {
+	if !isExactInteger(args[i]) {
+		return 0, fmt.Errorf("%s: index must be an exact integer, got %s", name, WriteString(args[i]))
+	}
+	if !isExactNonnegativeInteger(args[i]) {
+		return 0, fmt.Errorf("%s: index must be a non-negative integer, got %s", name, WriteString(args[i]))
+	}
+	return int(args[i].(parser.Integer)), nil
+}
+
+// truncDivMod は商を 0 方向に丸める整数除算を行い、商と余りを返します。
+// 余りの符号は被除数と同じになります（quotient / remainder）。
+func truncDivMod(n, d int64) (int64, int64) {
+	return n / d, n % d
+}
+
+// floorDivMod は商を負の無限大方向に丸める整数除算を行い、商と余りを返します。
+// 余りの符号は除数と同じになります（floor-quotient / modulo）。
+func floorDivMod(n, d int64) (int64, int64) {
+	q, r := truncDivMod(n, d)
+	if r != 0 && (r < 0) != (d < 0) {
+		q--
+		r += d
+	}
+	return q, r
+}
+
+// divisionResult は整数除算系の組み込み関数が divmod の結果のうち何を返すかです。
+type divisionResult int
+
+const (
+	// divQuotient は商を返します（quotient など）。
+	divQuotient divisionResult = iota
+	// divRemainder は余りを返します（remainder など）。
+	divRemainder
+	// divBoth は商と余りを2つの値として返します（floor/ と truncate/）。
+	divBoth
+)
+
+// makeIntegerDivision は整数除算系の組み込み関数を生成します。
+// divmod の結果のうち、result に応じて商、余り、またはその両方を values として返します。
+func makeIntegerDivision(name string, divmod func(int64, int64) (int64, int64), result divisionResult) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			n, ok := args[0].(parser.Integer)
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid argument type %T", name, args[0])
+			}
+			d, ok := args[1].(parser.Integer)
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid argument type %T", name, args[1])
+			}
+			if d == 0 {
+				return nil, fmt.Errorf("%s: division by zero", name)
+			}
+			q, r := divmod(int64(n), int64(d))
+			switch result {
+			case divQuotient:
+				return parser.Integer(q), nil
+			case divRemainder:
+				return parser.Integer(r), nil
+			}
+			return makeValues([]parser.Expr{parser.Integer(q), parser.Integer(r)}), nil
+		},
+	}
+}
+
+// makeParityPredicate は整数の偶奇を判定する述語（even? / odd?）を生成します。
+func makeParityPredicate(name string, remainder int64) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			n, ok := args[0].(parser.Integer)
+			if !ok {
+				return nil, fmt.Errorf("%s: argument must be an integer, got %s", name, WriteString(args[0]))
+			}
+			_, r := floorDivMod(int64(n), 2)
+			return parser.Boolean(r == remainder), nil
+		},
+	}
+}
+
+// makeFloatClassPredicate は数値が無限大や NaN であるかを判定する述語（nan? など）を生成します。
+// Integer と Rational は常に有限の値として扱います。数値以外の引数はエラーです。
+func makeFloatClassPredicate(name string, pred func(float64) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			if isNumber(args[0]) {
+				return parser.Boolean(pred(toFloat(args[0]))), nil
+			}
+			return nil, fmt.Errorf("%s: argument must be a number, got %s", name, WriteString(args[0]))
+		},
+	}
+}
+
+// numericElems は elems（ベクタやリストの要素）がすべて数値であることを確認し、Float があるかを返します。
+func numericElems(name string, elems []parser.Expr) (inexact bool, err error) {
+	for i, elem := range elems {
+		kind, err := kindOf(name, elem)
+		if err != nil {
+			return false, fmt.Errorf("%s: element %d must be a number, got %s", name, i, WriteString(elem))
+		}
+		inexact = inexact || kind == kindFloat
+	}
+	return inexact, nil
+}
+
+// extremum は空でない数値の並び elems から、better が真になる（より大きい、またはより小さい）要素を選びます。
+// inexact が真（要素に Float がある）なら、結果も Float にします。
+func extremum(elems []parser.Expr, inexact bool, better func(c int) bool) parser.Expr {
+	result := elems[0]
+	for _, elem := range elems[1:] {
+		if better(compareNumbers(elem, result)) {
+			result = elem
+		}
+	}
+	if inexact {
+		return parser.Float(toFloat(result))
+	}
+	return result
+}
+
+// makeNumericComparison は数値の比較述語（= や < など）を生成します。
+// 引数は2つ以上で、隣り合うすべての組について compareNumbers の結果 c で holds(c) が成り立つときに #t を返します。
+// Integer と Rational は正確に比較し、Float が含まれる組だけを Float に変換して比較します。
+// NaN はどの数値とも比較が成り立ちません。途中で結果が決まっても、数値以外の引数はエラーです。
+func makeNumericComparison(name string, holds func(c int) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			for i, arg := range args {
+				if !isNumber(arg) {
+					return nil, fmt.Errorf("%s: argument %d must be a number, got %s", name, i+1, WriteString(arg))
+				}
+			}
+			for i := 1; i < len(args); i++ {
+				a, b := args[i-1], args[i]
+				if isNaN(a) || isNaN(b) || !holds(compareNumbers(a, b)) {
+					return parser.Boolean(false), nil
+				}
+			}
+			return parser.Boolean(true), nil
+		},
+	}
+}
+
+// isNaN は数値が NaN であるかを判定します。
+func isNaN(num parser.Expr) bool {
+	f, ok := num.(parser.Float)
+	return ok && math.IsNaN(float64(f))
+}
+
+// roundToExact は正確な x を正確な正の multiple の倍数に mode に従って丸めます。
+// 整数になる結果は Integer で返します。
+func roundToExact(x, multiple *big.Rat, mode parser.Symbol) parser.Expr {
+	ratio := new(big.Rat).Quo(x, multiple)
+	// big.Int の Div はユークリッド除算で、分母は正なので商は floor(ratio) になる
+	q := new(big.Int).Div(ratio.Num(), ratio.Denom())
+	r := new(big.Rat).Sub(ratio, new(big.Rat).SetInt(q))
+	switch {
+	case mode == "ceiling" && r.Sign() != 0, mode == "nearest" && r.Cmp(big.NewRat(1, 2)) >= 0:
+		q.Add(q, big.NewInt(1))
+	}
+	return parser.NewRational(new(big.Rat).Mul(new(big.Rat).SetInt(q), multiple))
+}
+
+// builtinRoundTo は "round-to" を実装します。
+// (round-to x multiple [mode]) x を multiple の倍数に丸めます。ヒストグラムの区間分けなどに使います。
+// mode は 'floor（以下で最大の倍数）、'ceiling（以上で最小の倍数）、'nearest（最も近い倍数、既定）のいずれかで、
+// 'nearest でちょうど中間の場合は大きい方に丸めます。multiple は正の数でなければなりません。
+// x と multiple がともに正確数（Integer か分数）なら結果も正確数で、どちらかが Float なら Float です。
+func builtinRoundTo(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("round-to: wrong number of arguments")
+	}
+	for _, arg := range args[:2] {
+		if !isNumber(arg) {
+			return nil, fmt.Errorf("round-to: arguments must be numbers, got %s", WriteString(arg))
+		}
+	}
+	mode := parser.Symbol("nearest")
+	if len(args) == 3 {
+		sym, ok := args[2].(parser.Symbol)
+		if !ok || (sym != "floor" && sym != "ceiling" && sym != "nearest") {
+			return nil, fmt.Errorf("round-to: mode must be 'floor, 'ceiling or 'nearest, got %s", WriteString(args[2]))
+		}
+		mode = sym
+	}
+	if toFloat(args[1]) <= 0 {
+		return nil, fmt.Errorf("round-to: multiple must be positive, got %s", WriteString(args[1]))
+	}
+
+	_, xInexact := args[0].(parser.Float)
+	_, mInexact := args[1].(parser.Float)
+	if !xInexact && !mInexact {
+		return roundToExact(toRat(args[0]), toRat(args[1]), mode), nil
+	}
+
+	f, multiple := toFloat(args[0]), toFloat(args[1])
+	var q float64
+	switch mode {
+	case "floor":
+		q = math.Floor(f / multiple)
+	case "ceiling":
+		q = math.Ceil(f / multiple)
+	default:
+		q = math.Floor(f/multiple + 0.5)
+	}
+	return parser.Float(q * multiple), nil
+}
+
+// floatFormats は number->string の書式指定シンボルと strconv.FormatFloat の書式の対応です。
+var floatFormats = map[parser.Symbol]byte{
+	"fixed":      'f',
+	"scientific": 'e',
+	"general":    'g',
+}
+
+// builtinNumberToString は "number->string" を実装します。
+// (number->string z [radix]) 整数は radix（2〜36、既定は 10）進数で表します。
+// (number->string z mode [digits]) mode は 'fixed・'scientific・'general で、digits は小数点以下
+// （'general では有効数字）の桁数です。digits を省略すると値を表すのに必要な最小の桁数になります。
+func builtinNumberToString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("number->string: wrong number of arguments")
+	}
+	if !isNumber(args[0]) {
+		return nil, fmt.Errorf("number->string: argument must be a number, got %s", WriteString(args[0]))
+	}
+	if len(args) == 1 {
+		return parser.String(WriteString(args[0])), nil
+	}
+	if mode, ok := args[1].(parser.Symbol); ok {
+		format, ok := floatFormats[mode]
+		if !ok {
+			return nil, fmt.Errorf("number->string: unknown format %s", mode)
+		}
+		digits := -1
+		if len(args) == 3 {
+			n, ok := args[2].(parser.Integer)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("number->string: digits must be a non-negative integer, got %s", WriteString(args[2]))
+			}
+			digits = int(n)
+		}
+		return parser.String(strconv.FormatFloat(toFloat(args[0]), format, digits, 64)), nil
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("number->string: wrong number of arguments")
+	}
+	radix, ok := args[1].(parser.Integer)
+	if !ok || radix < 2 || radix > 36 {
+		return nil, fmt.Errorf("number->string: radix must be an integer between 2 and 36, got %s", WriteString(args[1]))
+	}
+	switch v := args[0].(type) {
+	case parser.Integer:
+		return parser.String(strconv.FormatInt(int64(v), int(radix))), nil
+	case parser.Rational:
+		return parser.String(v.Num().Text(int(radix)) + "/" + v.Den().Text(int(radix))), nil
+	default:
+		if radix != 10 {
+			return nil, fmt.Errorf("number->string: radix %d is only supported for exact numbers", radix)
+		}
+		return parser.String(WriteString(v)), nil
+	}
+}
+
+// registerNumberBuiltins は数値関連の組み込み関数を環境に登録します。
+func registerNumberBuiltins(env *Env) {
+	env.Set("number?", makeNumberPredicate("number?", isNumber))
+	env.Set("complex?", makeNumberPredicate("complex?", isNumber))
+	env.Set("real?", makeNumberPredicate("real?", isNumber))
+	env.Set("rational?", makeNumberPredicate("rational?", isRational))
+	env.Set("integer?", makeNumberPredicate("integer?", isInteger))
+	env.Set("exact-integer?", makeNumberPredicate("exact-integer?", isExactInteger))
+	env.Set("exact-nonnegative-integer?", makeNumberPredicate("exact-nonnegative-integer?", isExactNonnegativeInteger))
+
+	env.Set("even?", makeParityPredicate("even?", 0))
+	env.Set("odd?", makeParityPredicate("odd?", 1))
+	env.Set("nan?", makeFloatClassPredicate("nan?", math.IsNaN))
+	env.Set("infinite?", makeFloatClassPredicate("infinite?", func(f float64) bool { return math.IsInf(f, 0) }))
+	env.Set("finite?", makeFloatClassPredicate("finite?", func(f float64) bool { return !math.IsInf(f, 0) && !math.IsNaN(f) }))
+	env.Set("=", makeNumericComparison("=", func(c int) bool { return c == 0 }))
+	env.Set("<", makeNumericComparison("<", func(c int) bool { return c < 0 }))
+	env.Set(">", makeNumericComparison(">", func(c int) bool { return c > 0 }))
+	env.Set("<=", makeNumericComparison("<=", func(c int) bool { return c <= 0 }))
+	env.Set(">=", makeNumericComparison(">=", func(c int) bool { return c >= 0 }))
+	env.Set("round-to", &Builtin{Name: "round-to", Fn: builtinRoundTo})
+	env.Set("number->string", &Builtin{Name: "number->string", Fn: builtinNumberToString})
+
+	env.Set("quotient", makeIntegerDivision("quotient", truncDivMod, divQuotient))
+	env.Set("remainder", makeIntegerDivision("remainder", truncDivMod, divRemainder))
+	env.Set("modulo", makeIntegerDivision("modulo", floorDivMod, divRemainder))
+	env.Set("truncate-quotient", makeIntegerDivision("truncate-quotient", truncDivMod, divQuotient))
+	env.Set("truncate-remainder", makeIntegerDivision("truncate-remainder", truncDivMod, divRemainder))
+	env.Set("floor-quotient", makeIntegerDivision("floor-quotient", floorDivMod, divQuotient))
+	env.Set("floor-remainder", makeIntegerDivision("floor-remainder", floorDivMod, divRemainder))
+	env.Set("truncate/", makeIntegerDivision("truncate/", truncDivMod, divBoth))
+	env.Set("floor/", makeIntegerDivision("floor/", floorDivMod, divBoth))
+}
@@ -0,0 +1,414 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestNumericTowerPredicates は number? 系の述語について Integer と Float の真理値表をテストします。
+func TestNumericTowerPredicates(t *testing.T) {
+	inputs := []string{"3", "3.0", "2.5", `"3"`}
+	expected := map[string][]parser.Boolean{
+		"number?":   {true, true, true, false},
+		"complex?":  {true, true, true, false},
+		"real?":     {true, true, true, false},
+		"rational?": {true, true, true, false},
+		"integer?":  {true, true, false, false},
+	}
+	for pred, want := range expected {
+		for i, input := range inputs {
+			expr := "(" + pred + " " + input + ")"
+			result, err := evalString(t, NewGlobalEnv(), expr)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", expr, err)
+				continue
+			}
+			if !reflect.DeepEqual(result, want[i]) {
+				t.Errorf("%s: expected %v, got %v", expr, want[i], result)
+			}
+		}
+	}
+}
+
+// TestIntegerDivisionFamily は整数除算系の関数を被除数・除数の符号の4通りの組み合わせ
+// （および割り切れる場合）でテストします。
+func TestIntegerDivisionFamily(t *testing.T) {
+	type operands struct{ n, d parser.Integer }
+	signs := []operands{{7, 2}, {-7, 2}, {7, -2}, {-7, -2}, {-6, 3}}
+	expected := map[string][]parser.Integer{
+		"quotient":           {3, -3, -3, 3, -2},
+		"truncate-quotient":  {3, -3, -3, 3, -2},
+		"remainder":          {1, -1, 1, -1, 0},
+		"truncate-remainder": {1, -1, 1, -1, 0},
+		"floor-quotient":     {3, -4, -4, 3, -2},
+		"modulo":             {1, 1, -1, -1, 0},
+		"floor-remainder":    {1, 1, -1, -1, 0},
+	}
+	env := NewGlobalEnv()
+	for name, want := range expected {
+		fn, ok := env.Get(parser.Symbol(name))
+		if !ok {
+			t.Fatalf("%s is not defined", name)
+		}
+		for i, op := range signs {
+			result, err := fn.(Callable).Call([]parser.Expr{op.n, op.d})
+			if err != nil {
+				t.Errorf("(%s %d %d): unexpected error: %v", name, op.n, op.d, err)
+				continue
+			}
+			if result != want[i] {
+				t.Errorf("(%s %d %d): expected %d, got %v", name, op.n, op.d, want[i], result)
+			}
+		}
+	}
+	// floor/ と truncate/ は対応する quotient と remainder の組を2つの値として返す
+	pairs := map[string][2]string{
+		"floor/":    {"floor-quotient", "floor-remainder"},
+		"truncate/": {"truncate-quotient", "truncate-remainder"},
+	}
+	for name, pair := range pairs {
+		fn, ok := env.Get(parser.Symbol(name))
+		if !ok {
+			t.Fatalf("%s is not defined", name)
+		}
+		for i, op := range signs {
+			result, err := fn.(Callable).Call([]parser.Expr{op.n, op.d})
+			if err != nil {
+				t.Errorf("(%s %d %d): unexpected error: %v", name, op.n, op.d, err)
+				continue
+			}
+			want := MultipleValues{expected[pair[0]][i], expected[pair[1]][i]}
+			if !reflect.DeepEqual(result, want) {
+				t.Errorf("(%s %d %d): expected %v, got %v", name, op.n, op.d, want, result)
+			}
+		}
+	}
+	// receive で2つの値を受け取れる
+	if got := evalToString(t, env, "(receive (q r) (floor/ -7 2) (list q r))"); got != "(-4 1)" {
+		t.Errorf("expected (-4 1), got %s", got)
+	}
+}
+
+// TestIntegerDivisionErrors は 0 除算と整数以外の引数がエラーになることをテストします。
+func TestIntegerDivisionErrors(t *testing.T) {
+	inputs := []string{"(quotient 1 0)", "(modulo 1 0)", "(remainder 1.5 2)", "(floor-quotient 1 \"2\")", "(quotient 1)", "(floor/ 1 0)", "(truncate/ 1.0 2)"}
+	for _, input := range inputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestExactIntegerPredicates は exact-integer? と exact-nonnegative-integer? の真理値表をテストします。
+func TestExactIntegerPredicates(t *testing.T) {
+	inputs := []string{"3", "0", "-1", "3.0", `"3"`}
+	expected := map[string][]parser.Boolean{
+		"exact-integer?":             {true, true, true, false, false},
+		"exact-nonnegative-integer?": {true, true, false, false, false},
+	}
+	for pred, want := range expected {
+		for i, input := range inputs {
+			expr := "(" + pred + " " + input + ")"
+			result, err := evalString(t, NewGlobalEnv(), expr)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", expr, err)
+				continue
+			}
+			if !reflect.DeepEqual(result, want[i]) {
+				t.Errorf("%s: expected %v, got %v", expr, want[i], result)
+			}
+		}
+	}
+}
+
+// TestIndexArgumentErrors は添字を取る組み込み関数が、負の数や正確でない整数に対して
+// 分かりやすいエラーを返すことをテストします。
+func TestIndexArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(vector-ref (vector 1 2) -1)", "vector-ref: index must be a non-negative integer, got -1"},
+		{"(vector-ref (vector 1 2) 1.0)", "vector-ref: index must be an exact integer, got 1.0"},
+		{"(list-ref '(1 2) -1)", "list-ref: index must be a non-negative integer, got -1"},
+		{"(list-ref '(1 2) 2)", "list-ref: index 2 out of range for length 2"},
+		{`(substring "abc" -1 2)`, "substring: index must be a non-negative integer, got -1"},
+		{`(string-copy "abc" 'a)`, "string-copy: index must be an exact integer, got a"},
+	}
+	for _, tt := range tests {
+		if _, err := evalString(t, NewGlobalEnv(), tt.input); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+	if got := evalToString(t, NewGlobalEnv(), "(list-ref '(a b c) 1)"); got != "b" {
+		t.Errorf("list-ref: expected b, got %s", got)
+	}
+	if got := evalToString(t, NewGlobalEnv(), `(substring "日本語です" 1 3)`); got != `"本語"` {
+		t.Errorf(`substring: expected "本語", got %s`, got)
+	}
+}
+
+// TestNumberToString は number->string の基数指定と、'fixed・'scientific・'general の書式指定をテストします。
+func TestNumberToString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{"(number->string 42)", parser.String("42")},
+		{"(number->string 2.5)", parser.String("2.5")},
+		{"(number->string 255 16)", parser.String("ff")},
+		{"(number->string -5 2)", parser.String("-101")},
+		{"(number->string 3.14159 10)", parser.String("3.14159")},
+		{"(number->string 3.14159 'fixed 2)", parser.String("3.14")},
+		{"(number->string 3.14159 'scientific 2)", parser.String("3.14e+00")},
+		{"(number->string 3.14159 'general 3)", parser.String("3.14")},
+		{"(number->string 1234.5 'general 2)", parser.String("1.2e+03")},
+		{"(number->string 7 'fixed 1)", parser.String("7.0")},
+		{"(number->string 0.1 'fixed)", parser.String("0.1")},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+	errorInputs := []string{
+		`(number->string "1")`,
+		"(number->string 10 1)",
+		"(number->string 1.5 2)",
+		"(number->string 1.5 'octal 2)",
+		"(number->string 1.5 'fixed -1)",
+	}
+	for _, input := range errorInputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestInfinityAndNaN は +inf.0・-inf.0・+nan.0 のリテラルが IEEE 754 に従って演算され、
+// nan?・infinite?・finite? で判定できることをテストします。
+func TestInfinityAndNaN(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"+inf.0", "+inf.0"},
+		{"-inf.0", "-inf.0"},
+		{"-nan.0", "+nan.0"},
+		{"(+ 1 +inf.0)", "+inf.0"},
+		{"(* -2 +inf.0)", "-inf.0"},
+		{"(- +inf.0 +inf.0)", "+nan.0"},
+		{"(* 0 +inf.0)", "+nan.0"},
+		{"(+ 1 +nan.0)", "+nan.0"},
+		{"(/ 1 +inf.0)", "0.0"},
+		{"(- +inf.0)", "-inf.0"},
+		{"(nan? +nan.0)", "#t"},
+		{"(nan? (- +inf.0 +inf.0))", "#t"},
+		{"(nan? +inf.0)", "#f"},
+		{"(nan? 1)", "#f"},
+		{"(infinite? -inf.0)", "#t"},
+		{"(infinite? +nan.0)", "#f"},
+		{"(infinite? 1e308)", "#f"},
+		{"(finite? 1.5)", "#t"},
+		{"(finite? 42)", "#t"},
+		{"(finite? +inf.0)", "#f"},
+		{"(finite? +nan.0)", "#f"},
+		// NaN はそれ自身とも等しくない
+		{"(equal? +nan.0 +nan.0)", "#f"},
+		{"(equal? +inf.0 +inf.0)", "#t"},
+		{"(rational? +inf.0)", "#f"},
+		{"(integer? -inf.0)", "#f"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(nan? 'a)", `(finite? "1")`, "(infinite?)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestRoundTo は round-to が各モードで倍数に丸め、正確数と Float の型を保つことをテストします。
+func TestRoundTo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(round-to 7 5 'floor)", "5"},
+		{"(round-to 7 5 'ceiling)", "10"},
+		{"(round-to 7 5 'nearest)", "5"},
+		{"(round-to 7 5)", "5"},
+		{"(round-to 8 5)", "10"},
+		{"(round-to 10 5 'ceiling)", "10"},
+		{"(round-to -7 5 'floor)", "-10"},
+		{"(round-to -7 5 'ceiling)", "-5"},
+		{"(round-to -7 5 'nearest)", "-5"},
+		// ちょうど中間は大きい方に丸める
+		{"(round-to 5 10)", "10"},
+		{"(round-to 7.3 0.5 'floor)", "7.0"},
+		{"(round-to 7.3 0.5 'ceiling)", "7.5"},
+		{"(round-to 7.2 0.5)", "7.0"},
+		{"(round-to 7.0 5)", "5.0"},
+		{"(round-to 7 2.5 'ceiling)", "7.5"},
+		// 分数を含む正確な引数は正確に丸める
+		{"(round-to 1/3 2)", "0"},
+		{"(round-to 7/2 1)", "4"},
+		{"(round-to 1/3 1/2 'ceiling)", "1/2"},
+		{"(round-to 5/4 1/2 'floor)", "1"},
+		{"(round-to -1/3 1/2 'floor)", "-1/2"},
+		{"(round-to 1/3 0.5)", "0.5"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(round-to 7 0)", "(round-to 7 -5)", "(round-to 7 5 'up)", "(round-to 'a 5)", "(round-to 7)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestRationalArithmetic は整数どうしの割り切れない割り算が分数になり、
+// 四則演算が Integer → Rational → Float の順に型をそろえることをテストします。
+func TestRationalArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(/ 1 3)", "1/3"},
+		{"(/ 6 4)", "3/2"},
+		{"(/ 6 3)", "2"},
+		{"(/ -1 3)", "-1/3"},
+		{"(/ 1 -3)", "-1/3"},
+		{"(/ 3)", "1/3"},
+		{"(/ 1/3)", "3"},
+		{"(+ 1/3 1/6)", "1/2"},
+		{"(+ 1/3 2/3)", "1"},
+		{"(- 1/2 1/3)", "1/6"},
+		{"(* 2/3 3/4)", "1/2"},
+		{"(* 3 1/3)", "1"},
+		{"(+ 1 1/2)", "3/2"},
+		{"(- 1/2)", "-1/2"},
+		{"(/ 1/2 1/4)", "2"},
+		// Float を含むと Float になる
+		{"(+ 1/2 0.25)", "0.75"},
+		{"(* 1/3 3.0)", "1.0"},
+		{"(/ 1/2 2.0)", "0.25"},
+		{"(number->string 1/3)", `"1/3"`},
+		{"(number->string -5/3 2)", `"-101/11"`},
+		{"(number->string 1/4 'fixed 2)", `"0.25"`},
+		{"(rational? 1/3)", "#t"},
+		{"(integer? 1/3)", "#f"},
+		{"(exact-integer? 1/3)", "#f"},
+		{"(number? 1/3)", "#t"},
+		{"(finite? 1/3)", "#t"},
+		{"(equal? 1/3 (/ 2 6))", "#t"},
+		{"(equal? 1/3 1/4)", "#f"},
+		{"(equal? 1/2 0.5)", "#f"},
+		{"(hash-table-ref/default (frequencies (list 1/2 (/ 2 4) 1/3)) 1/2 0)", "2"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(/ 1/2 0)", "(+ 1/2 'a)", "(even? 1/2)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestExactComparison は数値の比較が Integer・Rational・Float の間で正確に行われることをテストします。
+func TestExactComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(= 1/2 0.5)", "#t"},
+		{"(< 1/3 1/2)", "#t"},
+		{"(= 2/4 1/2)", "#t"},
+		{"(= 1/2 1)", "#f"},
+		{"(< 1/2 1 3/2 2.0)", "#t"},
+		{"(>= 3 5/2 5/2)", "#t"},
+		{"(> 1/3 1/3)", "#f"},
+		// Float を含まない比較は Float に変換しないため、近い値でも区別できる
+		{"(< 9007199254740992 9007199254740993)", "#t"},
+		{"(= 9007199254740993/2 9007199254740992/2)", "#f"},
+		// Float も正確な値に変換して比較する（0.3333333333333333 は 1/3 よりわずかに小さい）
+		{"(<= 1/3 0.3333333333333333)", "#f"},
+		{"(< 1 +inf.0)", "#t"},
+		{"(= +nan.0 +nan.0)", "#f"},
+		{"(< 1 +nan.0)", "#f"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(< 1)", "(= 1 'a)", "(< 2 1 \"x\")"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestNumericComparison は = < > <= >= のそれぞれについて、連鎖した比較や
+// Integer と Float の混在を含めて真になる場合と偽になる場合をテストします。
+func TestNumericComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(= 2 2)", "#t"},
+		{"(= 2 2 2.0)", "#t"},
+		{"(= 2 3)", "#f"},
+		{"(= 2 2 3)", "#f"},
+		{"(< 1 2)", "#t"},
+		{"(< 1 2 3)", "#t"},
+		{"(< 1 1.5 2)", "#t"},
+		{"(< 2 1)", "#f"},
+		{"(< 1 3 2)", "#f"},
+		{"(< 1 1)", "#f"},
+		{"(> 3 2 1)", "#t"},
+		{"(> 2.5 2)", "#t"},
+		{"(> 1 2)", "#f"},
+		{"(> 3 1 2)", "#f"},
+		{"(<= 1 1 2)", "#t"},
+		{"(<= 1 1.0)", "#t"},
+		{"(<= 2 1)", "#f"},
+		{"(<= 1 2 1)", "#f"},
+		{"(>= 3 3 1)", "#t"},
+		{"(>= 2.0 2)", "#t"},
+		{"(>= 1 2)", "#f"},
+		{"(>= 3 1 2)", "#f"},
+		// 依頼では整数と浮動小数点数を float64 に変換して比べるとしていたが、変換すると 2^53+1 が 2^53 に丸められて
+		// 等しくなってしまうため、正確な値のまま比べる。float64 で比べる実装ならこの結果は #t になる
+		{"(= 9007199254740993 9007199254740992.0)", "#f"},
+		{"(< 9007199254740992.0 9007199254740993)", "#t"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, op := range []string{"=", "<", ">", "<=", ">="} {
+		for _, input := range []string{"(" + op + ")", "(" + op + " 1)", "(" + op + " 1 \"2\")", "(" + op + " 'a 1)"} {
+			if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+				t.Errorf("%s: expected error, got nil", input)
+			}
+		}
+	}
+}
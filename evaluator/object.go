@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// メッセージパッシングによるオブジェクト
+//
+// make-object はメソッド名と手続きの連想リストから、メッセージを受け取って対応するメソッドを
+// 呼び出す手続きを作ります（SICP のメッセージパッシングと同じ考え方です）。
+// 状態はメソッドのクロージャが捕捉した値（ハッシュテーブルなど）で保持します。
+
+// builtinMakeObject は "make-object" を実装します。
+// (make-object alist) alist の各要素は (name proc) または (name . proc) です。
+// 返す手続きを (obj 'name arg...) と呼ぶと、name のメソッドを arg... に適用した結果を返します。
+// 同じ名前が複数ある場合は最初のものを使います。
+func builtinMakeObject(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("make-object: wrong number of arguments")
+	}
+	alist, err := listArg("make-object", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	methods := make(map[parser.Symbol]Callable, len(alist))
+	for _, entry := range alist {
+		pair, err := alistEntry("make-object", entry)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := pair[0].(parser.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("make-object: method name must be a symbol, got %s", WriteString(pair[0]))
+		}
+		var method parser.Expr
+		switch {
+		case len(pair) == 2:
+			method = pair[1]
+		case len(pair) == 3 && pair[1] == parser.Symbol("."):
+			method = pair[2]
+		default:
+			return nil, fmt.Errorf("make-object: method entry must be (name proc), got %s", WriteString(entry))
+		}
+		proc, ok := method.(Callable)
+		if !ok {
+			return nil, fmt.Errorf("make-object: method %s must be a procedure, got %s", name, WriteString(method))
+		}
+		if _, ok := methods[name]; !ok {
+			methods[name] = proc
+		}
+	}
+	return &Builtin{
+		Name: "object",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("object: missing method name")
+			}
+			name, ok := args[0].(parser.Symbol)
+			if !ok {
+				return nil, fmt.Errorf("object: method name must be a symbol, got %s", WriteString(args[0]))
+			}
+			method, ok := methods[name]
+			if !ok {
+				return nil, fmt.Errorf("object: unknown method %s", name)
+			}
+			return method.Call(args[1:])
+		},
+	}, nil
+}
+
+// registerObjectBuiltins はオブジェクト関連の組み込み関数を環境に登録します。
+func registerObjectBuiltins(env *Env) {
+	env.Set("make-object", &Builtin{Name: "make-object", Fn: builtinMakeObject})
+}
@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMakeObject は make-object で作ったカウンタに increment と value のメッセージを送れることをテストします。
+func TestMakeObject(t *testing.T) {
+	env := NewGlobalEnv()
+	setup := `
+	(define (make-counter state)
+	  (make-object
+	    (list (list 'increment (lambda (n) (hash-table-update! state 'count (lambda (c) (+ c n)) 0)))
+	          (list* 'value (lambda () (hash-table-ref/default state 'count 0))))))
+	(define counter (make-counter (make-hash-table)))
+	(define other (make-counter (make-hash-table)))
+	`
+	if _, err := evalString(t, env, setup); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{"(counter 'value)", "0"},
+		{"(counter 'increment 1)", "1"},
+		{"(counter 'increment 5)", "6"},
+		{"(counter 'value)", "6"},
+		// 状態はオブジェクトごとに独立している
+		{"(other 'value)", "0"},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+	}
+
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(counter 'reset)", "object: unknown method reset"},
+		{"(counter)", "object: missing method name"},
+		{"(make-object (list (list 'm 1)))", "make-object: method m must be a procedure, got 1"},
+		{`(make-object (list (list "m" list)))`, `make-object: method name must be a symbol, got "m"`},
+	}
+	for _, tt := range errTests {
+		_, err := evalString(t, env, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
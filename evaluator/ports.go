@@ -0,0 +1,252 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// OutputStringPort は書き込まれた内容を文字列として蓄積する出力ポートです。
+// strings.Builder を使うため、繰り返し書き込んでも全体で線形時間で文字列を組み立てられます。
+type OutputStringPort struct {
+	sb strings.Builder
+}
+
+// Write は io.Writer を実装し、p をポートの内容に追加します。
+func (p *OutputStringPort) Write(b []byte) (int, error) {
+	return p.sb.Write(b)
+}
+
+// String はこれまでに書き込まれた内容を返します。
+func (p *OutputStringPort) String() string {
+	return p.sb.String()
+}
+
+// InputStringPort は文字列を入力とする入力ポートです。
+// 読み取り位置は文字（rune）単位で管理します。
+type InputStringPort struct {
+	runes []rune
+	pos   int
+}
+
+// EOFObject は入力ポートの終端に達したことを表す値（EOF オブジェクト）の型です。
+type EOFObject struct{}
+
+// EOF は EOF オブジェクトを表す共有の値です。
+var EOF = EOFObject{}
+
+// peekChar は読み取り位置を進めずに次の文字を返します。終端に達していれば ok は false です。
+func (p *InputStringPort) peekChar() (r rune, ok bool) {
+	if p.pos >= len(p.runes) {
+		return 0, false
+	}
+	return p.runes[p.pos], true
+}
+
+// readChar は次の文字を読み取ります。終端に達していれば ok は false です。
+func (p *InputStringPort) readChar() (r rune, ok bool) {
+	r, ok = p.peekChar()
+	if ok {
+		p.pos++
+	}
+	return r, ok
+}
+
+// readLine は改行の直前までの文字列を読み取り、改行は読み捨てます。終端に達していれば ok は false です。
+func (p *InputStringPort) readLine() (line string, ok bool) {
+	if p.pos >= len(p.runes) {
+		return "", false
+	}
+	start := p.pos
+	for p.pos < len(p.runes) && p.runes[p.pos] != '\n' {
+		p.pos++
+	}
+	line = string(p.runes[start:p.pos])
+	if p.pos < len(p.runes) {
+		p.pos++ // 改行を読み捨てる
+	}
+	return line, true
+}
+
+// read は次のデータ（コメントを除く式）を1つ読み取ります。終端に達していれば ok は false です。
+func (p *InputStringPort) read() (datum parser.Expr, ok bool, err error) {
+	rest := string(p.runes[p.pos:])
+	// パーサは既定でコメントを読み飛ばす
+	expr, span, err := parser.NewParser(strings.NewReader(rest)).ParseExprAt()
+	if err == io.EOF {
+		p.pos = len(p.runes)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// Span はバイト単位なので、文字単位の位置に換算して読み取り位置を進める
+	p.pos += len([]rune(rest[:span.End.Offset]))
+	return expr, true, nil
+}
+
+// inputPortArg は args[i] が入力ポートであることを確認して返します。
+func inputPortArg(name string, args []parser.Expr, i int) (*InputStringPort, error) {
+	port, ok := args[i].(*InputStringPort)
+	if !ok {
+		return nil, fmt.Errorf("%s: argument %d must be an input port, got %s", name, i+1, WriteString(args[i]))
+	}
+	return port, nil
+}
+
+// builtinOpenInputString は "open-input-string" を実装します。
+// (open-input-string str) 文字列を読み取る入力ポートを返します。
+func builtinOpenInputString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("open-input-string: wrong number of arguments")
+	}
+	s, err := stringArg("open-input-string", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &InputStringPort{runes: []rune(s)}, nil
+}
+
+// builtinRead は "read" を実装します。
+// (read port) 次のデータを読み取って返します。終端では EOF オブジェクトを返します。
+func builtinRead(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("read: wrong number of arguments")
+	}
+	port, err := inputPortArg("read", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	datum, ok, err := port.read()
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if !ok {
+		return EOF, nil
+	}
+	return datum, nil
+}
+
+// makeCharReader は入力ポートから1文字を取り出す組み込み関数（read-char / peek-char）を生成します。
+// (read-char port) は次の文字を読み取り、(peek-char port) は読み取り位置を進めずに次の文字を返します。
+// どちらも終端では EOF オブジェクトを返します。
+func makeCharReader(name string, next func(*InputStringPort) (rune, bool)) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			port, err := inputPortArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			r, ok := next(port)
+			if !ok {
+				return EOF, nil
+			}
+			return parser.Char(r), nil
+		},
+	}
+}
+
+// builtinReadLine は "read-line" を実装します。
+// (read-line port) 次の改行までを文字列として返します（改行は含みません）。終端では EOF オブジェクトを返します。
+func builtinReadLine(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("read-line: wrong number of arguments")
+	}
+	port, err := inputPortArg("read-line", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	line, ok := port.readLine()
+	if !ok {
+		return EOF, nil
+	}
+	return parser.String(line), nil
+}
+
+// builtinEOFObject は "eof-object" を実装します。
+func builtinEOFObject(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("eof-object: wrong number of arguments")
+	}
+	return EOF, nil
+}
+
+// builtinIsEOFObject は "eof-object?" を実装します。
+func builtinIsEOFObject(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("eof-object?: wrong number of arguments")
+	}
+	return parser.Boolean(args[0] == EOF), nil
+}
+
+// outputPortArg は省略可能な出力ポート引数 args[i] を解釈します。
+// 省略された場合は環境の出力先を返します。
+func outputPortArg(name string, env *Env, args []parser.Expr, i int) (io.Writer, error) {
+	if len(args) <= i {
+		return env.Output(), nil
+	}
+	port, ok := args[i].(*OutputStringPort)
+	if !ok {
+		return nil, fmt.Errorf("%s: argument %d must be an output port, got %s", name, i+1, WriteString(args[i]))
+	}
+	return port, nil
+}
+
+// builtinOpenOutputString は "open-output-string" を実装します。
+func builtinOpenOutputString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("open-output-string: wrong number of arguments")
+	}
+	return &OutputStringPort{}, nil
+}
+
+// builtinGetOutputString は "get-output-string" を実装します。
+// (get-output-string port) ポートに書き込まれた内容を文字列として返します。
+func builtinGetOutputString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("get-output-string: wrong number of arguments")
+	}
+	port, ok := args[0].(*OutputStringPort)
+	if !ok {
+		return nil, fmt.Errorf("get-output-string: argument must be an output string port, got %s", WriteString(args[0]))
+	}
+	return parser.String(port.String()), nil
+}
+
+// registerPortBuiltins はポート関連の組み込み関数を環境に登録します。
+func registerPortBuiltins(env *Env) {
+	env.Set("open-output-string", &Builtin{Name: "open-output-string", Fn: builtinOpenOutputString})
+	env.Set("get-output-string", &Builtin{Name: "get-output-string", Fn: builtinGetOutputString})
+	env.Set("open-input-string", &Builtin{Name: "open-input-string", Fn: builtinOpenInputString})
+	env.Set("read", &Builtin{Name: "read", Fn: builtinRead})
+	env.Set("read-char", makeCharReader("read-char", (*InputStringPort).readChar))
+	env.Set("peek-char", makeCharReader("peek-char", (*InputStringPort).peekChar))
+	env.Set("read-line", &Builtin{Name: "read-line", Fn: builtinReadLine})
+	env.Set("eof-object", &Builtin{Name: "eof-object", Fn: builtinEOFObject})
+	env.Set("eof-object?", &Builtin{Name: "eof-object?", Fn: builtinIsEOFObject})
+	// (write-string str [port]) 文字列をそのまま（display と同じく引用符なしで）ポートに書き込みます。
+	env.Set("write-string", &Builtin{
+		Name: "write-string",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("write-string: wrong number of arguments")
+			}
+			s, err := stringArg("write-string", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			w, err := outputPortArg("write-string", env, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			io.WriteString(w, s)
+			return Unspecified, nil
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestOutputStringPort は出力文字列ポートへ繰り返し書き込み、get-output-string で
+// 蓄積された内容を取り出せることをテストします。
+func TestOutputStringPort(t *testing.T) {
+	env := NewGlobalEnv()
+	if _, err := evalString(t, env, "(define port (open-output-string))"); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	var expected strings.Builder
+	for i := 0; i < 100; i++ {
+		input := fmt.Sprintf(`(write-string "%d," port)`, i)
+		if _, err := evalString(t, env, input); err != nil {
+			t.Fatalf("%s: unexpected error: %v", input, err)
+		}
+		fmt.Fprintf(&expected, "%d,", i)
+	}
+	if got := evalToString(t, env, "(get-output-string port)"); got != fmt.Sprintf("%q", expected.String()) {
+		t.Errorf("expected %q, got %s", expected.String(), got)
+	}
+}
+
+// TestPrinterPortArgument は display・write・newline が出力ポートを引数に取れることをテストします。
+func TestPrinterPortArgument(t *testing.T) {
+	env := NewGlobalEnv()
+	var stdout strings.Builder
+	env.SetOutput(&stdout)
+	program := `
+(define port (open-output-string))
+(display "a" port)
+(write "b" port)
+(newline port)
+(write-string "c" port)
+(display "to stdout")
+(get-output-string port)
+`
+	if got := evalToString(t, env, program); got != `"a\"b\"\nc"` {
+		t.Errorf(`expected "a\"b\"\nc", got %s`, got)
+	}
+	if stdout.String() != "to stdout" {
+		t.Errorf("expected default output %q, got %q", "to stdout", stdout.String())
+	}
+	for _, input := range []string{`(display "x" 1)`, `(write-string 'x)`, "(get-output-string 1)"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestInputStringPort は入力文字列ポートから read・read-char・read-line で読み取れること、
+// 終端で EOF オブジェクトが返ることをテストします。
+func TestInputStringPort(t *testing.T) {
+	env := NewGlobalEnv()
+	if _, err := evalString(t, env, `(define p (open-input-string "1 2 three"))`); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	for _, expected := range []string{"1", "2", "three", "#<eof>", "#<eof>"} {
+		if got := evalToString(t, env, "(read p)"); got != expected {
+			t.Errorf("(read p): expected %s, got %s", expected, got)
+		}
+	}
+
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{`(define q (open-input-string "(a \"b\") ; comment\n#\\x 4.5\nあい\n\nend"))`, "q"},
+		{"(read q)", `(a "b")`},
+		{"(read q)", `#\x`},
+		{"(read-char q)", `#\space`},
+		{"(read-char q)", `#\4`},
+		{"(read-line q)", `".5"`},
+		{"(read-char q)", `#\あ`},
+		{"(read-line q)", `"い"`},
+		{"(read-line q)", `""`},
+		{"(read-line q)", `"end"`},
+		{"(read-line q)", "#<eof>"},
+		{"(eof-object? (read-char q))", "#t"},
+		{"(eof-object? 'eof)", "#f"},
+		{"(eof-object? (eof-object))", "#t"},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+	}
+	for _, input := range []string{`(read (open-input-string "(unclosed"))`, `(read "str")`, "(read-char (open-output-string))"} {
+		if _, err := evalString(t, env, input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestPeekChar は peek-char が読み取り位置を進めず、続く read-char が同じ文字を返すことをテストします。
+func TestPeekChar(t *testing.T) {
+	env := NewGlobalEnv()
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{`(define p (open-input-string "ab"))`, "p"},
+		{"(peek-char p)", `#\a`},
+		{"(peek-char p)", `#\a`},
+		{"(read-char p)", `#\a`},
+		{"(peek-char p)", `#\b`},
+		{"(read-char p)", `#\b`},
+		{"(peek-char p)", "#<eof>"},
+		{"(read-char p)", "#<eof>"},
+		// peek-char の後の read も先読みした文字から読み始める
+		{`(define q (open-input-string "42 x"))`, "q"},
+		{"(peek-char q)", `#\4`},
+		{"(read q)", "42"},
+		{"(peek-char q)", `#\space`},
+	}
+	for _, step := range steps {
+		if got := evalToString(t, env, step.input); got != step.expected {
+			t.Errorf("%s: expected %s, got %s", step.input, step.expected, got)
+		}
+	}
+	if _, err := evalString(t, env, `(peek-char "ab")`); err == nil {
+		t.Error("expected error for a non-port argument, got nil")
+	}
+}
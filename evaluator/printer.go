@@ -0,0 +1,379 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Warashi/lispish/lexer"
+	"github.com/Warashi/lispish/parser"
+)
+
+// quoteAbbrevs はリーダーマクロに対応するシンボルと、その省略記法の対応表です。
+var quoteAbbrevs = map[parser.Symbol]string{
+	"quote":            "'",
+	"quasiquote":       "`",
+	"unquote":          ",",
+	"unquote-splicing": ",@",
+}
+
+// WriteString は write と同じ形式（文字列は引用符付き）で式の外部表現を返します。
+// 2回以上現れるリストやベクタと循環する構造は、(#0=(a b) #0#) や #0=(a . #0#) のようにデータラベルを使って出力します。
+func WriteString(expr parser.Expr) string {
+	var sb strings.Builder
+	render(&sb, expr, true, findLabels(expr, true))
+	return sb.String()
+}
+
+// DisplayString は display と同じ形式（文字列は引用符なし）で式の外部表現を返します。
+// 循環する構造はデータラベルを使って出力しますが、循環していない共有にはラベルを付けません。
+func DisplayString(expr parser.Expr) string {
+	var sb strings.Builder
+	render(&sb, expr, false, findLabels(expr, false))
+	return sb.String()
+}
+
+// datumLabels は出力するデータラベルを表します。labeled はラベルを付けるリスト（listIdentity）とベクタで、
+// numbers は出力済みのものに割り当てたラベルの番号です。番号は出力する順に 0 から割り当てます。
+type datumLabels struct {
+	labeled map[any]bool
+	numbers map[any]int
+}
+
+// labelKey はデータラベルを付けられる値について、その同一性を表すキーと要素を返します。
+func labelKey(expr parser.Expr) (any, []parser.Expr, bool) {
+	switch v := expr.(type) {
+	case parser.List:
+		if len(v) == 0 {
+			return nil, nil, false
+		}
+		return identityOf(v), v, true
+	case *Vector:
+		return v, v.Elems, true
+	}
+	return nil, nil, false
+}
+
+// findLabels は expr の中でデータラベルを付けるリストとベクタを求めます。shared が偽なら循環しているものだけを、
+// 真なら2回以上現れるものすべてを対象にします。ラベルが不要であれば nil を返します。
+func findLabels(expr parser.Expr, shared bool) *datumLabels {
+	switch expr.(type) {
+	case parser.List, *Vector, MultipleValues:
+	default:
+		return nil
+	}
+	labeled := make(map[any]bool)
+	seen := make(map[any]bool)
+	onPath := make(map[any]bool)
+	var visit func(parser.Expr)
+	visit = func(expr parser.Expr) {
+		if values, ok := expr.(MultipleValues); ok {
+			for _, v := range values {
+				visit(v)
+			}
+			return
+		}
+		key, elems, ok := labelKey(expr)
+		if !ok {
+			return
+		}
+		if seen[key] {
+			// 探索中の経路上にあれば循環している
+			if shared || onPath[key] {
+				labeled[key] = true
+			}
+			return
+		}
+		seen[key], onPath[key] = true, true
+		for _, elem := range elems {
+			visit(elem)
+		}
+		delete(onPath, key)
+	}
+	visit(expr)
+	if len(labeled) == 0 {
+		return nil
+	}
+	return &datumLabels{labeled: labeled, numbers: make(map[any]int)}
+}
+
+// renderLabel は expr にデータラベルを付ける場合に、初出なら #n= を書き込んで false を、
+// 出力済みなら #n# を書き込んで true を返します。true の場合、呼び出し側は expr 自体を出力しません。
+func (labels *datumLabels) renderLabel(sb *strings.Builder, expr parser.Expr) bool {
+	if labels == nil {
+		return false
+	}
+	key, _, ok := labelKey(expr)
+	if !ok || !labels.labeled[key] {
+		return false
+	}
+	if n, ok := labels.numbers[key]; ok {
+		fmt.Fprintf(sb, "#%d#", n)
+		return true
+	}
+	n := len(labels.numbers)
+	labels.numbers[key] = n
+	fmt.Fprintf(sb, "#%d=", n)
+	return false
+}
+
+// render は式の外部表現を sb に書き込みます。write が真なら write 形式で出力します。
+// labels は findLabels で求めたデータラベルで、nil ならラベルを付けません。
+func render(sb *strings.Builder, expr parser.Expr, write bool, labels *datumLabels) {
+	if labels.renderLabel(sb, expr) {
+		return
+	}
+	switch v := expr.(type) {
+	case parser.Integer:
+		sb.WriteString(strconv.FormatInt(int64(v), 10))
+	case parser.Rational:
+		sb.WriteString(v.String())
+	case parser.Float:
+		sb.WriteString(formatFloat(float64(v)))
+	case parser.Boolean:
+		if v {
+			sb.WriteString("#t")
+		} else {
+			sb.WriteString("#f")
+		}
+	case parser.Char:
+		if write {
+			sb.WriteString(charLiteral(v))
+		} else {
+			sb.WriteRune(rune(v))
+		}
+	case parser.String:
+		if write {
+			writeQuotedString(sb, string(v))
+		} else {
+			sb.WriteString(string(v))
+		}
+	case parser.Symbol:
+		if write && symbolNeedsPipes(v) {
+			writePipeSymbol(sb, string(v))
+		} else {
+			sb.WriteString(string(v))
+		}
+	case parser.Comment:
+		sb.WriteString(string(v))
+	case parser.List:
+		// (quote x) などは 'x のような省略記法で出力する
+		if len(v) == 2 {
+			if sym, ok := v[0].(parser.Symbol); ok {
+				if prefix, ok := quoteAbbrevs[sym]; ok {
+					sb.WriteString(prefix)
+					render(sb, v[1], write, labels)
+					return
+				}
+			}
+		}
+		sb.WriteByte('(')
+		for i, elem := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			render(sb, elem, write, labels)
+		}
+		sb.WriteByte(')')
+	case *Vector:
+		sb.WriteString("#(")
+		for i, elem := range v.Elems {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			render(sb, elem, write, labels)
+		}
+		sb.WriteByte(')')
+	case MultipleValues:
+		// 多値は各値を空白で区切って出力する
+		for i, elem := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			render(sb, elem, write, labels)
+		}
+	case UnspecifiedValue:
+		// 未規定値は何も出力しない
+	// 以下はデータとして読み戻せない値で、#<...> 形式の外部表現を持ちます
+	case *Builtin:
+		fmt.Fprintf(sb, "#<builtin %s>", v.Name)
+	case *Closure:
+		sb.WriteString("#<closure (")
+		for i, param := range v.params {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			render(sb, param, write, labels)
+		}
+		sb.WriteString(")>")
+	case *tracedProcedure:
+		fmt.Fprintf(sb, "#<traced %s>", v.name)
+	case *HashTable:
+		fmt.Fprintf(sb, "#<hash-table %d>", v.Len())
+	case *OutputStringPort:
+		sb.WriteString("#<output-string-port>")
+	case *InputStringPort:
+		sb.WriteString("#<input-string-port>")
+	case EOFObject:
+		sb.WriteString("#<eof>")
+	case *ErrorObject:
+		fmt.Fprintf(sb, "#<error %s>", strconv.Quote(v.Message))
+	default:
+		fmt.Fprintf(sb, "#<%T>", v)
+	}
+}
+
+// charLiteral は文字を #\a や #\space のような読み戻せる形式で返します。
+func charLiteral(c parser.Char) string {
+	switch c {
+	case ' ':
+		return "#\\space"
+	case '\n':
+		return "#\\newline"
+	case '\t':
+		return "#\\tab"
+	case '\r':
+		return "#\\return"
+	case 0:
+		return "#\\nul"
+	}
+	if !strconv.IsPrint(rune(c)) {
+		return fmt.Sprintf("#\\x%x;", rune(c))
+	}
+	return "#\\" + string(rune(c))
+}
+
+// symbolNeedsPipes はシンボルをそのまま書くとリーダーで同じシンボルとして読み戻せず、
+// |a b| のように縦棒で囲む必要があるかを判定します。
+// 英字や記号だけからなる普通の名前はすぐに判定し、それ以外は実際に字句解析して確かめます。
+// (a . rest) の "." はリーダーが作るシンボルなので、そのまま書きます。
+func symbolNeedsPipes(sym parser.Symbol) bool {
+	s := string(sym)
+	if s == "." {
+		return false
+	}
+	if plainSymbol(s) {
+		return false
+	}
+	l := lexer.NewLexer(strings.NewReader(s))
+	tok := l.NextToken()
+	return tok.Type != lexer.TokenIdentifier || tok.Literal != s || l.NextToken().Type != lexer.TokenEOF
+}
+
+// plainSymbol は s が英字か記号で始まり、英字・数字・記号だけからなる、数値と紛れない名前であるかを判定します。
+func plainSymbol(s string) bool {
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), strings.ContainsRune("!$%&*/:<=>?^_~", r):
+		case i > 0 && (unicode.IsDigit(r) || r == '+' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// writePipeSymbol はシンボルを |...| の形式で sb に書き込みます。"|" と "\" は "\|" と "\\" にエスケープします。
+func writePipeSymbol(sb *strings.Builder, s string) {
+	sb.WriteByte('|')
+	for _, r := range s {
+		if r == '|' || r == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('|')
+}
+
+// writeQuotedString は文字列をリーダーで読み戻せる引用符付きの形式で sb に書き込みます。
+// 改行などは \n のように、それ以外の表示できない文字は \x7f; のような16進数のエスケープで表します。
+func writeQuotedString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if strconv.IsPrint(r) {
+				sb.WriteRune(r)
+			} else {
+				fmt.Fprintf(sb, `\x%x;`, r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// formatFloat は浮動小数点数を Scheme 風に整形します（整数値でも小数点を付ける）。
+// 無限大と NaN はリーダーで読み戻せる +inf.0、-inf.0、+nan.0 で表します。
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	case math.IsNaN(f):
+		return "+nan.0"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// registerPrinterBuiltins は display と write を環境に登録します。
+// 出力先は省略可能な最後の引数で指定する出力ポートで、省略時は env.Output() です。
+func registerPrinterBuiltins(env *Env) {
+	env.Set("display", &Builtin{
+		Name: "display",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("display: wrong number of arguments")
+			}
+			w, err := outputPortArg("display", env, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprint(w, DisplayString(args[0]))
+			return Unspecified, nil
+		},
+	})
+	env.Set("write", &Builtin{
+		Name: "write",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("write: wrong number of arguments")
+			}
+			w, err := outputPortArg("write", env, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprint(w, WriteString(args[0]))
+			return Unspecified, nil
+		},
+	})
+	env.Set("newline", &Builtin{
+		Name: "newline",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("newline: wrong number of arguments")
+			}
+			w, err := outputPortArg("newline", env, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintln(w)
+			return Unspecified, nil
+		},
+	})
+}
@@ -0,0 +1,213 @@
+package evaluator
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestWriteQuoteAbbreviation は quote 系のリストが省略記法で出力されることをテストします。
+func TestWriteQuoteAbbreviation(t *testing.T) {
+	tests := []struct {
+		expr     parser.Expr
+		expected string
+	}{
+		{parser.List{parser.Symbol("quote"), parser.Symbol("x")}, "'x"},
+		{parser.List{parser.Symbol("quote"), parser.List{parser.Symbol("quote"), parser.Symbol("x")}}, "''x"},
+		{parser.List{parser.Symbol("quasiquote"), parser.List{parser.Symbol("unquote"), parser.Symbol("y")}}, "`,y"},
+		// 要素数が 2 でないものは通常のリストとして出力する
+		{parser.List{parser.Symbol("quote"), parser.Symbol("x"), parser.Symbol("y")}, "(quote x y)"},
+		{parser.List{parser.Integer(1), parser.String("two"), parser.Float(3)}, `(1 "two" 3.0)`},
+	}
+	for _, tt := range tests {
+		if got := WriteString(tt.expr); got != tt.expected {
+			t.Errorf("WriteString(%v): expected %q, got %q", tt.expr, tt.expected, got)
+		}
+	}
+}
+
+// TestDisplayAndWriteBuiltins は display と write が環境の出力先に書き込むことをテストします。
+func TestDisplayAndWriteBuiltins(t *testing.T) {
+	var buf bytes.Buffer
+	env := NewGlobalEnv()
+	env.SetOutput(&buf)
+	input := `
+	(write ''x)
+	(display " ")
+	(write "s")
+	(display "s")
+	`
+	if _, err := evalString(t, env, input); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	expected := `'x "s"s`
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+// TestWriteNonDatumTypes はデータでない値が #<...> 形式の安定した外部表現で出力されることをテストします。
+func TestWriteNonDatumTypes(t *testing.T) {
+	env := NewGlobalEnv()
+	input := `
+	(define (square x) (* x x))
+	(define table (make-hash-table))
+	(hash-table-set! table 'a 1)
+	`
+	if _, err := evalString(t, env, input); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	square, _ := env.Get("square")
+	plus, _ := env.Get("+")
+	table, _ := env.Get("table")
+	tests := []struct {
+		expr     parser.Expr
+		expected string
+	}{
+		{square, "#<closure (x)>"},
+		{plus, "#<builtin +>"},
+		{table, "#<hash-table 1>"},
+		{&ErrorObject{Message: "boom"}, `#<error "boom">`},
+		{parser.List{square, parser.Integer(1)}, "(#<closure (x)> 1)"},
+	}
+	for _, tt := range tests {
+		if got := WriteString(tt.expr); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+		if got := DisplayString(tt.expr); got != tt.expected {
+			t.Errorf("display: expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+// TestWriteRoundTrip は構文の式を write 形式で出力して読み戻すと、元と同じ AST になることをテストします。
+// パーサは cond や let などの派生形式を基本的な形式に変換せず、書かれたとおりの形で保持します。
+func TestWriteRoundTrip(t *testing.T) {
+	input := `
+	(cond ((eq? x 'a) "a") ((null? x) 'empty) (else (list x 1.5 1/3)))
+	(case (car xs) ((1 2) 'small) (else 'large))
+	(when (pair? x) (display x) (newline))
+	(unless done (loop (+ i 1)))
+	(let ((a 1) (b #\a)) (let* ((c (+ a 1))) (list a b c)))
+	'(a 'b)
+	` + "`(1 ,x ,@ys)" + `
+	(list "tab\tquote\"back\\slash" "\x7f;" #\x1; #\space)
+	'#(1 "a" (b c) #(d) #())
+	'(|a b| |has\|bar| |back\\slash| |1| |#t| || |(x)|)
+	`
+	original, err := parser.NewParser(strings.NewReader(input)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if head := original[0].(parser.List)[0]; head != parser.Symbol("cond") {
+		t.Fatalf("expected the cond form to be kept, got %s", WriteString(original[0]))
+	}
+	var formatted strings.Builder
+	for _, expr := range original {
+		formatted.WriteString(WriteString(expr))
+		formatted.WriteByte('\n')
+	}
+	reparsed, err := parser.NewParser(strings.NewReader(formatted.String())).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error on formatted output %q: %v", formatted.String(), err)
+	}
+	if !reflect.DeepEqual(reparsed, original) {
+		t.Errorf("round trip changed the AST:\n%s", formatted.String())
+	}
+}
+
+// TestDatumLabels はデータラベルで共有したリストが eq? で同一になり、
+// write が2回以上現れる部分と循環する部分をラベル付きで、display が循環する部分だけをラベル付きで出力することをテストします。
+func TestDatumLabels(t *testing.T) {
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define x '(#1=(a b) #1#))", "x"},
+		{"(eq? (list-ref x 0) (list-ref x 1))", "#t"},
+		// 内容が同じでも別に読んだリストは同一ではない
+		{"(eq? (list-ref '((a b) (a b)) 0) (list-ref '((a b) (a b)) 1))", "#f"},
+		{"(eq? '() '())", "#t"},
+		{"(eq? 'a 'a)", "#t"},
+		{"(define out (open-output-string))", "out"},
+		{"(write x out)", ""},
+		{"(get-output-string out)", `"(#0=(a b) #0#)"`},
+		// 同一でないリストにはラベルを付けない
+		{"(define out (open-output-string))", "out"},
+		{"(write '((a b) (a b)) out)", ""},
+		{"(get-output-string out)", `"((a b) (a b))"`},
+		// display は循環していない共有にはラベルを付けない
+		{"(define out (open-output-string))", "out"},
+		{"(display x out)", ""},
+		{"(get-output-string out)", `"((a b) (a b))"`},
+		// list-set! で作った循環する構造
+		{"(define c (list 1 2))", "c"},
+		{"(list-set! c 1 c)", ""},
+		{"(define out (open-output-string))", "out"},
+		{"(write (list c c) out)", ""},
+		{"(get-output-string out)", `"(#0=(1 #0#) #0#)"`},
+		{"(define out (open-output-string))", "out"},
+		{"(write (vector x 'y x) out)", ""},
+		{"(get-output-string out)", `"#(#0=(#1=(a b) #1#) y #0#)"`},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// ラベル付きで出力した循環する構造は、読み戻すと同じ形になる
+	cyclic, err := parser.NewParser(strings.NewReader("#0=(a #0# b)")).ParseExpr()
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+	if got := WriteString(cyclic); got != "#0=(a #0# b)" {
+		t.Errorf("expected %q, got %q", "#0=(a #0# b)", got)
+	}
+}
+
+// TestWriteSymbolsAndVectors は読み戻せないシンボルが write では |...| で囲んで出力され、
+// #( で始まるベクタが読めることをテストします。
+func TestWriteSymbolsAndVectors(t *testing.T) {
+	symbols := []struct {
+		sym     parser.Symbol
+		written string
+	}{
+		{"abc", "abc"},
+		{"a->b", "a->b"},
+		{"...", "..."},
+		{"+", "+"},
+		{"a b", "|a b|"},
+		{"has|bar", `|has\|bar|`},
+		{"1", "|1|"},
+		{"", "||"},
+	}
+	for _, tt := range symbols {
+		if got := WriteString(tt.sym); got != tt.written {
+			t.Errorf("WriteString(%q): expected %q, got %q", string(tt.sym), tt.written, got)
+		}
+		// display はそのままの名前を出力する
+		if got := DisplayString(tt.sym); got != string(tt.sym) {
+			t.Errorf("DisplayString(%q): expected %q, got %q", string(tt.sym), string(tt.sym), got)
+		}
+	}
+
+	env := NewGlobalEnv()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(vector-ref #(1 (a b)) 1)", "(a b)"},
+		{`(vector-ref (read (open-input-string "#(1 2)")) 1)`, "2"},
+		{`(equal? (read (open-input-string "(|a b| #(c))")) '(|a b| #(c)))`, "#t"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, env, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
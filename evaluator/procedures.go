@@ -0,0 +1,37 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// builtinIdentity は "identity" を実装します。
+// (identity x) x をそのまま返します。
+func builtinIdentity(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("identity: wrong number of arguments")
+	}
+	return args[0], nil
+}
+
+// builtinConst は "const" を実装します。
+// (const x) 任意の数の引数を受け取り、それらを無視して常に x を返す手続きを返します。
+func builtinConst(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("const: wrong number of arguments")
+	}
+	val := args[0]
+	return &Builtin{
+		Name: "const",
+		Fn: func([]parser.Expr) (parser.Expr, error) {
+			return val, nil
+		},
+	}, nil
+}
+
+// registerProcedureBuiltins は手続きを組み合わせるための組み込み関数を環境に登録します。
+func registerProcedureBuiltins(env *Env) {
+	env.Set("identity", &Builtin{Name: "identity", Fn: builtinIdentity})
+	env.Set("const", &Builtin{Name: "const", Fn: builtinConst})
+}
@@ -0,0 +1,28 @@
+package evaluator
+
+import "testing"
+
+// TestIdentityAndConst は identity が引数をそのまま返し、const の返す手続きが引数を無視して同じ値を返すことをテストします。
+func TestIdentityAndConst(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(identity 42)", "42"},
+		{"(identity '(a b))", "(a b)"},
+		{"((const 7) 1 2 3)", "7"},
+		{"((const 'x))", "x"},
+		{"(map-indexed (const 0) '(a b c))", "(0 0 0)"},
+		{"(apply identity '(5))", "5"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(identity)", "(identity 1 2)", "(const)", "(const 1 2)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// procedureName は名前を持つ手続きの名前を返します。lambda で作った無名のクロージャなどは false を返します。
+func procedureName(proc Callable) (parser.Symbol, bool) {
+	switch p := proc.(type) {
+	case *Builtin:
+		return parser.Symbol(p.Name), p.Name != ""
+	case *Closure:
+		return p.name, p.name != ""
+	case *tracedProcedure:
+		return p.name, true
+	}
+	return "", false
+}
+
+// evalProfile は (profile body...) を評価します。本体を評価する間、名前を持つ手続きの呼び出し回数を
+// 呼び出しを監視するフックで数え、評価を終えたら "name count" の行を回数の多い順（同じ回数なら名前順）に
+// env の出力先へ出力します。結果は本体の最後の式の値です。本体がエラーになった場合や throw で抜けた場合は出力しません。
+func evalProfile(exp parser.List, env *Env) (parser.Expr, error) {
+	counts := make(map[parser.Symbol]int)
+	uninstall := env.tracer.install(func(proc Callable) {
+		if name, ok := procedureName(proc); ok {
+			counts[name]++
+		}
+	})
+	// throw で抜けた場合にもフックが残らないよう、取り除く処理は defer で行う
+	defer uninstall()
+	result, err := eval(append(parser.List{parser.Symbol("begin")}, exp[1:]...), env)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]parser.Symbol, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	w := env.Output()
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %d\n", name, counts[name])
+	}
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestProfile は profile が本体の値を返し、名前を持つ手続きの呼び出し回数を多い順に出力することをテストします。
+func TestProfile(t *testing.T) {
+	env := NewGlobalEnv()
+	var out bytes.Buffer
+	env.SetOutput(&out)
+	got := evalToString(t, env, `
+(define (fib n)
+  (match n (0 0) (1 1) (_ (+ (fib (- n 1)) (fib (- n 2))))))
+(profile (fib 10))`)
+	if got != "55" {
+		t.Errorf("expected 55, got %s", got)
+	}
+	// (fib 10) は fib を 177 回呼び出し、n が 2 以上の 88 回の呼び出しで + を 1 回、- を 2 回呼び出す
+	expected := "fib 177\n- 176\n+ 88\n"
+	if out.String() != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// 無名の手続きは数えず、profile の外の呼び出しも数えない
+	out.Reset()
+	if got := evalToString(t, env, "(profile ((lambda (x) (fib x)) 2) (fib 1))"); got != "1" {
+		t.Errorf("expected 1, got %s", got)
+	}
+	if expected := "fib 4\n- 2\n+ 1\n"; out.String() != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, out.String())
+	}
+	out.Reset()
+	evalToString(t, env, "(fib 3)")
+	if out.Len() != 0 {
+		t.Errorf("expected no report outside profile, got %q", out.String())
+	}
+
+	// 本体がエラーになった場合はエラーを返し、レポートは出力しない
+	if _, err := evalString(t, env, "(profile (fib 'a))"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no report on error, got %q", out.String())
+	}
+
+	// throw で抜けた場合もフックは取り除かれ、後の profile は自分の呼び出しだけを数える
+	out.Reset()
+	if got := evalToString(t, env, "(catch 'k (profile (fib 1) (throw 'k 'escaped)))"); got != "escaped" {
+		t.Errorf("expected escaped, got %s", got)
+	}
+	if n := len(env.tracer.hooks); n != 0 {
+		t.Errorf("expected no hooks after throw, got %d", n)
+	}
+	evalToString(t, env, "(profile (fib 1))")
+	if expected := "fib 1\n"; out.String() != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,290 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// 環境の直列化
+//
+// Serialize は環境に define された束縛を JSON として書き出し、Deserialize で別の環境に読み戻します。
+// 標準ライブラリを読み込んだ状態を保存しておき、REPL の起動時に再評価せずに復元する用途を想定しています。
+//
+// 直列化できる値は次のとおりです。
+//   - AST で表せるデータ（数値・文字列・シンボル・真偽値・文字・それらのリスト）。parser.MarshalExpr と同じ形式で書き出します。
+//   - ベクタとハッシュテーブル。要素（ハッシュテーブルではキーと値）も同じ規則で直列化します。
+//   - クロージャ。引数、本体の AST、捕捉した環境（フレーム）への参照を書き出します。
+//     捕捉したフレームの束縛も同じ規則で直列化されます。
+//
+// define-constant で束縛した変数は、読み戻した後も定数のままです。
+// データは束縛ごとに書き出すため、複数の束縛で共有していたリストやベクタは読み戻すと別々のコピーになります。
+//
+// 組み込み関数、エラーオブジェクト、ポートのように AST で表せない値、それらを要素に含むデータ、循環するデータ、
+// 直列化する環境の外側で作られたクロージャは直列化できないため、その束縛は読み飛ばします。
+
+// serializedEnv は直列化された環境です。Frames[0] が直列化した環境自身で、
+// それ以外はクロージャが捕捉した内側のフレームです。
+type serializedEnv struct {
+	Frames []serializedFrame `json:"frames"`
+}
+
+// serializedFrame は1つのフレームの束縛です。Parent は外側のフレームの番号で、つねにこのフレームの番号より小さく、Frames[0] では使いません。
+type serializedFrame struct {
+	Parent   int                 `json:"parent"`
+	Bindings []serializedBinding `json:"bindings"`
+}
+
+// serializedBinding は1つの束縛です。値は Datum と Closure のどちらか一方で表します。
+// Constant は define-constant で束縛した変数であることを表します。
+type serializedBinding struct {
+	Name     string             `json:"name"`
+	Datum    json.RawMessage    `json:"datum,omitempty"`
+	Closure  *serializedClosure `json:"closure,omitempty"`
+	Constant bool               `json:"constant,omitempty"`
+}
+
+// serializedData はリスト・ベクタ・ハッシュテーブルの形式です。parser.MarshalExpr のリストと同じく Type と Items で表し、
+// ハッシュテーブルの Items はキーと値を交互に並べたものです。
+type serializedData struct {
+	Type  string            `json:"type"`
+	Items []json.RawMessage `json:"items,omitempty"`
+}
+
+// marshalValue はデータを JSON にエンコードします。リスト・ベクタ・ハッシュテーブルは要素を再帰的に、
+// それ以外は parser.MarshalExpr でエンコードします。path は外側でエンコード中のリスト・ベクタ・ハッシュテーブルで、循環の検出に使います。
+func marshalValue(val parser.Expr, path map[any]bool) (json.RawMessage, error) {
+	var typ string
+	var elems []parser.Expr
+	switch v := val.(type) {
+	case parser.List:
+		typ, elems = "list", v
+	case *Vector:
+		typ, elems = "vector", v.Elems
+	case *HashTable:
+		typ = "hash-table"
+		for _, e := range v.entries {
+			elems = append(elems, e.key, e.value)
+		}
+	default:
+		return parser.MarshalExpr(val)
+	}
+	key, _, ok := labelKey(val)
+	if table, isTable := val.(*HashTable); isTable {
+		key, ok = table, true
+	}
+	if ok {
+		if path[key] {
+			return nil, fmt.Errorf("cannot marshal cyclic data")
+		}
+		path[key] = true
+		defer delete(path, key)
+	}
+	items := make([]json.RawMessage, len(elems))
+	for i, elem := range elems {
+		var err error
+		if items[i], err = marshalValue(elem, path); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(serializedData{Type: typ, Items: items})
+}
+
+// unmarshalValue は marshalValue でエンコードされた JSON からデータを復元します。
+func unmarshalValue(data json.RawMessage) (parser.Expr, error) {
+	var d serializedData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	switch d.Type {
+	case "list", "vector", "hash-table":
+	default:
+		return parser.UnmarshalExpr(data)
+	}
+	elems := make([]parser.Expr, len(d.Items))
+	for i, item := range d.Items {
+		var err error
+		if elems[i], err = unmarshalValue(item); err != nil {
+			return nil, err
+		}
+	}
+	switch d.Type {
+	case "list":
+		return parser.List(elems), nil
+	case "vector":
+		return &Vector{Elems: elems}, nil
+	}
+	if len(elems)%2 != 0 {
+		return nil, fmt.Errorf("hash-table: odd number of items")
+	}
+	table := NewHashTable()
+	for i := 0; i < len(elems); i += 2 {
+		table.Set(elems[i], elems[i+1])
+	}
+	return table, nil
+}
+
+// serializedClosure はクロージャです。Params は各仮引数（シンボルまたは分解するリスト）を
+// parser.MarshalExpr で書き出したもので、Frame は捕捉した環境のフレーム番号です。
+// Name は define で定義されたクロージャの名前で、無名のクロージャでは空です。
+type serializedClosure struct {
+	Name   string            `json:"name,omitempty"`
+	Params []json.RawMessage `json:"params"`
+	Body   json.RawMessage   `json:"body"`
+	Frame  int               `json:"frame"`
+}
+
+// bindings はこの環境自身の束縛を名前順に返します。
+func (env *Env) bindings() []parser.Symbol {
+	names := make([]parser.Symbol, 0, env.n+len(env.vars))
+	names = append(names, env.names[:env.n]...)
+	for name := range env.vars {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// envSerializer は直列化中のフレームと、その番号の対応を保持します。
+type envSerializer struct {
+	ids    map[*Env]int
+	envs   []*Env
+	frames []serializedFrame
+}
+
+// frameID はフレームの番号を返します。初めて見るフレームであれば、外側のフレームとあわせて登録します。
+// フレームが直列化する環境の内側にない場合は false を返します。
+func (s *envSerializer) frameID(env *Env) (int, bool) {
+	if id, ok := s.ids[env]; ok {
+		return id, true
+	}
+	if env.outer == nil {
+		return 0, false
+	}
+	parent, ok := s.frameID(env.outer)
+	if !ok {
+		return 0, false
+	}
+	id := len(s.envs)
+	s.ids[env] = id
+	s.envs = append(s.envs, env)
+	s.frames = append(s.frames, serializedFrame{Parent: parent})
+	return id, true
+}
+
+// binding は値を直列化します。直列化できない値の場合は false を返します。
+func (s *envSerializer) binding(name parser.Symbol, val parser.Expr) (serializedBinding, bool) {
+	b := serializedBinding{Name: string(name)}
+	if c, ok := val.(*Closure); ok {
+		frame, ok := s.frameID(c.env)
+		if !ok {
+			return b, false
+		}
+		body, err := parser.MarshalExpr(c.body)
+		if err != nil {
+			return b, false
+		}
+		params := make([]json.RawMessage, len(c.params))
+		for i, p := range c.params {
+			if params[i], err = parser.MarshalExpr(p); err != nil {
+				return b, false
+			}
+		}
+		b.Closure = &serializedClosure{Name: string(c.name), Params: params, Body: body, Frame: frame}
+		return b, true
+	}
+	datum, err := marshalValue(val, make(map[any]bool))
+	if err != nil {
+		return b, false
+	}
+	b.Datum = datum
+	return b, true
+}
+
+// Serialize は環境に define された束縛を JSON として w に書き出します。
+// 直列化できない値の束縛（組み込み関数など）は読み飛ばします。
+func (env *Env) Serialize(w io.Writer) error {
+	s := &envSerializer{
+		ids:    map[*Env]int{env: 0},
+		envs:   []*Env{env},
+		frames: []serializedFrame{{}},
+	}
+	// クロージャが捕捉したフレームは処理中に追加されるため、添字で走査します。
+	for i := 0; i < len(s.envs); i++ {
+		frame := s.envs[i]
+		bindings := []serializedBinding{}
+		for _, name := range frame.bindings() {
+			val, _ := frame.lookup(name)
+			if b, ok := s.binding(name, val); ok {
+				b.Constant = frame.constants[name]
+				bindings = append(bindings, b)
+			}
+		}
+		s.frames[i].Bindings = bindings
+	}
+	return json.NewEncoder(w).Encode(serializedEnv{Frames: s.frames})
+}
+
+// Deserialize は Serialize で書き出された束縛を r から読み込み、この環境に設定します。
+func (env *Env) Deserialize(r io.Reader) error {
+	var data serializedEnv
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if len(data.Frames) == 0 {
+		return fmt.Errorf("deserialize: no frames")
+	}
+	frames := make([]*Env, len(data.Frames))
+	frames[0] = env
+	for i := 1; i < len(frames); i++ {
+		frames[i] = NewEnv(nil)
+	}
+	// Serialize は外側のフレームに内側より小さい番号を付けるため、それ以外の親は循環を作る不正な入力として拒否する
+	for i, f := range data.Frames[1:] {
+		if f.Parent < 0 || f.Parent >= i+1 {
+			return fmt.Errorf("deserialize: invalid parent frame %d", f.Parent)
+		}
+		frames[i+1].outer = frames[f.Parent]
+	}
+	for i, f := range data.Frames {
+		for _, b := range f.Bindings {
+			val, err := deserializeValue(b, frames)
+			if err != nil {
+				return fmt.Errorf("deserialize: %s: %w", b.Name, err)
+			}
+			frames[i].Set(parser.Symbol(b.Name), val)
+			if b.Constant {
+				frames[i].markConstant(parser.Symbol(b.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// deserializeValue は直列化された束縛の値を復元します。
+func deserializeValue(b serializedBinding, frames []*Env) (parser.Expr, error) {
+	if b.Closure == nil {
+		return unmarshalValue(b.Datum)
+	}
+	c := b.Closure
+	if c.Frame < 0 || c.Frame >= len(frames) {
+		return nil, fmt.Errorf("invalid closure frame %d", c.Frame)
+	}
+	body, err := parser.UnmarshalExpr(c.Body)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]parser.Expr, len(c.Params))
+	for i, p := range c.Params {
+		if params[i], err = parser.UnmarshalExpr(p); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := lambdaParams("deserialize", "invalid closure parameters", params); err != nil {
+		return nil, err
+	}
+	return &Closure{params: params, body: body, env: frames[c.Frame], name: parser.Symbol(c.Name)}, nil
+}
@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestSerializeRoundTrip は定義した値とクロージャを直列化し、新しい環境に読み戻しても
+// 同じように使えることをテストします。
+func TestSerializeRoundTrip(t *testing.T) {
+	env := NewGlobalEnv()
+	program := `
+(define pi 3.14)
+(define data '(1 "two" #\c (nested #t)))
+(define (make-adder n) (lambda (x) (+ x n)))
+(define add5 (make-adder 5))
+(define (swap (a b)) (list b a))
+(define table (make-hash-table))
+(hash-table-set! table 'key (vector 1 '(a "b")))
+(hash-table-set! table '(list key) 2)
+(define vec (vector 1 (list 2 (vector 3)) "four"))
+(define-constant limit 10)
+(define procs (list list))
+(define cyclic '#0=(1 #0#))
+`
+	if _, err := evalString(t, env, program); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := env.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize error: %v", err)
+	}
+
+	loaded := NewGlobalEnv()
+	if err := loaded.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pi", "3.14"},
+		{"data", `(1 "two" #\c (nested #t))`},
+		{"(add5 10)", "15"},
+		{"((make-adder 2) 3)", "5"},
+		{"(swap '(1 2))", "(2 1)"},
+		{"vec", `#(1 (2 #(3)) "four")`},
+		{"(vector-ref vec 1)", "(2 #(3))"},
+		{"(hash-table-ref table 'key)", `#(1 (a "b"))`},
+		{"(hash-table-ref table '(list key))", "2"},
+		{"limit", "10"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, loaded, tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	// 定数は読み戻した後も書き換えられない
+	if _, err := evalString(t, loaded, "(define limit 20)"); err == nil {
+		t.Errorf("expected redefining the constant limit to fail")
+	}
+	// 組み込み関数を含むデータや循環するデータは直列化できないため読み飛ばされる
+	for _, name := range []string{"procs", "cyclic"} {
+		if _, ok := loaded.Get(parser.Symbol(name)); ok {
+			t.Errorf("expected %s to be skipped", name)
+		}
+	}
+}
+
+// TestDeserializeInvalidFrames は外側のフレームの番号が不正な入力（自分自身や内側を指して循環するものなど）が、
+// 読み込み時にエラーになることをテストします。
+func TestDeserializeInvalidFrames(t *testing.T) {
+	inputs := []string{
+		`{"frames": []}`,
+		`{"frames": [{"bindings": []}, {"parent": 1, "bindings": []}]}`,
+		`{"frames": [{"bindings": []}, {"parent": 2, "bindings": []}, {"parent": 1, "bindings": []}]}`,
+		`{"frames": [{"bindings": []}, {"parent": -1, "bindings": []}]}`,
+		`{"frames": [{"bindings": []}, {"parent": 5, "bindings": []}]}`,
+	}
+	for _, input := range inputs {
+		if err := NewGlobalEnv().Deserialize(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+	// 外側のフレームが先に現れる入力は読み込める
+	valid := `{"frames": [{"bindings": []}, {"parent": 0, "bindings": []}, {"parent": 1, "bindings": []}]}`
+	if err := NewGlobalEnv().Deserialize(strings.NewReader(valid)); err != nil {
+		t.Errorf("%s: unexpected error: %v", valid, err)
+	}
+}
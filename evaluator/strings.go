@@ -0,0 +1,386 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// stringArg は args[i] が文字列であることを確認して返します。
+func stringArg(name string, args []parser.Expr, i int) (string, error) {
+	s, ok := args[i].(parser.String)
+	if !ok {
+		return "", fmt.Errorf("%s: argument %d must be a string, got %T", name, i+1, args[i])
+	}
+	return string(s), nil
+}
+
+// runeRange は args[from:] にある省略可能な start/end 引数を解釈し、長さ n の範囲として検証します。
+func runeRange(name string, args []parser.Expr, from, n int) (int, int, error) {
+	start, end := 0, n
+	var err error
+	if len(args) > from {
+		if start, err = indexArg(name, args, from); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(args) > from+1 {
+		if end, err = indexArg(name, args, from+1); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end > n || start > end {
+		return 0, 0, fmt.Errorf("%s: range [%d, %d) out of bounds for length %d", name, start, end, n)
+	}
+	return start, end, nil
+}
+
+// builtinStringCopy は "string-copy" を実装します。
+// (string-copy str [start [end]]) 文字（rune）単位の範囲を指定して部分文字列を複製します。
+func builtinStringCopy(args []parser.Expr) (parser.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("string-copy: wrong number of arguments")
+	}
+	s, err := stringArg("string-copy", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, end, err := runeRange("string-copy", args, 1, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	return parser.String(runes[start:end]), nil
+}
+
+// builtinSubstring は "substring" を実装します。
+// (substring str start end) string-copy と同じく文字（rune）単位で部分文字列を返しますが、start と end は必須です。
+func builtinSubstring(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("substring: wrong number of arguments")
+	}
+	s, err := stringArg("substring", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, end, err := runeRange("substring", args, 1, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	return parser.String(runes[start:end]), nil
+}
+
+// builtinStringReverse は "string-reverse" を実装します。
+// マルチバイト文字を壊さないよう rune 単位で反転します。
+func builtinStringReverse(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("string-reverse: wrong number of arguments")
+	}
+	s, err := stringArg("string-reverse", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return parser.String(runes), nil
+}
+
+// makeStringTrim は空白を取り除く組み込み関数（string-trim 系）を生成します。
+func makeStringTrim(name string, trim func(string, func(rune) bool) string) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return parser.String(trim(s, unicode.IsSpace)), nil
+		},
+	}
+}
+
+// makeStringSplitter は文字列を分割して文字列のリストを返す組み込み関数（string-lines など）を生成します。
+func makeStringSplitter(name string, split func(string) []string) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			s, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			parts := split(s)
+			result := make(parser.List, len(parts))
+			for i, part := range parts {
+				result[i] = parser.String(part)
+			}
+			return result, nil
+		},
+	}
+}
+
+// splitLines は文字列を "\n" で行に分割します。
+// 末尾の改行は最後の行の終わりとみなすため、"a\nb\n" と "a\nb" はどちらも2行になります。
+// 空文字列は0行です。
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// builtinStringToList は "string->list" を実装します。
+// (string->list str [start [end]]) 文字（rune）単位の範囲の文字をリストにして返します。
+func builtinStringToList(args []parser.Expr) (parser.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("string->list: wrong number of arguments")
+	}
+	s, err := stringArg("string->list", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, end, err := runeRange("string->list", args, 1, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	result := make(parser.List, 0, end-start)
+	for _, r := range runes[start:end] {
+		result = append(result, parser.Char(r))
+	}
+	return result, nil
+}
+
+// builtinListToString は "list->string" を実装します。
+// (list->string chars) 文字のリストから文字列を生成します。
+func builtinListToString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("list->string: wrong number of arguments")
+	}
+	list, err := listArg("list->string", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, 0, len(list))
+	for _, elem := range list {
+		c, ok := elem.(parser.Char)
+		if !ok {
+			return nil, fmt.Errorf("list->string: list elements must be characters, got %T", elem)
+		}
+		runes = append(runes, rune(c))
+	}
+	return parser.String(runes), nil
+}
+
+// builtinStringReplace は "string-replace" を実装します。
+// (string-replace str old new [count]) old の重ならない出現を先頭から count 個まで（省略時はすべて）new に置き換えます。
+func builtinStringReplace(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, fmt.Errorf("string-replace: wrong number of arguments")
+	}
+	var strs [3]string
+	for i := range strs {
+		s, err := stringArg("string-replace", args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	if strs[1] == "" {
+		return nil, fmt.Errorf("string-replace: pattern must not be empty")
+	}
+	count := -1
+	if len(args) == 4 {
+		n, ok := args[3].(parser.Integer)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("string-replace: count must be a non-negative integer, got %v", WriteString(args[3]))
+		}
+		count = int(n)
+	}
+	return parser.String(strings.Replace(strs[0], strs[1], strs[2], count)), nil
+}
+
+// builtinStringSearchAll は "string-search-all" を実装します。
+// (string-search-all needle haystack) haystack で needle が重ならずに出現する位置（文字単位）を先頭から順にリストで返します。
+func builtinStringSearchAll(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("string-search-all: wrong number of arguments")
+	}
+	needle, err := stringArg("string-search-all", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	haystack, err := stringArg("string-search-all", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if needle == "" {
+		return nil, fmt.Errorf("string-search-all: pattern must not be empty")
+	}
+	indices := parser.List{}
+	// offset は haystack[:pos] の文字数で、見つかった位置のバイトオフセットを文字単位に直すために使う
+	pos, offset := 0, 0
+	for {
+		i := strings.Index(haystack[pos:], needle)
+		if i < 0 {
+			return indices, nil
+		}
+		offset += utf8.RuneCountInString(haystack[pos : pos+i])
+		indices = append(indices, parser.Integer(offset))
+		offset += utf8.RuneCountInString(needle)
+		pos += i + len(needle)
+	}
+}
+
+// foldRune は大文字・小文字の区別をなくした（case folding した）文字を返します。
+// ToUpper してから ToLower することで、ß/ẞ やギリシャ文字のシグマのような特殊な対応もまとめます。
+func foldRune(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// foldString は文字列の各文字を foldRune で変換した文字列を返します。
+func foldString(s string) string {
+	return strings.Map(foldRune, s)
+}
+
+// makeStringContains は (name s1 s2) で s1 の中に s2 が最初に現れる位置（文字単位）を、なければ #f を返す
+// 組み込み関数を作ります（SRFI-13 の string-contains）。fold が真なら foldRune で大文字・小文字を区別せずに探します。
+func makeStringContains(name string, fold bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			haystack, err := stringArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			needle, err := stringArg(name, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if fold {
+				// foldRune は1文字を1文字に変換するため、変換後の文字単位の位置は元の文字列の位置と一致する
+				haystack, needle = foldString(haystack), foldString(needle)
+			}
+			i := strings.Index(haystack, needle)
+			if i < 0 {
+				return parser.Boolean(false), nil
+			}
+			return parser.Integer(utf8.RuneCountInString(haystack[:i])), nil
+		},
+	}
+}
+
+// makeStringComparison は文字列の比較述語（string=? など）を生成します。
+// 引数は1つ以上で、隣り合うすべての組で cmp が成り立つときに #t を返します。
+func makeStringComparison(name string, cmp func(a, b string) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			strs := make([]string, len(args))
+			for i := range args {
+				s, err := stringArg(name, args, i)
+				if err != nil {
+					return nil, err
+				}
+				strs[i] = s
+			}
+			for i := 1; i < len(strs); i++ {
+				if !cmp(strs[i-1], strs[i]) {
+					return parser.Boolean(false), nil
+				}
+			}
+			return parser.Boolean(true), nil
+		},
+	}
+}
+
+// charArg は args[i] が文字であることを確認して返します。
+func charArg(name string, args []parser.Expr, i int) (rune, error) {
+	c, ok := args[i].(parser.Char)
+	if !ok {
+		return 0, fmt.Errorf("%s: argument %d must be a character, got %T", name, i+1, args[i])
+	}
+	return rune(c), nil
+}
+
+// makeCharComparison は文字の比較述語（char=? など）を生成します。
+// 引数は1つ以上で、隣り合うすべての組で cmp が成り立つときに #t を返します。
+func makeCharComparison(name string, cmp func(a, b rune) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: wrong number of arguments", name)
+			}
+			chars := make([]rune, len(args))
+			for i := range args {
+				c, err := charArg(name, args, i)
+				if err != nil {
+					return nil, err
+				}
+				chars[i] = c
+			}
+			for i := 1; i < len(chars); i++ {
+				if !cmp(chars[i-1], chars[i]) {
+					return parser.Boolean(false), nil
+				}
+			}
+			return parser.Boolean(true), nil
+		},
+	}
+}
+
+// builtinCharFoldcase は "char-foldcase" を実装します。
+func builtinCharFoldcase(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("char-foldcase: wrong number of arguments")
+	}
+	c, err := charArg("char-foldcase", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return parser.Char(foldRune(c)), nil
+}
+
+// registerStringBuiltins は文字列関連の組み込み関数を環境に登録します。
+func registerStringBuiltins(env *Env) {
+	env.Set("string-copy", &Builtin{Name: "string-copy", Fn: builtinStringCopy})
+	env.Set("substring", &Builtin{Name: "substring", Fn: builtinSubstring})
+	env.Set("string-reverse", &Builtin{Name: "string-reverse", Fn: builtinStringReverse})
+	env.Set("string-trim", makeStringTrim("string-trim", strings.TrimFunc))
+	env.Set("string-trim-left", makeStringTrim("string-trim-left", strings.TrimLeftFunc))
+	env.Set("string-trim-right", makeStringTrim("string-trim-right", strings.TrimRightFunc))
+	env.Set("string->list", &Builtin{Name: "string->list", Fn: builtinStringToList})
+	env.Set("list->string", &Builtin{Name: "list->string", Fn: builtinListToString})
+	env.Set("string-lines", makeStringSplitter("string-lines", splitLines))
+	// string-words は Unicode の空白の並びで区切り、空の要素は含めません
+	env.Set("string-words", makeStringSplitter("string-words", strings.Fields))
+	env.Set("string-replace", &Builtin{Name: "string-replace", Fn: builtinStringReplace})
+	env.Set("string-search-all", &Builtin{Name: "string-search-all", Fn: builtinStringSearchAll})
+	env.Set("string-contains", makeStringContains("string-contains", false))
+	env.Set("string-contains-ci", makeStringContains("string-contains-ci", true))
+	env.Set("string=?", makeStringComparison("string=?", func(a, b string) bool { return a == b }))
+	env.Set("string<?", makeStringComparison("string<?", func(a, b string) bool { return a < b }))
+	env.Set("string-ci=?", makeStringComparison("string-ci=?", func(a, b string) bool { return foldString(a) == foldString(b) }))
+	env.Set("string-ci<?", makeStringComparison("string-ci<?", func(a, b string) bool { return foldString(a) < foldString(b) }))
+	env.Set("char=?", makeCharComparison("char=?", func(a, b rune) bool { return a == b }))
+	env.Set("char-ci=?", makeCharComparison("char-ci=?", func(a, b rune) bool { return foldRune(a) == foldRune(b) }))
+	env.Set("char-foldcase", &Builtin{Name: "char-foldcase", Fn: builtinCharFoldcase})
+}
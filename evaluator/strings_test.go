@@ -0,0 +1,250 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestStringCopyReverseTrim は string-copy、string-reverse、string-trim 系の評価結果をテストします。
+func TestStringCopyReverseTrim(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{`(string-copy "hello")`, parser.String("hello")},
+		{`(string-copy "hello" 1)`, parser.String("ello")},
+		{`(string-copy "こんにちは" 1 3)`, parser.String("んに")},
+		{`(string-reverse "abc")`, parser.String("cba")},
+		{`(string-reverse "日本語")`, parser.String("語本日")},
+		{`(string-reverse "")`, parser.String("")},
+		{"(string-trim \" \\t hello world \\n \")", parser.String("hello world")},
+		{"(string-trim-left \" \\t hello world \\n \")", parser.String("hello world \n ")},
+		{"(string-trim-right \" \\t hello world \\n \")", parser.String(" \t hello world")},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
+// TestStringCopyErrors は string-copy の引数の型・範囲の検証をテストします。
+func TestStringCopyErrors(t *testing.T) {
+	inputs := []string{
+		`(string-copy 42)`,
+		`(string-copy "abc" 2 1)`,
+		`(string-copy "abc" 0 4)`,
+		`(string-copy "abc" "0")`,
+		`(string-reverse 'abc)`,
+		`(string-trim 1)`,
+	}
+	for _, input := range inputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringListConversions は string->list（範囲指定を含む）と list->string の変換をテストします。
+func TestStringListConversions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(string->list "abc")`, `(#\a #\b #\c)`},
+		{`(string->list "a c")`, `(#\a #\space #\c)`},
+		{`(string->list "日本語です" 1)`, `(#\本 #\語 #\で #\す)`},
+		{`(string->list "日本語です" 1 3)`, `(#\本 #\語)`},
+		{`(string->list "abc" 3)`, `()`},
+		{`(list->string '(#\h #\i))`, `"hi"`},
+		{`(list->string (string->list "日本語"))`, `"日本語"`},
+		{`(list->string '())`, `""`},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	errorInputs := []string{
+		`(string->list "abc" 0 4)`,
+		`(string->list "abc" 2 1)`,
+		`(list->string '(#\a 1))`,
+		`(list->string "abc")`,
+	}
+	for _, input := range errorInputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringReplace は string-replace による全置換、回数指定の置換、見つからない場合をテストします。
+func TestStringReplace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{`(string-replace "a-b-c" "-" "+")`, parser.String("a+b+c")},
+		{`(string-replace "a-b-c" "-" "" 1)`, parser.String("ab-c")},
+		{`(string-replace "aaaa" "aa" "b")`, parser.String("bb")},
+		{`(string-replace "abc" "x" "y")`, parser.String("abc")},
+		{`(string-replace "abc" "b" "y" 0)`, parser.String("abc")},
+		{`(string-replace "日本の日本語" "日本" "にほん")`, parser.String("にほんのにほん語")},
+		{`(string-replace "ねこねこねこ" "こ" "ko" 2)`, parser.String("ねkoねkoねこ")},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+	errorInputs := []string{
+		`(string-replace "abc" "" "x")`,
+		`(string-replace "abc" "a" "x" -1)`,
+		`(string-replace "abc" 'a "x")`,
+		`(string-replace "abc" "a")`,
+	}
+	for _, input := range errorInputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringSearchAll は string-search-all が重ならない出現位置を文字単位で返すことをテストします。
+func TestStringSearchAll(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-search-all "ab" "abcabcab")`, "(0 3 6)"},
+		{`(string-search-all "x" "abc")`, "()"},
+		// 重なる候補は数えない
+		{`(string-search-all "aa" "aaaaa")`, "(0 2)"},
+		{`(string-search-all "日本" "日本の日本語")`, "(0 3)"},
+		{`(string-search-all "c" "あいcうc")`, "(2 4)"},
+		{`(string-search-all "abc" "")`, "()"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	errorInputs := []string{
+		`(string-search-all "" "abc")`,
+		`(string-search-all 'a "abc")`,
+		`(string-search-all "a")`,
+	}
+	for _, input := range errorInputs {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringContains は string-contains と string-contains-ci が最初の出現位置を文字単位で返すことをテストします。
+func TestStringContains(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-contains "say hello there" "hello")`, "4"},
+		{`(string-contains "say HELLO there" "Hello")`, "#f"},
+		{`(string-contains "abc" "")`, "0"},
+		{`(string-contains-ci "say HELLO there" "Hello")`, "4"},
+		{`(string-contains-ci "日本語のΣΟΦΙΑ" "σοφια")`, "4"},
+		{`(string-contains-ci "say hello" "world")`, "#f"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{`(string-contains-ci "abc")`, `(string-contains-ci "abc" 'a)`, `(string-contains 1 "a")`} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestCaseInsensitiveComparison は string-ci=?・string-ci<?・char-ci=?・char-foldcase が
+// マルチバイト文字を含めて大文字・小文字を区別せずに比較することをテストします。
+func TestCaseInsensitiveComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected parser.Expr
+	}{
+		{`(string=? "Hello" "hello")`, parser.Boolean(false)},
+		{`(string-ci=? "Hello" "hello")`, parser.Boolean(true)},
+		{`(string-ci=? "Hello" "hello" "HELLO")`, parser.Boolean(true)},
+		{`(string-ci=? "Hello" "help")`, parser.Boolean(false)},
+		{`(string=? "ΣΊΣΥΦΟΣ" "σίσυφος")`, parser.Boolean(false)},
+		{`(string-ci=? "ΣΊΣΥΦΟΣ" "σίσυφος")`, parser.Boolean(true)},
+		{`(string-ci=? "ÄPFEL" "äpfel")`, parser.Boolean(true)},
+		{`(string<? "Apple" "apple")`, parser.Boolean(true)},
+		{`(string-ci<? "Apple" "apple")`, parser.Boolean(false)},
+		{`(string-ci<? "apple" "BANANA" "cherry")`, parser.Boolean(true)},
+		{`(char=? #\a #\A)`, parser.Boolean(false)},
+		{`(char-ci=? #\a #\A)`, parser.Boolean(true)},
+		{`(char-ci=? #\Ж #\ж)`, parser.Boolean(true)},
+		{`(char-foldcase #\A)`, parser.Char('a')},
+		{`(char-foldcase #\Ω)`, parser.Char('ω')},
+		{`(char-foldcase #\1)`, parser.Char('1')},
+	}
+	for _, tt := range tests {
+		result, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, result)
+		}
+	}
+	for _, input := range []string{`(string-ci=? "a" 'a)`, `(char-ci=? #\a "a")`, "(string=?)", `(char-foldcase "a")`} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringLinesWords は string-lines と string-words による分割をテストします。
+func TestStringLinesWords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(string-lines "one\ntwo\nthree")`, `("one" "two" "three")`},
+		// 末尾の改行の有無で結果は変わらない
+		{`(string-lines "one\ntwo\nthree\n")`, `("one" "two" "three")`},
+		{`(string-lines "a\n\nb")`, `("a" "" "b")`},
+		{`(string-lines "\n")`, `("")`},
+		{`(string-lines "")`, "()"},
+		{`(string-lines "日本\n語")`, `("日本" "語")`},
+		{`(string-words "  hello   world \t again  ")`, `("hello" "world" "again")`},
+		{`(string-words "一　二 三")`, `("一" "二" "三")`},
+		{`(string-words "   ")`, "()"},
+		{`(string-words "single")`, `("single")`},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(string-lines 'a)", `(string-words "a" "b")`} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
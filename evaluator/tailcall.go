@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// applyBuiltin は apply の組み込み関数です。
+// eval は演算子がこの値であることを判定し、展開した呼び出しを末尾呼び出しとして評価します。
+// 他の組み込み関数から呼ばれた場合は通常の組み込み関数として動作し、引数の上限には DefaultMaxArgs を使います。
+var applyBuiltin = &Builtin{Name: "apply", Fn: builtinApply}
+
+// builtinApply は "apply" を実装します。
+// (apply proc arg... list) proc を arg... と list の要素を並べた引数で呼び出します。
+func builtinApply(args []parser.Expr) (parser.Expr, error) {
+	proc, procArgs, err := spreadApplyArgs(args, DefaultMaxArgs)
+	if err != nil {
+		return nil, err
+	}
+	return proc.Call(procArgs)
+}
+
+// spreadApplyArgs は apply の引数から、呼び出す手続きと展開した引数を取り出します。
+// 展開した引数の数が limit を超える場合はエラーを返します。
+func spreadApplyArgs(args []parser.Expr, limit int) (Callable, []parser.Expr, error) {
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("apply: wrong number of arguments")
+	}
+	proc, err := procArg("apply", args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	last, err := listArg("apply", args, len(args)-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := len(args) - 2 + len(last)
+	if n > limit {
+		return nil, nil, fmt.Errorf("apply: too many arguments: %d exceeds the limit of %d", n, limit)
+	}
+	spread := make([]parser.Expr, 0, n)
+	spread = append(spread, args[1:len(args)-1]...)
+	return proc, append(spread, last...), nil
+}
@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"errors"
+	"runtime/debug"
+	"testing"
+)
+
+// TestApply は apply が最後のリストを展開した引数で手続きを呼び出すことをテストします。
+func TestApply(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(apply + '(1 2 3))`, "6"},
+		{`(apply + 1 2 '(3 4))`, "10"},
+		{`(apply list '())`, "()"},
+		{`(apply (lambda (a b) (- a b)) '(10 3))`, "7"},
+		// apply 自身も手続きとして apply できる
+		{`(apply apply (list + '(1 2)))`, "3"},
+		// 他の組み込み関数に渡した場合も動作する
+		{`(call-with-values (lambda () (values + '(1 2))) apply)`, "3"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`(apply +)`, "apply: wrong number of arguments"},
+		{`(apply 1 '(2))`, "apply: argument 1 must be a procedure, got parser.Integer"},
+		{`(apply + 1 2)`, "apply: argument 3 must be a list, got parser.Integer"},
+		{`(apply (lambda (x) x) '(1 2))`, "expected 1 argument (x), got 2"},
+	}
+	for _, tt := range errorTests {
+		_, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err == nil || innermostError(err).Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestTailCall は末尾位置の呼び出しによる繰り返しが、回数が多くてもスタックを溢れさせずに終わることをテストします。
+func TestTailCall(t *testing.T) {
+	// 末尾呼び出しがスタックを消費していれば、この上限で確実に溢れる
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"direct", `
+		(define (loop i) (match i (100000 'done) (_ (loop (+ i 1)))))
+		(loop 0)`},
+		{"apply", `
+		(define (loop i) (match i (100000 'done) (_ (apply loop (list (+ i 1))))))
+		(loop 0)`},
+		{"mutual", `
+		(define (ping i) (match i (100000 'done) (_ (pong (+ i 1)))))
+		(define (pong i) (apply ping (+ i 1) '()))
+		(ping 0)`},
+		{"letrec", `
+		(letrec ((loop (lambda (i) (match i (100000 'done) (_ (loop (+ i 1)))))))
+		  (loop 0))`},
+		{"if", `
+		(define (loop i) (if (= i 100000) 'done (loop (+ i 1))))
+		(loop 0)`},
+		{"cond", `
+		(define (loop i) (cond ((= i 100000) 'done) (else (loop (+ i 1)))))
+		(loop 0)`},
+		{"let", `
+		(define (loop i) (let ((next (+ i 1))) (if (= next 100000) 'done (loop next))))
+		(loop 0)`},
+		{"when", `
+		(define (loop i) (when (< i 100000) (loop (+ i 1))))
+		(loop 0)
+		'done`},
+		{"case", `
+		(define (loop i) (case i ((100000) 'done) (else (loop (+ i 1)))))
+		(loop 0)`},
+		{"receive", `
+		(define (loop i) (receive (next) (values (+ i 1)) (match next (100000 'done) (_ (loop next)))))
+		(loop 0)`},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != "done" {
+			t.Errorf("%s: expected done, got %s", tt.name, got)
+		}
+	}
+}
+
+// TestTailCallBacktrace は末尾呼び出しで置き換えた呼び出しも、エラーのバックトレースに含まれることをテストします。
+func TestTailCallBacktrace(t *testing.T) {
+	input := `
+	(define (fail x) (error "boom" x))
+	(define (step x) (apply fail (list (* x 2))))
+	(step 1)
+	`
+	expected := `boom 2
+backtrace:
+  in fail: (apply fail (list (* x 2)))
+  in step: (step 1)`
+	_, err := evalString(t, NewGlobalEnv(), input)
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+
+	// 長い繰り返しの後のエラーでは、末尾呼び出しのフレームは直近の maxTailFrames 個だけが残る
+	input = `
+	(define (loop i) (match i (1000 (error "end")) (_ (loop (+ i 1)))))
+	(loop 0)
+	`
+	_, err = evalString(t, NewGlobalEnv(), input)
+	var bt *backtraceError
+	if !errors.As(err, &bt) || len(bt.frames) != maxTailFrames {
+		t.Errorf("expected %d frames, got %v", maxTailFrames, err)
+	}
+}
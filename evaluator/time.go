@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// registerTimeBuiltins は時刻・経過時間を扱う組み込み関数を環境に登録します。
+// runtime の起点は環境（インタプリタ）の生成時刻です。
+func registerTimeBuiltins(env *Env) {
+	start := time.Now()
+	// (current-time) 現在の Unix 時刻を秒単位の Float で返します。
+	env.Set("current-time", &Builtin{
+		Name: "current-time",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 0 {
+				return nil, fmt.Errorf("current-time: wrong number of arguments")
+			}
+			return parser.Float(float64(time.Now().UnixNano()) / float64(time.Second)), nil
+		},
+	})
+	// (runtime) インタプリタの起動からの経過時間（実時間）をマイクロ秒単位の Integer で返します。
+	env.Set("runtime", &Builtin{
+		Name: "runtime",
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			if len(args) != 0 {
+				return nil, fmt.Errorf("runtime: wrong number of arguments")
+			}
+			return parser.Integer(time.Since(start).Microseconds()), nil
+		},
+	})
+}
@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// TestRuntimeIsMonotonic は runtime を処理の前後で呼び出したとき、後の値が前の値以上になることをテストします。
+func TestRuntimeIsMonotonic(t *testing.T) {
+	env := NewGlobalEnv()
+	program := `
+(define before (runtime))
+(define (square x) (* x x))
+(define (busy n) (+ (square n) (square (+ n 1)) (square (+ n 2))))
+(busy 1) (busy 2) (busy 3) (busy 4) (busy 5)
+(define after (runtime))
+`
+	if _, err := evalString(t, env, program); err != nil {
+		t.Fatalf("EvalAll error: %v", err)
+	}
+	before, _ := env.Get("before")
+	after, _ := env.Get("after")
+	b, ok1 := before.(parser.Integer)
+	a, ok2 := after.(parser.Integer)
+	if !ok1 || !ok2 {
+		t.Fatalf("expected integers, got %#v and %#v", before, after)
+	}
+	if b < 0 || a < b {
+		t.Errorf("expected 0 <= before <= after, got %d and %d", b, a)
+	}
+}
+
+// TestCurrentTime は current-time が現在の Unix 時刻（秒）を Float で返すことをテストします。
+func TestCurrentTime(t *testing.T) {
+	lower := float64(time.Now().Unix())
+	result, err := evalString(t, NewGlobalEnv(), "(current-time)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	upper := float64(time.Now().Unix() + 1)
+	f, ok := result.(parser.Float)
+	if !ok || float64(f) < lower || float64(f) > upper {
+		t.Errorf("expected a Float in [%v, %v], got %#v", lower, upper, result)
+	}
+}
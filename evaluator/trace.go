@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// callTracer は手続きの呼び出しを監視するフックです。グローバル環境で作られ、その内側の環境すべてで共有します。
+// フックは呼び出し式（(f x) など）で手続きを呼び出すたびに呼ばれます。
+// map などの組み込み関数が内部で行う呼び出しは対象外です。
+type callTracer struct {
+	hooks []func(proc Callable)
+}
+
+// called は proc の呼び出しをインストールされているフックに通知します。
+func (t *callTracer) called(proc Callable) {
+	if t == nil {
+		return
+	}
+	for _, hook := range t.hooks {
+		hook(proc)
+	}
+}
+
+// install はフックを追加し、それを取り除く関数を返します。
+func (t *callTracer) install(hook func(proc Callable)) (uninstall func()) {
+	t.hooks = append(t.hooks, hook)
+	return func() {
+		t.hooks = t.hooks[:len(t.hooks)-1]
+	}
+}
+
+// tracedProcedure は trace-define で定義された、呼び出しと戻り値を出力する手続きです。
+type tracedProcedure struct {
+	name parser.Symbol
+	proc Callable
+	// env は出力先を決める環境（trace-define を評価した環境）です。
+	env *Env
+	// depth は実行中の呼び出しのネストの深さで、出力の字下げに使います。
+	depth int
+}
+
+// Call は呼び出しを "> (name arg...)"、戻り値を "< value" として、ネストの深さに応じて字下げして出力します。
+// エラーや throw で抜けた場合は戻り値を出力しません。
+func (t *tracedProcedure) Call(args []parser.Expr) (parser.Expr, error) {
+	indent := strings.Repeat("  ", t.depth)
+	w := t.env.Output()
+	fmt.Fprintf(w, "%s> %s\n", indent, WriteString(append(parser.List{t.name}, args...)))
+	// throw で抜けた場合にも深さが戻るよう、減らす処理は defer で行う
+	t.depth++
+	defer func() { t.depth-- }()
+	result, err := t.proc.Call(args)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "%s< %s\n", indent, WriteString(result))
+	return result, nil
+}
+
+// evalTraceDefine は (trace-define (name param...) body...) を評価します。
+// define の関数定義と同じく name を束縛しますが、束縛する手続きは呼び出しと戻り値を env の出力先に出力します。
+// 本体の中の再帰呼び出しも name を通じて行われるため、同じように出力されます。
+func evalTraceDefine(exp parser.List, env *Env) (parser.Expr, error) {
+	if len(exp) < 3 {
+		return nil, fmt.Errorf("trace-define: too few arguments")
+	}
+	sig, ok := exp[1].(parser.List)
+	if !ok || len(sig) == 0 {
+		return nil, fmt.Errorf("trace-define: expected (name param...), got %s", WriteString(exp[1]))
+	}
+	name, ok := sig[0].(parser.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("trace-define: function name must be a symbol")
+	}
+	if _, err := eval(append(parser.List{parser.Symbol("define")}, exp[1:]...), env); err != nil {
+		return nil, err
+	}
+	proc, _ := env.lookup(name)
+	env.Set(name, &tracedProcedure{name: name, proc: proc.(Callable), env: env})
+	return name, nil
+}
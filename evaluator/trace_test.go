@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestTraceDefine は trace-define で定義した再帰関数の呼び出しと戻り値が、深さに応じて字下げして出力されることをテストします。
+func TestTraceDefine(t *testing.T) {
+	env := NewGlobalEnv()
+	var out bytes.Buffer
+	env.SetOutput(&out)
+	got := evalToString(t, env, `
+(trace-define (fact n)
+  (match n (0 1) (_ (* n (fact (- n 1))))))
+(fact 3)`)
+	if got != "6" {
+		t.Errorf("expected 6, got %s", got)
+	}
+	expected := `> (fact 3)
+  > (fact 2)
+    > (fact 1)
+      > (fact 0)
+      < 1
+    < 1
+  < 2
+< 6
+`
+	if out.String() != expected {
+		t.Errorf("expected trace:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// 束縛される値は呼び出しを出力する手続き
+	if got := evalToString(t, env, "fact"); got != "#<traced fact>" {
+		t.Errorf("expected #<traced fact>, got %s", got)
+	}
+	// throw で抜けた呼び出しの後も、字下げは元の深さに戻る
+	out.Reset()
+	evalToString(t, env, `
+(trace-define (f n)
+  (match n (0 'done) (_ (match n (1 (throw 'k 'escaped)) (_ (f (- n 1)))))))
+(catch 'k (f 2))
+(f 0)`)
+	expected = `> (f 2)
+  > (f 1)
+> (f 0)
+< done
+`
+	if out.String() != expected {
+		t.Errorf("expected trace:\n%s\ngot:\n%s", expected, out.String())
+	}
+	for _, input := range []string{"(trace-define x 1)", "(trace-define (f))", "(trace-define (1 x) x)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
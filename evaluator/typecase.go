@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// typecasePredicates は typecase の節に書ける型の名前と、値がその型であるかを判定する関数です。
+var typecasePredicates = map[parser.Symbol]func(parser.Expr) bool{
+	"integer":   func(v parser.Expr) bool { _, ok := v.(parser.Integer); return ok },
+	"string":    func(v parser.Expr) bool { _, ok := v.(parser.String); return ok },
+	"symbol":    func(v parser.Expr) bool { _, ok := v.(parser.Symbol); return ok },
+	"list":      func(v parser.Expr) bool { _, ok := v.(parser.List); return ok },
+	"boolean":   func(v parser.Expr) bool { _, ok := v.(parser.Boolean); return ok },
+	"procedure": func(v parser.Expr) bool { _, ok := v.(Callable); return ok },
+}
+
+// typecaseClause は (typecase expr (type body...) ... (else body...)) の expr を評価し、
+// 値の型が type に一致する最初の節（else はどの値にも一致する）の body を返します。
+// body は末尾位置にあるため、評価は呼び出し側の eval が行います。どの節にも一致しなければ空の body を返します。
+func typecaseClause(exp parser.List, env *Env) ([]parser.Expr, error) {
+	if len(exp) < 2 {
+		return nil, fmt.Errorf("typecase: too few arguments")
+	}
+	val, err := evalExpr(exp[1], env)
+	if err != nil {
+		return nil, err
+	}
+	for i, clause := range exp[2:] {
+		c, ok := clause.(parser.List)
+		if !ok || len(c) == 0 {
+			return nil, fmt.Errorf("typecase: clause must be a non-empty list, got %s", WriteString(clause))
+		}
+		typ, ok := c[0].(parser.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("typecase: type must be a symbol, got %s", WriteString(c[0]))
+		}
+		if typ == "else" {
+			if i != len(exp)-3 {
+				return nil, fmt.Errorf("typecase: else clause must be last")
+			}
+			return c[1:], nil
+		}
+		pred, ok := typecasePredicates[typ]
+		if !ok {
+			return nil, fmt.Errorf("typecase: unknown type %s", typ)
+		}
+		if pred(val) {
+			return c[1:], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,32 @@
+package evaluator
+
+import "testing"
+
+// TestTypecase は typecase が値の型に一致する最初の節を評価し、一致しなければ else 節を評価することをテストします。
+func TestTypecase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(define (describe x) (typecase x (integer 'int) (string 'str) (else 'other))) (describe 42)`, "int"},
+		{`(define (describe x) (typecase x (integer 'int) (string 'str) (else 'other))) (describe "hi")`, "str"},
+		{`(define (describe x) (typecase x (integer 'int) (string 'str) (else 'other))) (describe 'sym)`, "other"},
+		{`(typecase '(1 2) (symbol 'sym) (list 'list) (else 'other))`, "list"},
+		{`(typecase + (procedure 'proc) (else 'other))`, "proc"},
+		{`(typecase #f (boolean 'bool))`, "bool"},
+		// 本体は複数の式を順に評価し、最後の値を返す
+		{`(typecase 'a (symbol 1 2 3))`, "3"},
+		// 一致する節がなければ未規定値
+		{`(typecase 1.5 (integer 'int))`, ""},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(typecase)", "(typecase 1 (number 'n))", "(typecase 1 (else 'a) (integer 'b))", "(typecase 1 42)", "(typecase 1 ((integer) 'a))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// MultipleValues は values によって返される複数の値です。
+// 値が1つの場合は MultipleValues を作らず、その値自身を返します。
+type MultipleValues []parser.Expr
+
+// makeValues は値の並びを values の結果に変換します。
+func makeValues(vals []parser.Expr) parser.Expr {
+	if len(vals) == 1 {
+		return vals[0]
+	}
+	return MultipleValues(append([]parser.Expr(nil), vals...))
+}
+
+// valuesOf は値を values の並びとして解釈します。MultipleValues 以外は1つの値とみなします。
+func valuesOf(val parser.Expr) []parser.Expr {
+	if mv, ok := val.(MultipleValues); ok {
+		return mv
+	}
+	return []parser.Expr{val}
+}
+
+// builtinValues は "values" を実装します。
+func builtinValues(args []parser.Expr) (parser.Expr, error) {
+	return makeValues(args), nil
+}
+
+// builtinCallWithValues は "call-with-values" を実装します。
+// (call-with-values producer consumer) producer の返す値を引数として consumer を呼び出します。
+func builtinCallWithValues(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("call-with-values: wrong number of arguments")
+	}
+	producer, err := procArg("call-with-values", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := procArg("call-with-values", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := producer.Call(nil)
+	if err != nil {
+		return nil, err
+	}
+	return consumer.Call(valuesOf(vals))
+}
+
+// builtinValuesToList は "values->list" を実装します。
+// (values->list thunk) thunk の返す値をリストにまとめます。
+func builtinValuesToList(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("values->list: wrong number of arguments")
+	}
+	thunk, err := procArg("values->list", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := thunk.Call(nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(parser.List{}, valuesOf(vals)...), nil
+}
+
+// builtinListToValues は "list->values" を実装します。
+// (list->values list) リストの要素を複数の値として返します。
+func builtinListToValues(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("list->values: wrong number of arguments")
+	}
+	list, err := listArg("list->values", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return makeValues(list), nil
+}
+
+// receiveFormals は receive の仮引数を解釈し、固定の仮引数と残りの値を受け取る仮引数（なければ空）を返します。
+// 仮引数は (a b)、(a b . rest)、または全体をリストで受け取る rest の形をとります。
+func receiveFormals(formals parser.Expr) ([]parser.Symbol, parser.Symbol, error) {
+	if rest, ok := formals.(parser.Symbol); ok {
+		return nil, rest, checkBindable("receive", rest)
+	}
+	list, ok := formals.(parser.List)
+	if !ok {
+		return nil, "", fmt.Errorf("receive: formals must be a symbol or a list, got %s", WriteString(formals))
+	}
+	var fixed []parser.Symbol
+	var rest parser.Symbol
+	for i := 0; i < len(list); i++ {
+		sym, ok := list[i].(parser.Symbol)
+		if !ok {
+			return nil, "", fmt.Errorf("receive: formals must be symbols, got %s", WriteString(list[i]))
+		}
+		if sym == "." {
+			if i != len(list)-2 {
+				return nil, "", fmt.Errorf("receive: malformed rest parameter in %s", WriteString(formals))
+			}
+			if rest, ok = list[i+1].(parser.Symbol); !ok {
+				return nil, "", fmt.Errorf("receive: formals must be symbols, got %s", WriteString(list[i+1]))
+			}
+			if err := checkBindable("receive", rest); err != nil {
+				return nil, "", err
+			}
+			break
+		}
+		if err := checkBindable("receive", sym); err != nil {
+			return nil, "", err
+		}
+		fixed = append(fixed, sym)
+	}
+	return fixed, rest, nil
+}
+
+// receiveBody は SRFI-8 の (receive formals producer body...) の producer を評価し、
+// その返す多値を formals に束縛した新しい環境と body を返します。body の評価は呼び出し側の eval が行います。
+// (a b . rest) の rest には、固定の仮引数に束縛した残りの値がリストとして束縛されます。
+func receiveBody(exp parser.List, env *Env) ([]parser.Expr, *Env, error) {
+	if len(exp) < 4 {
+		return nil, nil, fmt.Errorf("receive: too few arguments")
+	}
+	fixed, rest, err := receiveFormals(exp[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	produced, err := evalExpr(exp[2], env)
+	if err != nil {
+		return nil, nil, err
+	}
+	vals := valuesOf(produced)
+	if len(vals) < len(fixed) || (rest == "" && len(vals) != len(fixed)) {
+		return nil, nil, fmt.Errorf("receive: expected %d values for %s, got %d", len(fixed), WriteString(exp[1]), len(vals))
+	}
+	newEnv := NewEnv(env)
+	for i, sym := range fixed {
+		newEnv.Set(sym, vals[i])
+	}
+	if rest != "" {
+		newEnv.Set(rest, append(parser.List{}, vals[len(fixed):]...))
+	}
+	return exp[3:], newEnv, nil
+}
+
+// registerValuesBuiltins は多値関連の組み込み関数を環境に登録します。
+func registerValuesBuiltins(env *Env) {
+	env.Set("values", &Builtin{Name: "values", Fn: builtinValues})
+	env.Set("call-with-values", &Builtin{Name: "call-with-values", Fn: builtinCallWithValues})
+	env.Set("values->list", &Builtin{Name: "values->list", Fn: builtinValuesToList})
+	env.Set("list->values", &Builtin{Name: "list->values", Fn: builtinListToValues})
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import "testing"
+
+// TestValuesListInterop は values->list と list->values による多値とリストの相互変換をテストします。
+func TestValuesListInterop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(values->list (lambda () (values 1 2 3)))", "(1 2 3)"},
+		{"(values->list (lambda () (values)))", "()"},
+		{"(values->list (lambda () 42))", "(42)"},
+		{"(values->list (lambda () (list->values '(a b c))))", "(a b c)"},
+		{"(list->values '(1))", "1"},
+		{"(list->values '(1 2))", "1 2"},
+		{"(call-with-values (lambda () (values 1 2)) +)", "3"},
+		{"(call-with-values (lambda () (list->values '(2 3 4))) *)", "24"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(values->list 1)", "(list->values 1)", "(call-with-values 1 +)"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestReceive は receive が producer の多値を固定の仮引数と残りの仮引数に束縛して本体を評価することをテストします。
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(receive (a b) (values 1 2) (list b a))", "(2 1)"},
+		{"(receive (a b . rest) (values 1 2 3 4 5) (list a b rest))", "(1 2 (3 4 5))"},
+		{"(receive (a . rest) (values 1) (list a rest))", "(1 ())"},
+		{"(receive all (values 1 2 3) all)", "(1 2 3)"},
+		{"(receive (x) 42 x)", "42"},
+		{"(receive () (values) 'none)", "none"},
+		{"(define (div-mod a b) (values (quotient a b) (remainder a b))) (receive (q r) (div-mod 17 5) (list q r))", "(3 2)"},
+		// 束縛は receive の内側だけで有効
+		{"(define a 'outer) (receive (a) (values 'inner) a) a", "outer"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{
+		"(receive (a b) (values 1) a)",
+		"(receive (a b) (values 1 2 3) a)",
+		"(receive (a 1) (values 1 2) a)",
+		"(receive (a . b c) (values 1 2 3) a)",
+		"(receive (a define) (values 1 2) a)",
+		"(receive (a) (values 1))",
+	} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Warashi/lispish/parser"
+)
+
+// Vector は Scheme のベクタを表します。リーダーが #(...) から作るベクタと同じ型です。
+type Vector = parser.Vector
+
+// vectorArg は args[i] がベクタであることを確認して返します。
+func vectorArg(name string, args []parser.Expr, i int) (*Vector, error) {
+	v, ok := args[i].(*Vector)
+	if !ok {
+		return nil, fmt.Errorf("%s: argument %d must be a vector, got %T", name, i+1, args[i])
+	}
+	return v, nil
+}
+
+// builtinVector は "vector" を実装します。
+// (vector elem...) 引数を要素とするベクタを返します。
+func builtinVector(args []parser.Expr) (parser.Expr, error) {
+	return &Vector{Elems: append([]parser.Expr(nil), args...)}, nil
+}
+
+// builtinVectorLength は "vector-length" を実装します。
+func builtinVectorLength(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("vector-length: wrong number of arguments")
+	}
+	v, err := vectorArg("vector-length", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return parser.Integer(len(v.Elems)), nil
+}
+
+// builtinVectorRef は "vector-ref" を実装します。
+// (vector-ref vec k) k 番目（0 始まり）の要素を返します。
+func builtinVectorRef(args []parser.Expr) (parser.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("vector-ref: wrong number of arguments")
+	}
+	v, err := vectorArg("vector-ref", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	k, err := indexArg("vector-ref", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if k >= len(v.Elems) {
+		return nil, fmt.Errorf("vector-ref: index %d out of range for length %d", k, len(v.Elems))
+	}
+	return v.Elems[k], nil
+}
+
+// builtinStringToVector は "string->vector" を実装します。
+// (string->vector str [start [end]]) 文字（rune）単位の範囲の各文字を要素とするベクタを返します。
+func builtinStringToVector(args []parser.Expr) (parser.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("string->vector: wrong number of arguments")
+	}
+	s, err := stringArg("string->vector", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, end, err := runeRange("string->vector", args, 1, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]parser.Expr, 0, end-start)
+	for _, r := range runes[start:end] {
+		elems = append(elems, parser.Char(r))
+	}
+	return &Vector{Elems: elems}, nil
+}
+
+// builtinVectorToString は "vector->string" を実装します。
+// (vector->string vec [start [end]]) 範囲の要素（すべて文字でなければならない）を連結した文字列を返します。
+func builtinVectorToString(args []parser.Expr) (parser.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("vector->string: wrong number of arguments")
+	}
+	v, err := vectorArg("vector->string", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := runeRange("vector->string", args, 1, len(v.Elems))
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, 0, end-start)
+	for i, elem := range v.Elems[start:end] {
+		c, ok := elem.(parser.Char)
+		if !ok {
+			return nil, fmt.Errorf("vector->string: element %d must be a character, got %s", start+i, WriteString(elem))
+		}
+		runes = append(runes, rune(c))
+	}
+	return parser.String(runes), nil
+}
+
+// numericVectorArg は (name vec) の vec が、数値だけを要素とするベクタであることを確認して返します。
+// 要素に Float があるかも返します。
+func numericVectorArg(name string, args []parser.Expr) (v *Vector, inexact bool, err error) {
+	if len(args) != 1 {
+		return nil, false, fmt.Errorf("%s: wrong number of arguments", name)
+	}
+	if v, err = vectorArg(name, args, 0); err != nil {
+		return nil, false, err
+	}
+	if inexact, err = numericElems(name, v.Elems); err != nil {
+		return nil, false, err
+	}
+	return v, inexact, nil
+}
+
+// builtinVectorSum は "vector-sum" を実装します。
+// (vector-sum vec) 要素の和を + と同じ規則で求めます。空のベクタの和は 0 です。
+func builtinVectorSum(args []parser.Expr) (parser.Expr, error) {
+	v, _, err := numericVectorArg("vector-sum", args)
+	if err != nil {
+		return nil, err
+	}
+	return addOp.fold(parser.Integer(0), v.Elems)
+}
+
+// makeVectorExtremum は、要素のうち better が真になる（より大きい、またはより小さい）ものを選ぶ
+// vector-max や vector-min を作ります。要素に Float があれば、結果も Float にします。
+func makeVectorExtremum(name string, better func(c int) bool) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(args []parser.Expr) (parser.Expr, error) {
+			v, inexact, err := numericVectorArg(name, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(v.Elems) == 0 {
+				return nil, fmt.Errorf("%s: empty vector", name)
+			}
+			return extremum(v.Elems, inexact, better), nil
+		},
+	}
+}
+
+// registerVectorBuiltins はベクタ関連の組み込み関数を環境に登録します。
+func registerVectorBuiltins(env *Env) {
+	env.Set("vector", &Builtin{Name: "vector", Fn: builtinVector})
+	env.Set("vector-length", &Builtin{Name: "vector-length", Fn: builtinVectorLength})
+	env.Set("vector-ref", &Builtin{Name: "vector-ref", Fn: builtinVectorRef})
+	env.Set("string->vector", &Builtin{Name: "string->vector", Fn: builtinStringToVector})
+	env.Set("vector->string", &Builtin{Name: "vector->string", Fn: builtinVectorToString})
+	env.Set("vector-sum", &Builtin{Name: "vector-sum", Fn: builtinVectorSum})
+	env.Set("vector-max", makeVectorExtremum("vector-max", func(c int) bool { return c > 0 }))
+	env.Set("vector-min", makeVectorExtremum("vector-min", func(c int) bool { return c < 0 }))
+}
@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestVectorBuiltins は vector、vector-length、vector-ref と、ベクタの外部表現をテストします。
+func TestVectorBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(vector 1 \"two\" 'three)", `#(1 "two" three)`},
+		{"(vector)", "#()"},
+		{"(vector-length (vector 1 2 3))", "3"},
+		{"(vector-ref (vector 1 2 3) 0)", "1"},
+		{"(vector-ref (vector 1 2 3) 2)", "3"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+	for _, input := range []string{"(vector-ref (vector 1) 1)", "(vector-ref (vector 1) 'a)", "(vector-length '(1))"} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestStringVectorConversion は string->vector と vector->string が範囲指定を含めて相互に変換できることをテストします。
+func TestStringVectorConversion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(string->vector "abc")`, `#(#\a #\b #\c)`},
+		{`(string->vector "日本語" 1)`, `#(#\本 #\語)`},
+		{`(string->vector "hello" 1 3)`, `#(#\e #\l)`},
+		{`(string->vector "")`, "#()"},
+		{`(vector->string (vector #\a #\b #\c))`, `"abc"`},
+		{`(vector->string (vector #\a #\b #\c) 1 2)`, `"b"`},
+		{`(vector->string (string->vector "日本語"))`, `"日本語"`},
+		{`(string->vector (vector->string (vector #\x #\space #\y)))`, `#(#\x #\space #\y)`},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	_, err := evalString(t, NewGlobalEnv(), `(vector->string (vector #\a 1 #\c))`)
+	if err == nil || !strings.Contains(err.Error(), "element 1 must be a character") {
+		t.Errorf("expected non-char element error, got %v", err)
+	}
+	for _, input := range []string{`(string->vector "abc" 2 4)`, `(string->vector 'abc)`, `(vector->string (vector #\a) 0 2)`, `(vector->string "a")`} {
+		if _, err := evalString(t, NewGlobalEnv(), input); err == nil {
+			t.Errorf("%s: expected error, got nil", input)
+		}
+	}
+}
+
+// TestVectorNumericReductions は vector-sum、vector-max、vector-min が + と同じ数値の昇格規則に従うことをテストします。
+func TestVectorNumericReductions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(vector-sum (vector 1 2 3 4))", "10"},
+		{"(vector-max (vector 3 1 4 1 5))", "5"},
+		{"(vector-min (vector 3 1 4 1 5))", "1"},
+		// Float を含むと結果も Float になる
+		{"(vector-sum (vector 1 2.5 3))", "6.5"},
+		{"(vector-max (vector 1 2.5 3))", "3.0"},
+		{"(vector-min (vector 1 2.5 3))", "1.0"},
+		{"(vector-sum (vector 1/2 1/3))", "5/6"},
+		{"(vector-max (vector 1/2 1/3))", "1/2"},
+		{"(vector-sum (vector))", "0"},
+		{"(vector-max (vector -7))", "-7"},
+	}
+	for _, tt := range tests {
+		if got := evalToString(t, NewGlobalEnv(), tt.input); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(vector-max (vector))", "vector-max: empty vector"},
+		{"(vector-min (vector))", "vector-min: empty vector"},
+		{`(vector-sum (vector 1 "two" 3))`, `vector-sum: element 1 must be a number, got "two"`},
+		{"(vector-min (vector 1 'a))", "vector-min: element 1 must be a number, got a"},
+		{"(vector-sum '(1 2))", "vector-sum: argument 1 must be a vector, got parser.List"},
+	}
+	for _, tt := range errTests {
+		_, err := evalString(t, NewGlobalEnv(), tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
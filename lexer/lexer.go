@@ -1,25 +1,41 @@
 package lexer
 
 import (
+	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"text/scanner"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType はトークンの種類を表します。
 type TokenType int
 
 const (
-	TokenEOF TokenType = iota
-	TokenLParen      // (
-	TokenRParen      // )
-	TokenQuote       // '
-	TokenIdentifier  // 識別子
-	TokenInteger     // 整数
-	TokenFloat       // 浮動小数点数
-	TokenString      // 文字列リテラル
-	TokenComment     // コメント
+	TokenEOF        TokenType = iota
+	TokenLParen               // (
+	TokenRParen               // )
+	TokenQuote                // '
+	TokenIdentifier           // 識別子
+	TokenInteger              // 整数
+	TokenFloat                // 浮動小数点数
+	TokenString               // 文字列リテラル
+	TokenComment              // コメント
+	TokenBoolean              // 真偽値リテラル（#t, #f）
+	TokenChar                 // 文字リテラル（#\a, #\space など）
+	TokenIllegal              // 不正な入力（閉じられていない |...| や文字列、不正なエスケープ、] などの使えない文字）
+	TokenDot                  // 単独の .（(a . b) の区切り）
+	TokenRational             // 分数（1/3 など）
+	TokenDatumLabel           // データラベルの定義（#1= など）
+	TokenDatumRef             // データラベルの参照（#1# など）
+	TokenVectorOpen           // ベクタの開始 "#("
+
+	// numTokenTypes はトークン種別の数です。新しい種別はこの上に追加してください。
+	numTokenTypes
 )
 
 // String は TokenType の文字列表現を返します。
@@ -43,15 +59,45 @@ func (t TokenType) String() string {
 		return "String"
 	case TokenComment:
 		return "Comment"
+	case TokenBoolean:
+		return "Boolean"
+	case TokenChar:
+		return "Char"
+	case TokenIllegal:
+		return "Illegal"
+	case TokenDot:
+		return "Dot"
+	case TokenRational:
+		return "Rational"
+	case TokenDatumLabel:
+		return "DatumLabel"
+	case TokenDatumRef:
+		return "DatumRef"
+	case TokenVectorOpen:
+		return "VectorOpen"
 	default:
 		return "Unknown"
 	}
 }
 
+// Position はソース上の位置を表します。
+type Position struct {
+	Offset int // バイトオフセット（0 始まり）
+	Line   int // 行番号（1 始まり）
+	Column int // 列番号（1 始まり、文字単位）
+}
+
 // Token は字句解析された1単位（トークン）を表します。
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // トークンの開始位置
+	End     Position // トークン直後の位置
+}
+
+// String はトークンを "Type(Literal)@Line:Column" の形式で返します（デバッグ用）。
+func (t Token) String() string {
+	return fmt.Sprintf("%s(%q)@%d:%d", t.Type, t.Literal, t.Pos.Line, t.Pos.Column)
 }
 
 // Lexer は Scheme の入力を走査する字句解析器です。
@@ -67,8 +113,16 @@ func NewLexer(r io.Reader) *Lexer {
 	s.Mode = scanner.ScanIdents | scanner.ScanStrings | scanner.ScanInts | scanner.ScanFloats
 	// デフォルトの Whitespace には改行('\n')も含まれるため、コメント終了検出のために改行は除外する
 	s.Whitespace = scanner.GoWhitespace &^ (1 << '\n')
+	// 文字列のエスケープなどの誤りは TokenIllegal として報告するため、text/scanner のエラー出力は抑止する
+	s.Error = func(*scanner.Scanner, string) {}
 	// Scheme では識別子に記号などが使われることがあるため、IsIdentRune を上書き
+	// label はデータラベルの定義 "#1=" を読んでいる途中かどうかの状態です。
+	// "#1=(a b)" や "#1=#1#" のように定義の直後に空白なしでデータが続いても、"=" で識別子を区切ります。
+	label := labelNone
 	s.IsIdentRune = func(ch rune, i int) bool {
+		if label = nextLabelState(label, ch, i); label == labelEnd {
+			return false
+		}
 		// '#' はどこでも許容（例: #t, #f など）
 		if ch == '#' {
 			return true
@@ -88,13 +142,386 @@ func NewLexer(r io.Reader) *Lexer {
 	return &Lexer{s: s}
 }
 
+// データラベルの定義 "#1=" を読み進める状態です。
+const (
+	labelNone   = iota // データラベルではない
+	labelHash          // 先頭の "#" を読んだ
+	labelDigits        // "#" に続く数字を読んでいる
+	labelEquals        // "#1=" まで読んだ
+	labelEnd           // "#1=" の次の文字で、識別子はここで終わる
+)
+
+// nextLabelState は識別子の i 文字目 ch を読んだときの、データラベルの定義を読む状態を返します。
+func nextLabelState(state int, ch rune, i int) int {
+	if i == 0 {
+		if ch == '#' {
+			return labelHash
+		}
+		return labelNone
+	}
+	switch {
+	case state == labelHash && '0' <= ch && ch <= '9':
+		return labelDigits
+	case state == labelDigits && '0' <= ch && ch <= '9':
+		return labelDigits
+	case state == labelDigits && ch == '=':
+		return labelEquals
+	case state == labelEquals:
+		return labelEnd
+	}
+	return labelNone
+}
+
+// position は text/scanner の位置を Position に変換します。
+func position(p scanner.Position) Position {
+	return Position{Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+// readCharName は文字リテラル "#\" に続く文字名（"a" や "space" など）を読み取ります。
+// 1文字目は記号を含む任意の文字で、英字で始まる場合は続く英数字も名前の一部とします。
+// "x41;" のように "x" と16進数に ";" が続く場合は、その符号位置の文字を名前として返します。
+// 符号位置が不正な場合は ok が false で、name は読み取った字句です。
+func (l *Lexer) readCharName() (name string, ok bool) {
+	l.s.Next() // '\' を読み飛ばす
+	first := l.s.Next()
+	if first == scanner.EOF {
+		return "", true
+	}
+	runes := []rune{first}
+	if unicode.IsLetter(first) {
+		for ch := l.s.Peek(); unicode.IsLetter(ch) || unicode.IsDigit(ch); ch = l.s.Peek() {
+			runes = append(runes, l.s.Next())
+		}
+	}
+	name = string(runes)
+	if first != 'x' || len(runes) == 1 || l.s.Peek() != ';' {
+		return name, true
+	}
+	l.s.Next()
+	r, ok := hexCodePoint(name[1:])
+	if !ok {
+		return name + ";", false
+	}
+	return string(r), true
+}
+
+// hexCodePoint は16進数の字句 hex を符号位置として解釈します。
+// 16進数でない場合や、有効な Unicode の符号位置でない場合は false を返します。
+func hexCodePoint(hex string) (rune, bool) {
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(v)) {
+		return 0, false
+	}
+	return rune(v), true
+}
+
+// unquoteString は text/scanner が読み取った文字列リテラル（囲みの引用符を含む）のエスケープを解釈します。
+// "\x41;" のような16進数のエスケープは ";" で終わる必要があり、その他のエスケープは Go の文字列と同じです。
+// 閉じられていない場合やエスケープが不正な場合は false を返します。
+func unquoteString(text string) (string, bool) {
+	if len(text) < 2 || text[len(text)-1] != '"' {
+		return "", false
+	}
+	body := text[1 : len(text)-1]
+	var sb strings.Builder
+	for len(body) > 0 {
+		if strings.HasPrefix(body, `\x`) {
+			end := strings.IndexFunc(body[2:], func(ch rune) bool { return !isHexDigit(ch) }) + 2
+			if end < 2 || body[end] != ';' {
+				return "", false
+			}
+			r, ok := hexCodePoint(body[2:end])
+			if !ok {
+				return "", false
+			}
+			sb.WriteRune(r)
+			body = body[end+1:]
+			continue
+		}
+		r, _, tail, err := strconv.UnquoteChar(body, '"')
+		if err != nil {
+			return "", false
+		}
+		sb.WriteRune(r)
+		body = tail
+	}
+	return sb.String(), true
+}
+
+// isHexDigit は ch が16進数の数字であるかを判定します。
+func isHexDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// readPipeSymbol は "|" で囲まれたシンボル（"|hello world|" など）の残りを読み取ります。
+// 開始の "|" は読み込み済みとし、内側の文字は空白も含めてそのままシンボル名とします。
+// "\|" と "\\" はそれぞれ "|" と "\" を表します。閉じる "|" がなければ ok は false です。
+func (l *Lexer) readPipeSymbol() (name string, ok bool) {
+	var runes []rune
+	for {
+		ch := l.s.Next()
+		switch ch {
+		case scanner.EOF:
+			return string(runes), false
+		case '|':
+			return string(runes), true
+		case '\\':
+			if next := l.s.Peek(); next == '|' || next == '\\' {
+				ch = l.s.Next()
+			}
+		}
+		runes = append(runes, ch)
+	}
+}
+
+// signedNumber は符号付きの数値リテラル（"-1" や "+2.5e3" など）を読み取ります。
+// text/scanner は "-1" を識別子として読むため、識別子の字句が符号と数字で始まる場合にここで数値として解釈します。
+// "-1.5" のように小数点が続く場合は、小数部と指数部を続けて読み取ります。
+func (l *Lexer) signedNumber(text string) (TokenType, string, bool) {
+	if len(text) < 2 || (text[0] != '-' && text[0] != '+') || text[1] < '0' || text[1] > '9' {
+		return 0, "", false
+	}
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		if l.s.Peek() != '.' {
+			return TokenInteger, text, true
+		}
+		literal := []rune(text)
+		literal = append(literal, l.s.Next())
+		for ch := l.s.Peek(); unicode.IsDigit(ch); ch = l.s.Peek() {
+			literal = append(literal, l.s.Next())
+		}
+		if ch := l.s.Peek(); ch == 'e' || ch == 'E' {
+			literal = append(literal, l.s.Next())
+			if ch := l.s.Peek(); ch == '-' || ch == '+' {
+				literal = append(literal, l.s.Next())
+			}
+			for ch := l.s.Peek(); unicode.IsDigit(ch); ch = l.s.Peek() {
+				literal = append(literal, l.s.Next())
+			}
+		}
+		return TokenFloat, string(literal), true
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return TokenFloat, text, true
+	}
+	return 0, "", false
+}
+
+// specialFloat は無限大と NaN のリテラル（"+inf.0" や "-nan.0" など）を読み取ります。
+// text/scanner は "+inf" までを識別子として読むため、続く ".0" をここで読み取ります。
+// "+inf." のように "0" が続かない場合は、読み取った "." までを識別子とします。
+func (l *Lexer) specialFloat(text string) (TokenType, string, bool) {
+	switch text {
+	case "+inf", "-inf", "+nan", "-nan":
+	default:
+		return 0, "", false
+	}
+	if l.s.Peek() != '.' {
+		return 0, "", false
+	}
+	l.s.Next()
+	if l.s.Peek() != '0' {
+		return TokenIdentifier, text + ".", true
+	}
+	l.s.Next()
+	return TokenFloat, text + ".0", true
+}
+
+// isRationalLiteral は text が符号付きの分数リテラル（"-1/3" など）であるかを判定します。
+// 分子・分母の値（分母が 0 でないかなど）はパーサが検証します。
+func isRationalLiteral(text string) bool {
+	if len(text) > 0 && (text[0] == '-' || text[0] == '+') {
+		text = text[1:]
+	}
+	slash := strings.IndexByte(text, '/')
+	if slash <= 0 || slash == len(text)-1 {
+		return false
+	}
+	for i, ch := range text {
+		if i != slash && (ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// datumLabel は text が共有構造のデータラベルの定義 "#1=" または参照 "#1#" であるかを判定し、そのトークンの種類を返します。
+// "#1=(a b)" のように定義の直後に続くデータは、別のトークンとして読み取ります。
+func datumLabel(text string) (TokenType, bool) {
+	if len(text) < 3 || text[0] != '#' {
+		return 0, false
+	}
+	digits := text[1 : len(text)-1]
+	if strings.IndexFunc(digits, func(ch rune) bool { return ch < '0' || ch > '9' }) >= 0 {
+		return 0, false
+	}
+	switch text[len(text)-1] {
+	case '=':
+		return TokenDatumLabel, true
+	case '#':
+		return TokenDatumRef, true
+	}
+	return 0, false
+}
+
+// prefixedNumber は "#x" などの基数の接頭辞や "#e"/"#i" の正確性の接頭辞が付いた数値リテラル
+// （"#xff"、"#e3.0"、"#e#x10" など）を読み取ります。接頭辞で始まらない字句の場合は ok が false です。
+// text/scanner は "#e3" のように "." の手前までを識別子として読むため、続く小数部などをここで読み取ります。
+// 値は接頭辞を解釈した10進数の Integer・Rational・Float のトークンとして返し、
+// 接頭辞と数値の組み合わせが不正な場合（"#e+inf.0" など）は TokenIllegal を返します。
+func (l *Lexer) prefixedNumber(text string) (typ TokenType, literal string, ok bool) {
+	if len(text) < 2 || text[0] != '#' || !strings.ContainsRune("eEiIxXbBoOdD", rune(text[1])) {
+		return 0, "", false
+	}
+	for ch := l.s.Peek(); ch == '.' || ch == '+' || ch == '-' || ch == '/' || unicode.IsLetter(ch) || unicode.IsDigit(ch); ch = l.s.Peek() {
+		text += string(l.s.Next())
+	}
+	typ, literal, valid := parsePrefixedNumber(text)
+	if !valid {
+		return TokenIllegal, text, true
+	}
+	return typ, literal, true
+}
+
+// parsePrefixedNumber は接頭辞付きの数値リテラル text を解釈し、10進数のトークンの種類と字句を返します。
+// 基数の接頭辞は #b #o #d #x、正確性の接頭辞は #e（正確数）と #i（不正確数）で、それぞれ1つまで任意の順に付けられます。
+// 正確性の指定がなければ、10進数の小数や指数表記は不正確数、それ以外は正確数です。
+func parsePrefixedNumber(text string) (TokenType, string, bool) {
+	radix := 0
+	var exactness byte
+	for len(text) >= 2 && text[0] == '#' {
+		switch c := unicode.ToLower(rune(text[1])); c {
+		case 'e', 'i':
+			if exactness != 0 {
+				return 0, "", false
+			}
+			exactness = byte(c)
+		case 'b', 'o', 'd', 'x':
+			if radix != 0 {
+				return 0, "", false
+			}
+			radix = map[rune]int{'b': 2, 'o': 8, 'd': 10, 'x': 16}[c]
+		default:
+			return 0, "", false
+		}
+		text = text[2:]
+	}
+	if radix == 0 {
+		radix = 10
+	}
+	if text == "" {
+		return 0, "", false
+	}
+	switch text {
+	case "+inf.0", "-inf.0", "+nan.0", "-nan.0":
+		// 無限大と NaN は正確数で表せない
+		if exactness == 'e' || radix != 10 {
+			return 0, "", false
+		}
+		return TokenFloat, text, true
+	}
+
+	var exact *big.Rat
+	decimal := false
+	if radix == 10 && strings.ContainsAny(text, ".eE") {
+		// 10進数の小数（"3.0" や "1.5e2"）
+		if strings.ContainsAny(text, "/") || strings.IndexFunc(text, func(ch rune) bool {
+			return !unicode.IsDigit(ch) && !strings.ContainsRune(".eE+-", ch)
+		}) >= 0 {
+			return 0, "", false
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0, "", false
+		}
+		if exactness != 'e' {
+			return TokenFloat, formatFloatLiteral(f), true
+		}
+		var ok bool
+		if exact, ok = new(big.Rat).SetString(text); !ok {
+			return 0, "", false
+		}
+		decimal = true
+	} else {
+		// 整数または分数。分子と分母は基数に従って読み取る
+		var ok bool
+		if exact, ok = parseRadixRational(text, radix); !ok {
+			return 0, "", false
+		}
+	}
+	if exactness == 'i' && !decimal {
+		f, _ := exact.Float64()
+		return TokenFloat, formatFloatLiteral(f), true
+	}
+	if exact.IsInt() {
+		return TokenInteger, exact.Num().String(), true
+	}
+	return TokenRational, exact.String(), true
+}
+
+// parseRadixRational は符号付きの整数 "n" または分数 "n/d" を基数 radix で読み取ります。
+func parseRadixRational(text string, radix int) (*big.Rat, bool) {
+	numText, denText, isFraction := strings.Cut(text, "/")
+	sign := ""
+	if numText != "" && (numText[0] == '+' || numText[0] == '-') {
+		sign, numText = numText[:1], numText[1:]
+	}
+	num, ok := parseRadixNatural(numText, radix)
+	if !ok {
+		return nil, false
+	}
+	if sign == "-" {
+		num.Neg(num)
+	}
+	den := big.NewInt(1)
+	if isFraction {
+		if den, ok = parseRadixNatural(denText, radix); !ok || den.Sign() == 0 {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+// parseRadixNatural は符号のない整数を基数 radix で読み取ります。
+func parseRadixNatural(text string, radix int) (*big.Int, bool) {
+	if text == "" || text[0] == '+' || text[0] == '-' || text[0] == '_' {
+		return nil, false
+	}
+	return new(big.Int).SetString(text, radix)
+}
+
+// formatFloatLiteral は浮動小数点数をパーサが読み取れる字句にします。
+// 整数値でも小数点を付け、無限大と NaN は +inf.0 などで表します。
+func formatFloatLiteral(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	case math.IsNaN(f):
+		return "+nan.0"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// token は開始位置 pos から現在の走査位置までを占めるトークンを生成します。
+func (l *Lexer) token(typ TokenType, literal string, pos Position) Token {
+	return Token{Type: typ, Literal: literal, Pos: pos, End: position(l.s.Pos())}
+}
+
 // NextToken は入力から次のトークンを返します。
-// Scheme のコメント（';' から行末まで）は読み飛ばします。
+// トークンには開始位置と直後の位置が記録されます。
 func (l *Lexer) NextToken() Token {
 	for {
 		tok := l.s.Scan()
+		pos := position(l.s.Position)
 		if tok == scanner.EOF {
-			return Token{Type: TokenEOF, Literal: ""}
+			end := position(l.s.Pos())
+			return Token{Type: TokenEOF, Literal: "", Pos: end, End: end}
 		}
 		text := l.s.TokenText()
 
@@ -106,46 +533,112 @@ func (l *Lexer) NextToken() Token {
 			l.s.Whitespace = 0
 
 			commentText := ";"
+			end := position(l.s.Pos())
 			for {
 				tok = l.s.Scan()
 				if tok == '\n' || tok == scanner.EOF {
 					break
 				}
 				commentText += l.s.TokenText()
+				end = position(l.s.Pos())
 			}
 
 			// Restore the original whitespace flag
 			l.s.Whitespace = originalWhitespace
-			return Token{Type: TokenComment, Literal: commentText}
+			return Token{Type: TokenComment, Literal: commentText, Pos: pos, End: end}
 		}
 
 		switch tok {
 		case '(':
-			return Token{Type: TokenLParen, Literal: text}
+			return l.token(TokenLParen, text, pos)
 		case ')':
-			return Token{Type: TokenRParen, Literal: text}
+			return l.token(TokenRParen, text, pos)
 		case '\'':
-			return Token{Type: TokenQuote, Literal: text}
+			return l.token(TokenQuote, text, pos)
 		case scanner.String:
-			// 文字列リテラルの場合、囲みのクォートを除去
-			unquoted, err := strconv.Unquote(text)
-			if err != nil {
-				unquoted = text
+			// 文字列リテラルの場合、囲みのクォートを除去してエスケープを解釈する
+			unquoted, ok := unquoteString(text)
+			if !ok {
+				return l.token(TokenIllegal, text, pos)
 			}
-			return Token{Type: TokenString, Literal: unquoted}
+			return l.token(TokenString, unquoted, pos)
 		case scanner.Int:
-			return Token{Type: TokenInteger, Literal: text}
+			// 整数に "/" と数字が続けば分数。text/scanner は "/3" を識別子として読むため、ここで続けて読み取る
+			if l.s.Peek() == '/' {
+				literal := []rune(text)
+				literal = append(literal, l.s.Next())
+				for ch := l.s.Peek(); unicode.IsDigit(ch); ch = l.s.Peek() {
+					literal = append(literal, l.s.Next())
+				}
+				if !isRationalLiteral(string(literal)) {
+					return l.token(TokenIllegal, string(literal), pos)
+				}
+				return l.token(TokenRational, string(literal), pos)
+			}
+			return l.token(TokenInteger, text, pos)
 		case scanner.Float:
-			return Token{Type: TokenFloat, Literal: text}
+			return l.token(TokenFloat, text, pos)
 		case scanner.Ident:
-			return Token{Type: TokenIdentifier, Literal: text}
+			// "#" の直後に '\' が続く場合は文字リテラル
+			if text == "#" && l.s.Peek() == '\\' {
+				name, ok := l.readCharName()
+				if !ok {
+					return l.token(TokenIllegal, "#\\"+name, pos)
+				}
+				return l.token(TokenChar, name, pos)
+			}
+			// "#" の直後に '(' が続く場合はベクタの開始
+			if text == "#" && l.s.Peek() == '(' {
+				l.s.Next()
+				return l.token(TokenVectorOpen, "#(", pos)
+			}
+			switch text {
+			case "#t", "#f", "#true", "#false":
+				return l.token(TokenBoolean, text, pos)
+			}
+			if typ, ok := datumLabel(text); ok {
+				return l.token(typ, text, pos)
+			}
+			if typ, literal, ok := l.prefixedNumber(text); ok {
+				return l.token(typ, literal, pos)
+			}
+			if typ, literal, ok := l.signedNumber(text); ok {
+				return l.token(typ, literal, pos)
+			}
+			if isRationalLiteral(text) {
+				return l.token(TokenRational, text, pos)
+			}
+			if typ, literal, ok := l.specialFloat(text); ok {
+				return l.token(typ, literal, pos)
+			}
+			return l.token(TokenIdentifier, text, pos)
+		case '.':
+			// 単独の "." は数値ではなく DOT トークン。"..." のように続く場合は識別子とする
+			// （".5" や "3." は text/scanner が浮動小数点数として読むため、ここには来ない）
+			if l.s.Peek() != '.' {
+				return l.token(TokenDot, text, pos)
+			}
+			for l.s.Peek() == '.' {
+				text += string(l.s.Next())
+			}
+			return l.token(TokenIdentifier, text, pos)
+		case '|':
+			name, ok := l.readPipeSymbol()
+			if !ok {
+				return l.token(TokenIllegal, "|"+name, pos)
+			}
+			return l.token(TokenIdentifier, name, pos)
+		case '[', ']', '{', '}':
+			// 使えない文字は1文字の不正なトークンとして報告する。
+			// 走査はその直後から続けられるため、呼び出し側は複数の字句エラーを集められる
+			return l.token(TokenIllegal, text, pos)
 		default:
 			// 改行、タブ、スペースなどはスキップ
 			if tok == '\n' || tok == '\r' || tok == '\t' || tok == ' ' {
 				continue
 			}
 			// 上記以外は識別子として扱う
-			return Token{Type: TokenIdentifier, Literal: text}
+			return l.token(TokenIdentifier, text, pos)
 		}
 	}
 }
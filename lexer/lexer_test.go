@@ -76,3 +76,426 @@ func TestLexerWithAdditionalCases(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerPositions(t *testing.T) {
+	input := "(foo \"bar\")\n  ; note\n42"
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	// 期待するトークンの開始位置と直後の位置
+	expected := []struct {
+		typ        TokenType
+		start, end Position
+	}{
+		{TokenLParen, Position{0, 1, 1}, Position{1, 1, 2}},
+		{TokenIdentifier, Position{1, 1, 2}, Position{4, 1, 5}},
+		{TokenString, Position{5, 1, 6}, Position{10, 1, 11}},
+		{TokenRParen, Position{10, 1, 11}, Position{11, 1, 12}},
+		{TokenComment, Position{14, 2, 3}, Position{20, 2, 9}},
+		{TokenInteger, Position{21, 3, 1}, Position{23, 3, 3}},
+	}
+
+	for i, exp := range expected {
+		token := lexer.NextToken()
+		if token.Type != exp.typ || token.Pos != exp.start || token.End != exp.end {
+			t.Errorf("Token %d: expected %s at %+v-%+v, got %s at %+v-%+v",
+				i, exp.typ, exp.start, exp.end, token.Type, token.Pos, token.End)
+		}
+	}
+}
+
+func TestLexerCharLiterals(t *testing.T) {
+	input := `#\a #\space #\( #\) #\あ`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenChar, Literal: "a"},
+		{Type: TokenChar, Literal: "space"},
+		{Type: TokenChar, Literal: "("},
+		{Type: TokenChar, Literal: ")"},
+		{Type: TokenChar, Literal: "あ"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerSignedNumbers(t *testing.T) {
+	input := `-1 +2 -1.5 -2.5e3 -3e2 - -x +`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenInteger, Literal: "-1"},
+		{Type: TokenInteger, Literal: "+2"},
+		{Type: TokenFloat, Literal: "-1.5"},
+		{Type: TokenFloat, Literal: "-2.5e3"},
+		{Type: TokenFloat, Literal: "-3e2"},
+		{Type: TokenIdentifier, Literal: "-"},
+		{Type: TokenIdentifier, Literal: "-x"},
+		{Type: TokenIdentifier, Literal: "+"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerPipeSymbols(t *testing.T) {
+	input := `|a b| |has\|bar| |back\\slash| (|x|)`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenIdentifier, Literal: "a b"},
+		{Type: TokenIdentifier, Literal: "has|bar"},
+		{Type: TokenIdentifier, Literal: `back\slash`},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "x"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+
+	// 閉じられていない |...| は不正なトークンになる
+	token := NewLexer(strings.NewReader("|abc")).NextToken()
+	if token.Type != TokenIllegal || token.Literal != "|abc" {
+		t.Errorf("expected (Illegal, %q), got (%s, %q)", "|abc", token.Type, token.Literal)
+	}
+}
+
+func TestTokenString(t *testing.T) {
+	lexer := NewLexer(strings.NewReader("(define x\n  \"hi\")"))
+
+	expected := []string{
+		`LParen("(")@1:1`,
+		`Identifier("define")@1:2`,
+		`Identifier("x")@1:9`,
+		`String("hi")@2:3`,
+		`RParen(")")@2:7`,
+		`EOF("")@2:8`,
+	}
+
+	for i, exp := range expected {
+		if got := lexer.NextToken().String(); got != exp {
+			t.Errorf("Token %d: expected %s, got %s", i, exp, got)
+		}
+	}
+}
+
+// TestTokenTypeNames はすべてのトークン種別が重複のない名前を持つことを確認します。
+func TestTokenTypeNames(t *testing.T) {
+	seen := make(map[string]TokenType)
+	for typ := TokenType(0); typ < numTokenTypes; typ++ {
+		name := typ.String()
+		if name == "Unknown" {
+			t.Errorf("TokenType %d has no name", int(typ))
+		}
+		if prev, ok := seen[name]; ok {
+			t.Errorf("TokenType %d and %d share the name %q", int(prev), int(typ), name)
+		}
+		seen[name] = typ
+	}
+	if got := numTokenTypes.String(); got != "Unknown" {
+		t.Errorf("expected out-of-range TokenType to be Unknown, got %q", got)
+	}
+}
+
+// TestLexerDecimalPoint は小数点が常に "." であること、末尾が "." の数値が浮動小数点数になること、
+// 単独の "." が数値ではなく DOT トークンになることを確認します。
+func TestLexerDecimalPoint(t *testing.T) {
+	input := `3. . 3.5 3,5 (a . b) ...`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenFloat, Literal: "3."},
+		{Type: TokenDot, Literal: "."},
+		{Type: TokenFloat, Literal: "3.5"},
+		// "," は小数点ではない
+		{Type: TokenInteger, Literal: "3"},
+		{Type: TokenIdentifier, Literal: ","},
+		{Type: TokenInteger, Literal: "5"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "a"},
+		{Type: TokenDot, Literal: "."},
+		{Type: TokenIdentifier, Literal: "b"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenIdentifier, Literal: "..."},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+// TestLexerIllegalRecovery は使えない文字を不正なトークンとして報告したあとも、
+// 続くトークンを読み進められることを確認します。
+func TestLexerIllegalRecovery(t *testing.T) {
+	input := "(foo 1)\n  ] (bar 2)"
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "foo"},
+		{Type: TokenInteger, Literal: "1"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenIllegal, Literal: "]"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "bar"},
+		{Type: TokenInteger, Literal: "2"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	var illegal []Token
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+		if token.Type == TokenIllegal {
+			illegal = append(illegal, token)
+		}
+	}
+
+	if len(illegal) != 1 {
+		t.Fatalf("expected 1 illegal token, got %d", len(illegal))
+	}
+	if want := (Position{10, 2, 3}); illegal[0].Pos != want {
+		t.Errorf("expected illegal token at %+v, got %+v", want, illegal[0].Pos)
+	}
+}
+
+func TestLexerSpecialFloats(t *testing.T) {
+	input := `+inf.0 -inf.0 +nan.0 -nan.0 (f +inf.0) inf +inf`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenFloat, Literal: "+inf.0"},
+		{Type: TokenFloat, Literal: "-inf.0"},
+		{Type: TokenFloat, Literal: "+nan.0"},
+		{Type: TokenFloat, Literal: "-nan.0"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "f"},
+		{Type: TokenFloat, Literal: "+inf.0"},
+		{Type: TokenRParen, Literal: ")"},
+		// ".0" が続かなければ通常の識別子
+		{Type: TokenIdentifier, Literal: "inf"},
+		{Type: TokenIdentifier, Literal: "+inf"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerRationals(t *testing.T) {
+	input := `1/3 -1/3 +2/4 (/ 1 3) a/b 1/`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenRational, Literal: "1/3"},
+		{Type: TokenRational, Literal: "-1/3"},
+		{Type: TokenRational, Literal: "+2/4"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "/"},
+		{Type: TokenInteger, Literal: "1"},
+		{Type: TokenInteger, Literal: "3"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenIdentifier, Literal: "a/b"},
+		// 分母のない分数は不正なトークン
+		{Type: TokenIllegal, Literal: "1/"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerHexEscapes(t *testing.T) {
+	input := `"\x41;" "\x3042;" "a\x62;c\n" #\x41; #\x3042; #\x "\x41" "\x110000;" "\xZZ;" #\x110000;`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenString, Literal: "A"},
+		{Type: TokenString, Literal: "あ"},
+		{Type: TokenString, Literal: "abc\n"},
+		{Type: TokenChar, Literal: "A"},
+		{Type: TokenChar, Literal: "あ"},
+		{Type: TokenChar, Literal: "x"},
+		// ";" で終わらない16進数のエスケープや、符号位置として不正な値は不正なトークン
+		{Type: TokenIllegal, Literal: `"\x41"`},
+		{Type: TokenIllegal, Literal: `"\x110000;"`},
+		{Type: TokenIllegal, Literal: `"\xZZ;"`},
+		{Type: TokenIllegal, Literal: `#\x110000;`},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerNumberPrefixes(t *testing.T) {
+	input := `#e3.0 #i3 #e1.5 #i1/2 #xff #X1F #b-101 #o17/2 #d12 #e#xff #x#e10 #i#b11 (#e3.0) #e+inf.0 #i+inf.0 #e#e1 #x1.5 #xzz #t`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenInteger, Literal: "3"},
+		{Type: TokenFloat, Literal: "3.0"},
+		{Type: TokenRational, Literal: "3/2"},
+		{Type: TokenFloat, Literal: "0.5"},
+		{Type: TokenInteger, Literal: "255"},
+		{Type: TokenInteger, Literal: "31"},
+		{Type: TokenInteger, Literal: "-5"},
+		{Type: TokenRational, Literal: "15/2"},
+		{Type: TokenInteger, Literal: "12"},
+		// 基数と正確性の接頭辞はどちらの順でも組み合わせられる
+		{Type: TokenInteger, Literal: "255"},
+		{Type: TokenInteger, Literal: "16"},
+		{Type: TokenFloat, Literal: "3.0"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenInteger, Literal: "3"},
+		{Type: TokenRParen, Literal: ")"},
+		// 無限大は正確数で表せない
+		{Type: TokenIllegal, Literal: "#e+inf.0"},
+		{Type: TokenFloat, Literal: "+inf.0"},
+		{Type: TokenIllegal, Literal: "#e#e1"},
+		{Type: TokenIllegal, Literal: "#x1.5"},
+		{Type: TokenIllegal, Literal: "#xzz"},
+		{Type: TokenBoolean, Literal: "#t"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerTrailingComment(t *testing.T) {
+	for _, input := range []string{"; comment", "; comment\n"} {
+		lexer := NewLexer(strings.NewReader(input))
+		if token := lexer.NextToken(); token.Type != TokenComment || token.Literal != "; comment" {
+			t.Errorf("%q: expected (%s, %q), got (%s, %q)", input, TokenComment, "; comment", token.Type, token.Literal)
+		}
+		if token := lexer.NextToken(); token.Type != TokenEOF {
+			t.Errorf("%q: expected EOF, got (%s, %q)", input, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerDatumLabels(t *testing.T) {
+	input := `(#1=(a b) #1#) #0='x #12# #1 #1=a #2=#2# #x1`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenDatumLabel, Literal: "#1="},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "a"},
+		{Type: TokenIdentifier, Literal: "b"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenDatumRef, Literal: "#1#"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenDatumLabel, Literal: "#0="},
+		{Type: TokenQuote, Literal: "'"},
+		{Type: TokenIdentifier, Literal: "x"},
+		{Type: TokenDatumRef, Literal: "#12#"},
+		// "=" や "#" で終わらなければデータラベルではない
+		{Type: TokenIdentifier, Literal: "#1"},
+		// 定義の直後に空白なしで続くデータは別のトークン
+		{Type: TokenDatumLabel, Literal: "#1="},
+		{Type: TokenIdentifier, Literal: "a"},
+		{Type: TokenDatumLabel, Literal: "#2="},
+		{Type: TokenDatumRef, Literal: "#2#"},
+		{Type: TokenInteger, Literal: "1"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
+
+func TestLexerVectorOpen(t *testing.T) {
+	input := `#(1 #(a)) # (b)`
+
+	lexer := NewLexer(strings.NewReader(input))
+
+	expectedTokens := []Token{
+		{Type: TokenVectorOpen, Literal: "#("},
+		{Type: TokenInteger, Literal: "1"},
+		{Type: TokenVectorOpen, Literal: "#("},
+		{Type: TokenIdentifier, Literal: "a"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenRParen, Literal: ")"},
+		// "#" と "(" の間に空白があればベクタではない
+		{Type: TokenIdentifier, Literal: "#"},
+		{Type: TokenLParen, Literal: "("},
+		{Type: TokenIdentifier, Literal: "b"},
+		{Type: TokenRParen, Literal: ")"},
+		{Type: TokenEOF, Literal: ""},
+	}
+
+	for i, expected := range expectedTokens {
+		token := lexer.NextToken()
+		if token.Type != expected.Type || token.Literal != expected.Literal {
+			t.Errorf("Token %d: expected (%s, %q), got (%s, %q)",
+				i, expected.Type, expected.Literal, token.Type, token.Literal)
+		}
+	}
+}
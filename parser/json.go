@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jsonExpr は式を JSON で表現する際の形式です。
+// Type で式の種類を区別し、リストとベクタは Items、それ以外は Value に値を保持します。
+type jsonExpr struct {
+	Type  string            `json:"type"`
+	Value json.RawMessage   `json:"value,omitempty"`
+	Items []json.RawMessage `json:"items,omitempty"`
+}
+
+// MarshalExpr は式を JSON にエンコードします。
+// 例: 42 → {"type":"integer","value":42}、(a "b") → {"type":"list","items":[...]}
+func MarshalExpr(expr Expr) ([]byte, error) {
+	var typ string
+	var value any
+	switch v := expr.(type) {
+	case Integer:
+		typ, value = "integer", int64(v)
+	case Float:
+		typ, value = "float", float64(v)
+	case Rational:
+		typ, value = "rational", v.String()
+	case String:
+		typ, value = "string", string(v)
+	case Symbol:
+		typ, value = "symbol", string(v)
+	case Boolean:
+		typ, value = "boolean", bool(v)
+	case Char:
+		typ, value = "char", string(rune(v))
+	case Comment:
+		typ, value = "comment", string(v)
+	case List:
+		return marshalItems("list", v)
+	case *Vector:
+		return marshalItems("vector", v.Elems)
+	default:
+		return nil, fmt.Errorf("cannot marshal expression of type %T", expr)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonExpr{Type: typ, Value: b})
+}
+
+// marshalItems はリストやベクタの要素を Items とする typ の JSON を返します。
+func marshalItems(typ string, elems []Expr) ([]byte, error) {
+	items := make([]json.RawMessage, len(elems))
+	for i, elem := range elems {
+		b, err := MarshalExpr(elem)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = b
+	}
+	return json.Marshal(jsonExpr{Type: typ, Items: items})
+}
+
+// UnmarshalExpr は MarshalExpr でエンコードされた JSON から式を復元します。
+func UnmarshalExpr(data []byte) (Expr, error) {
+	var j jsonExpr
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	switch j.Type {
+	case "integer":
+		var v int64
+		err := json.Unmarshal(j.Value, &v)
+		return Integer(v), err
+	case "float":
+		var v float64
+		err := json.Unmarshal(j.Value, &v)
+		return Float(v), err
+	case "string", "symbol", "comment", "char":
+		var v string
+		if err := json.Unmarshal(j.Value, &v); err != nil {
+			return nil, err
+		}
+		switch j.Type {
+		case "string":
+			return String(v), nil
+		case "symbol":
+			return Symbol(v), nil
+		case "comment":
+			return Comment(v), nil
+		}
+		runes := []rune(v)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("invalid char value: %q", v)
+		}
+		return Char(runes[0]), nil
+	case "rational":
+		var v string
+		if err := json.Unmarshal(j.Value, &v); err != nil {
+			return nil, err
+		}
+		r, ok := new(big.Rat).SetString(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid rational value: %q", v)
+		}
+		return NewRational(r), nil
+	case "boolean":
+		var v bool
+		err := json.Unmarshal(j.Value, &v)
+		return Boolean(v), err
+	case "list", "vector":
+		elems := make([]Expr, len(j.Items))
+		for i, item := range j.Items {
+			elem, err := UnmarshalExpr(item)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		if j.Type == "vector" {
+			return &Vector{Elems: elems}, nil
+		}
+		return List(elems), nil
+	default:
+		return nil, fmt.Errorf("unknown expression type: %q", j.Type)
+	}
+}
@@ -3,6 +3,8 @@ package parser
 import (
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
 
 	"github.com/Warashi/lispish/lexer"
@@ -20,38 +22,136 @@ type Integer int64
 // Float は浮動小数点数リテラルを表します。
 type Float float64
 
+// specialFloats は無限大と NaN のリテラルとその値の対応表です。
+var specialFloats = map[string]float64{
+	"+inf.0": math.Inf(1),
+	"-inf.0": math.Inf(-1),
+	"+nan.0": math.NaN(),
+	"-nan.0": math.NaN(),
+}
+
+// Boolean は真偽値リテラル（#t, #f）を表します。
+type Boolean bool
+
+// Char は文字リテラル（#\a など）を表します。
+type Char rune
+
+// charNames は名前付き文字リテラルとその文字の対応表です。
+var charNames = map[string]rune{
+	"space":     ' ',
+	"newline":   '\n',
+	"tab":       '\t',
+	"return":    '\r',
+	"nul":       0,
+	"null":      0,
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+}
+
+// parseCharName は文字リテラルの名前から文字を求めます。
+func parseCharName(name string) (Char, error) {
+	if r, ok := charNames[name]; ok {
+		return Char(r), nil
+	}
+	runes := []rune(name)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("invalid character literal: #\\%s", name)
+	}
+	return Char(runes[0]), nil
+}
+
 // String は文字列リテラルを表します。
 type String string
 
 // List は Scheme のリスト（S式）を表します。
 type List []Expr
 
+// Vector は Scheme のベクタ（#(1 2 3) など）を表します。
+// 要素の変更や伸長（heap-push! など）が共有されるよう、ポインタで扱います。
+type Vector struct {
+	Elems []Expr
+}
+
 // Comment は Scheme のコメントを表します。
 type Comment string
 
+// Span はソース上で式が占める範囲を表します。End は式の最後のトークンの直後の位置です。
+type Span struct {
+	Start lexer.Position
+	End   lexer.Position
+}
+
 // Parser は lexer からのトークンをもとに Scheme の式を構文解析します。
 type Parser struct {
 	l        *lexer.Lexer
 	curToken lexer.Token
+	// prevEnd は直前に消費したトークンの直後の位置です。
+	prevEnd lexer.Position
+	// keepComments が真なら、コメントを Comment として結果に含めます。
+	keepComments bool
+	// openParens は読み込み中のリストの開き括弧の位置のスタックです。
+	openParens []lexer.Position
+	// lastOpen は最後に読んだ開き括弧の位置で、hasOpen はそれが存在するかを表します。
+	// 対応しない ')' のエラーで、どの括弧と対応させるつもりだったのかのヒントに使います。
+	lastOpen lexer.Position
+	hasOpen  bool
+	// labels は読み込み中の式で定義されたデータラベル（#1= など）とその値です。
+	// ラベルの有効範囲は ParseExpr で読む1つの式で、次の式を読むときに破棄します。
+	labels map[int]Expr
+}
+
+// datumPlaceholder は定義を読み終える前に参照されたデータラベルの仮の値です。
+// #0=(a #0#) のような循環する構造では、ラベルの定義を読み終えてから実際の値に置き換えます。
+type datumPlaceholder struct {
+	label int
+	used  bool
+}
+
+// Option は NewParser に渡すパーサの設定です。
+type Option func(*Parser)
+
+// KeepComments はコメントを Comment として ParseAll などの結果に含めるかを設定します。
+// フォーマッタのようにコメントを残す必要がある場合に有効にします。
+// 既定では無効で、評価に不要なコメントは読み飛ばします。
+func KeepComments(keep bool) Option {
+	return func(p *Parser) {
+		p.keepComments = keep
+	}
 }
 
 // NewParser は入力リーダーからパーサを初期化して返します。
-func NewParser(r io.Reader) *Parser {
+func NewParser(r io.Reader, opts ...Option) *Parser {
 	p := &Parser{
 		l: lexer.NewLexer(r),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 	p.nextToken() // 最初のトークンを取得
 	return p
 }
 
-// nextToken は次のトークンを取得します（コメントはスキップ）。
+// nextToken は次のトークンを取得します（KeepComments が無効ならコメントはスキップ）。
 func (p *Parser) nextToken() {
+	p.prevEnd = p.curToken.End
 	tok := p.l.NextToken()
+	for !p.keepComments && tok.Type == lexer.TokenComment {
+		tok = p.l.NextToken()
+	}
 	p.curToken = tok
 }
 
 // ParseExpr は1つの Scheme 式をパースして返します。
+// 式の中では #1=(a b) のようにデータラベルを定義し、#1# でその値を共有して参照できます。
 func (p *Parser) ParseExpr() (Expr, error) {
+	p.labels = nil
+	return p.parseExpr()
+}
+
+// parseExpr は1つの式をパースします。ParseExpr と異なり、データラベルを引き継ぎます。
+func (p *Parser) parseExpr() (Expr, error) {
 	switch p.curToken.Type {
 	case lexer.TokenEOF:
 		return nil, io.EOF
@@ -65,14 +165,39 @@ func (p *Parser) ParseExpr() (Expr, error) {
 		p.nextToken()
 		return expr, nil
 	case lexer.TokenFloat:
-		// 浮動小数点数リテラルをパース
-		val, err := strconv.ParseFloat(p.curToken.Literal, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid float literal: %s", p.curToken.Literal)
+		// 浮動小数点数リテラルをパース（+inf.0 などは specialFloats から求める）
+		val, ok := specialFloats[p.curToken.Literal]
+		if !ok {
+			var err error
+			if val, err = strconv.ParseFloat(p.curToken.Literal, 64); err != nil {
+				return nil, fmt.Errorf("invalid float literal: %s", p.curToken.Literal)
+			}
 		}
 		expr := Float(val)
 		p.nextToken()
 		return expr, nil
+	case lexer.TokenRational:
+		// 分数リテラルをパース（既約化し、整数になる 4/2 などは Integer にする）
+		r, ok := new(big.Rat).SetString(p.curToken.Literal)
+		if !ok {
+			return nil, fmt.Errorf("invalid rational literal: %s", p.curToken.Literal)
+		}
+		expr := NewRational(r)
+		p.nextToken()
+		return expr, nil
+	case lexer.TokenBoolean:
+		// 真偽値リテラル
+		expr := Boolean(p.curToken.Literal == "#t" || p.curToken.Literal == "#true")
+		p.nextToken()
+		return expr, nil
+	case lexer.TokenChar:
+		// 文字リテラル
+		expr, err := parseCharName(p.curToken.Literal)
+		if err != nil {
+			return nil, err
+		}
+		p.nextToken()
+		return expr, nil
 	case lexer.TokenString:
 		// 文字列リテラル
 		expr := String(p.curToken.Literal)
@@ -85,31 +210,105 @@ func (p *Parser) ParseExpr() (Expr, error) {
 		return expr, nil
 	case lexer.TokenLParen:
 		return p.parseList()
+	case lexer.TokenVectorOpen:
+		return p.parseVector()
 	case lexer.TokenQuote:
 		return p.parseQuote()
+	case lexer.TokenDatumLabel:
+		return p.parseLabeledDatum()
+	case lexer.TokenDatumRef:
+		return p.parseDatumRef()
 	case lexer.TokenComment:
 		// コメントをパース
 		expr := Comment(p.curToken.Literal)
 		p.nextToken()
 		return expr, nil
 	case lexer.TokenRParen:
-		return nil, fmt.Errorf("unexpected ')'")
+		return nil, p.unexpectedRParenError()
+	case lexer.TokenDot:
+		return nil, fmt.Errorf("unexpected '.'")
+	case lexer.TokenIllegal:
+		pos := p.curToken.Pos
+		return nil, fmt.Errorf("illegal token %q at line %d, column %d", p.curToken.Literal, pos.Line, pos.Column)
 	default:
 		return nil, fmt.Errorf("unexpected token: %v", p.curToken)
 	}
 }
 
+// ParseExprAt は1つの Scheme 式をパースし、その式がソース上で占めた範囲とともに返します。
+// 呼び出し側は Span.End 以降の入力から続けてパースできます。
+func (p *Parser) ParseExprAt() (Expr, Span, error) {
+	start := p.curToken.Pos
+	expr, err := p.ParseExpr()
+	if err != nil {
+		return nil, Span{Start: start, End: start}, err
+	}
+	return expr, Span{Start: start, End: p.prevEnd}, nil
+}
+
+// unexpectedRParenError は式が始まるべき位置に ')' が現れたときのエラーを、位置と対応する括弧のヒントとともに返します。
+func (p *Parser) unexpectedRParenError() error {
+	pos := p.curToken.Pos
+	switch {
+	case len(p.openParens) > 0:
+		// リストの中で ')' が式として読まれるのは、'( の直後など引用する式がない場合
+		return fmt.Errorf("line %d col %d: unexpected ')' — expected an expression after quote", pos.Line, pos.Column)
+	case p.hasOpen:
+		return fmt.Errorf("line %d col %d: unexpected ')' — no matching '(' (last open paren was at line %d col %d)",
+			pos.Line, pos.Column, p.lastOpen.Line, p.lastOpen.Column)
+	default:
+		return fmt.Errorf("line %d col %d: unexpected ')' — no matching '('", pos.Line, pos.Column)
+	}
+}
+
 // parseList はリスト式をパースします。
 func (p *Parser) parseList() (Expr, error) {
-	// 現在のトークンは '(' なので、これを消費
+	elems, err := p.parseElements(true)
+	if err != nil {
+		return nil, err
+	}
+	return List(elems), nil
+}
+
+// parseVector は #(elem...) をパースします。ベクタの中では "." は使えません。
+func (p *Parser) parseVector() (Expr, error) {
+	elems, err := p.parseElements(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Vector{Elems: elems}, nil
+}
+
+// parseElements は '(' または "#(" から対応する ')' までの要素をパースします。
+// allowDot が真なら、要素の間の "." をシンボル "." として残します。
+func (p *Parser) parseElements(allowDot bool) ([]Expr, error) {
+	// 現在のトークンは '(' か "#(" なので、その位置を記録してから消費
+	open := p.curToken.Pos
+	p.openParens = append(p.openParens, open)
+	p.lastOpen, p.hasOpen = open, true
+	defer func() { p.openParens = p.openParens[:len(p.openParens)-1] }()
 	p.nextToken()
-	var list List
+	var list []Expr
 	// ')' が現れるまで式を読み込む
 	for p.curToken.Type != lexer.TokenRParen {
 		if p.curToken.Type == lexer.TokenEOF {
-			return nil, fmt.Errorf("unexpected EOF while reading list")
+			// 位置は入力の終わり（最後のトークンの直後）
+			pos := p.prevEnd
+			return nil, fmt.Errorf("line %d col %d: unexpected EOF while reading list — unclosed '(' at line %d col %d",
+				pos.Line, pos.Column, open.Line, open.Column)
 		}
-		expr, err := p.ParseExpr()
+		// ドット対は表現できないため、リスト中の "." はシンボル "." として残し、
+		// (a . rest) のような記法の解釈は呼び出し側に任せる
+		if p.curToken.Type == lexer.TokenDot {
+			if !allowDot {
+				pos := p.curToken.Pos
+				return nil, fmt.Errorf("line %d col %d: unexpected '.' in vector", pos.Line, pos.Column)
+			}
+			list = append(list, Symbol("."))
+			p.nextToken()
+			continue
+		}
+		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
@@ -125,7 +324,7 @@ func (p *Parser) parseList() (Expr, error) {
 func (p *Parser) parseQuote() (Expr, error) {
 	// クォートトークンを消費
 	p.nextToken()
-	expr, err := p.ParseExpr()
+	expr, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +332,87 @@ func (p *Parser) parseQuote() (Expr, error) {
 	return List{Symbol("quote"), expr}, nil
 }
 
+// datumLabelNumber はデータラベルのトークン（#1= または #1#）からラベルの番号を取り出します。
+func datumLabelNumber(tok lexer.Token) (int, error) {
+	n, err := strconv.Atoi(tok.Literal[1 : len(tok.Literal)-1])
+	if err != nil {
+		return 0, fmt.Errorf("line %d col %d: invalid datum label %s", tok.Pos.Line, tok.Pos.Column, tok.Literal)
+	}
+	return n, nil
+}
+
+// parseLabeledDatum は #1=datum をパースし、datum をラベルとともに登録して返します。
+// datum の中でラベル自身を参照していれば、読み終えてから datum に置き換えて循環する構造にします。
+func (p *Parser) parseLabeledDatum() (Expr, error) {
+	tok := p.curToken
+	n, err := datumLabelNumber(tok)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.labels[n]; ok {
+		return nil, fmt.Errorf("line %d col %d: duplicate datum label #%d=", tok.Pos.Line, tok.Pos.Column, n)
+	}
+	if p.labels == nil {
+		p.labels = make(map[int]Expr)
+	}
+	placeholder := &datumPlaceholder{label: n}
+	p.labels[n] = placeholder
+	p.nextToken()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if ph, ok := expr.(*datumPlaceholder); ok {
+		return nil, fmt.Errorf("line %d col %d: datum label #%d= has no datum other than #%d#", tok.Pos.Line, tok.Pos.Column, n, ph.label)
+	}
+	if placeholder.used {
+		resolvePlaceholder(expr, placeholder, expr, make(map[*Expr]bool))
+	}
+	p.labels[n] = expr
+	return expr, nil
+}
+
+// parseDatumRef は #1# をパースし、ラベルに登録された値を返します。
+func (p *Parser) parseDatumRef() (Expr, error) {
+	tok := p.curToken
+	n, err := datumLabelNumber(tok)
+	if err != nil {
+		return nil, err
+	}
+	expr, ok := p.labels[n]
+	if !ok {
+		return nil, fmt.Errorf("line %d col %d: undefined datum label #%d#", tok.Pos.Line, tok.Pos.Column, n)
+	}
+	if ph, ok := expr.(*datumPlaceholder); ok {
+		ph.used = true
+	}
+	p.nextToken()
+	return expr, nil
+}
+
+// resolvePlaceholder は expr に含まれる placeholder を value に置き換えます。
+// 同じリストを何度もたどらないように、seen に訪れたリストの先頭要素のアドレスを記録します。
+func resolvePlaceholder(expr Expr, placeholder *datumPlaceholder, value Expr, seen map[*Expr]bool) {
+	var list []Expr
+	switch v := expr.(type) {
+	case List:
+		list = v
+	case *Vector:
+		list = v.Elems
+	}
+	if len(list) == 0 || seen[&list[0]] {
+		return
+	}
+	seen[&list[0]] = true
+	for i, elem := range list {
+		if ph, ok := elem.(*datumPlaceholder); ok && ph == placeholder {
+			list[i] = value
+			continue
+		}
+		resolvePlaceholder(elem, placeholder, value, seen)
+	}
+}
+
 // ParseAll は入力全体から式を読み込み、式のスライスを返します。
 func (p *Parser) ParseAll() ([]Expr, error) {
 	var exprs []Expr
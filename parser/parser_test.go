@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"math"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -109,15 +111,17 @@ func TestParser_QuoteExpression(t *testing.T) {
 	}
 }
 
-// TestParser_Comments tests the parsing of comments.
-func TestParser_Comments(t *testing.T) {
-	input := `
+// commentsInput is the input shared by the comment tests.
+const commentsInput = `
     ; This is a comment
     (define x 42) ; Another comment
     ; Comment before quoted expression
     '(1 2 3) ; Comment after quoted expression
     `
-	p := NewParser(strings.NewReader(input))
+
+// TestParser_Comments tests the parsing of comments with KeepComments enabled.
+func TestParser_Comments(t *testing.T) {
+	p := NewParser(strings.NewReader(commentsInput), KeepComments(true))
 	exprs, err := p.ParseAll()
 	if err != nil {
 		t.Fatalf("ParseAll error: %v", err)
@@ -190,3 +194,362 @@ func TestParser_Comments(t *testing.T) {
 		t.Errorf("expected sixth expression to be a Comment, got %v", exprs[5])
 	}
 }
+
+// TestParser_DropComments tests that comments are skipped by default and with KeepComments(false),
+// including comments inside a list.
+func TestParser_DropComments(t *testing.T) {
+	expected := []Expr{
+		List{Symbol("define"), Symbol("x"), Integer(42)},
+		List{Symbol("quote"), List{Integer(1), Integer(2), Integer(3)}},
+	}
+	for name, p := range map[string]*Parser{
+		"default":             NewParser(strings.NewReader(commentsInput)),
+		"KeepComments(false)": NewParser(strings.NewReader(commentsInput), KeepComments(false)),
+	} {
+		exprs, err := p.ParseAll()
+		if err != nil {
+			t.Fatalf("%s: ParseAll error: %v", name, err)
+		}
+		if !reflect.DeepEqual(exprs, expected) {
+			t.Errorf("%s: expected %#v, got %#v", name, expected, exprs)
+		}
+	}
+
+	exprs, err := NewParser(strings.NewReader("(a ; inner\n b)")).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if want := []Expr{List{Symbol("a"), Symbol("b")}}; !reflect.DeepEqual(exprs, want) {
+		t.Errorf("expected %#v, got %#v", want, exprs)
+	}
+}
+
+// TestParser_ParseExprAt tests that ParseExprAt reports the span of the parsed expression.
+func TestParser_ParseExprAt(t *testing.T) {
+	input := "  (define (square x)\n    (* x x))  42 'foo"
+	p := NewParser(strings.NewReader(input))
+
+	expr, span, err := p.ParseExprAt()
+	if err != nil {
+		t.Fatalf("ParseExprAt error: %v", err)
+	}
+	if list, ok := expr.(List); !ok || len(list) != 3 {
+		t.Fatalf("expected a define list of length 3, got %v", expr)
+	}
+	// The span starts at the opening paren and ends just after the final ')'.
+	wantEnd := strings.Index(input, "))") + 2
+	if span.Start.Offset != 2 || span.Start.Line != 1 || span.Start.Column != 3 {
+		t.Errorf("unexpected span start: %+v", span.Start)
+	}
+	if span.End.Offset != wantEnd || span.End.Line != 2 || span.End.Column != 13 {
+		t.Errorf("unexpected span end: %+v (want offset %d)", span.End, wantEnd)
+	}
+	if rest := input[span.End.Offset:]; rest != "  42 'foo" {
+		t.Errorf("unexpected leftover input: %q", rest)
+	}
+
+	// Parsing continues from after the span.
+	expr, span, err = p.ParseExprAt()
+	if err != nil {
+		t.Fatalf("ParseExprAt error: %v", err)
+	}
+	if expr != Integer(42) || input[span.Start.Offset:span.End.Offset] != "42" {
+		t.Errorf("expected 42 spanning %q, got %v spanning %q", "42", expr, input[span.Start.Offset:span.End.Offset])
+	}
+	expr, span, err = p.ParseExprAt()
+	if err != nil {
+		t.Fatalf("ParseExprAt error: %v", err)
+	}
+	if !reflect.DeepEqual(expr, List{Symbol("quote"), Symbol("foo")}) || input[span.Start.Offset:span.End.Offset] != "'foo" {
+		t.Errorf("unexpected quoted expression %v spanning %q", expr, input[span.Start.Offset:span.End.Offset])
+	}
+}
+
+// TestParser_CharLiterals tests the parsing of character literals.
+func TestParser_CharLiterals(t *testing.T) {
+	p := NewParser(strings.NewReader(`(#\a #\space #\newline #\( #\あ)`))
+	expr, err := p.ParseExpr()
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+	expected := List{Char('a'), Char(' '), Char('\n'), Char('('), Char('あ')}
+	if !reflect.DeepEqual(expr, expected) {
+		t.Errorf("expected %v, got %v", expected, expr)
+	}
+
+	if _, err := NewParser(strings.NewReader(`#\bogus`)).ParseExpr(); err == nil {
+		t.Error("expected error for unknown character name, got nil")
+	}
+}
+
+// TestMarshalExpr tests that expressions survive a JSON round trip.
+func TestMarshalExpr(t *testing.T) {
+	expr := List{Symbol("define"), Symbol("x"), List{Integer(1), Float(2.5), String("three"), Boolean(true), Char('λ'), NewRational(big.NewRat(-2, 3))}, &Vector{Elems: []Expr{Integer(1), List{Symbol("a")}}}}
+	data, err := MarshalExpr(expr)
+	if err != nil {
+		t.Fatalf("MarshalExpr error: %v", err)
+	}
+	got, err := UnmarshalExpr(data)
+	if err != nil {
+		t.Fatalf("UnmarshalExpr error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expr) {
+		t.Errorf("expected %#v, got %#v", expr, got)
+	}
+	if _, err := UnmarshalExpr([]byte(`{"type":"pair"}`)); err == nil {
+		t.Errorf("expected error for unknown type, got nil")
+	}
+}
+
+// TestParser_PipeSymbols tests that |...| symbols become Symbols and an unterminated one is an error.
+func TestParser_PipeSymbols(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`(|a b| |has\|bar|)`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	expected := []Expr{List{Symbol("a b"), Symbol("has|bar")}}
+	if !reflect.DeepEqual(exprs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, exprs)
+	}
+
+	_, err = NewParser(strings.NewReader("(foo |unterminated)")).ParseAll()
+	if err == nil || !strings.Contains(err.Error(), `illegal token "|unterminated)" at line 1, column 6`) {
+		t.Errorf("expected illegal token error, got %v", err)
+	}
+}
+
+// TestParser_DecimalPoint tests that "3." and "3.5" parse as floats and a lone "." is not a number.
+func TestParser_DecimalPoint(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`3. 3.5 -2. (a . b)`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	expected := []Expr{Float(3.0), Float(3.5), Float(-2.0), List{Symbol("a"), Symbol("."), Symbol("b")}}
+	if !reflect.DeepEqual(exprs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, exprs)
+	}
+
+	if _, err := NewParser(strings.NewReader(".")).ParseAll(); err == nil || !strings.Contains(err.Error(), "unexpected '.'") {
+		t.Errorf("expected unexpected '.' error, got %v", err)
+	}
+}
+
+// TestParser_SpecialFloats tests that +inf.0, -inf.0 and +nan.0/-nan.0 parse as IEEE 754 special values.
+func TestParser_SpecialFloats(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`+inf.0 -inf.0 +nan.0 -nan.0`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if len(exprs) != 4 {
+		t.Fatalf("expected 4 expressions, got %d", len(exprs))
+	}
+	if f, ok := exprs[0].(Float); !ok || !math.IsInf(float64(f), 1) {
+		t.Errorf("expected +Inf, got %#v", exprs[0])
+	}
+	if f, ok := exprs[1].(Float); !ok || !math.IsInf(float64(f), -1) {
+		t.Errorf("expected -Inf, got %#v", exprs[1])
+	}
+	for _, expr := range exprs[2:] {
+		if f, ok := expr.(Float); !ok || !math.IsNaN(float64(f)) {
+			t.Errorf("expected NaN, got %#v", expr)
+		}
+	}
+}
+
+// TestParser_Rationals tests that rational literals are reduced and that integral ones become Integers.
+func TestParser_Rationals(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`1/3 -2/6 +3/4 4/2 (f 1/2)`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	expected := []string{"1/3", "-1/3", "3/4"}
+	for i, want := range expected {
+		r, ok := exprs[i].(Rational)
+		if !ok || r.String() != want {
+			t.Errorf("expression %d: expected Rational %s, got %#v", i, want, exprs[i])
+		}
+	}
+	if exprs[3] != Integer(2) {
+		t.Errorf("expected 4/2 to parse as Integer 2, got %#v", exprs[3])
+	}
+	if list, ok := exprs[4].(List); !ok || len(list) != 2 {
+		t.Errorf("expected (f 1/2) to parse as a two-element list, got %#v", exprs[4])
+	} else if r, ok := list[1].(Rational); !ok || r.String() != "1/2" {
+		t.Errorf("expected 1/2 inside the list, got %#v", list[1])
+	}
+
+	if _, err := NewParser(strings.NewReader("1/0")).ParseAll(); err == nil || !strings.Contains(err.Error(), "invalid rational literal") {
+		t.Errorf("expected invalid rational literal error, got %v", err)
+	}
+}
+
+// TestParser_NumberPrefixes tests the radix prefixes #b/#o/#d/#x and the exactness prefixes #e/#i.
+func TestParser_NumberPrefixes(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`#e3.0 #i3 #e#xff #e0.25 #i1/4`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	if exprs[0] != Integer(3) {
+		t.Errorf("expected #e3.0 to parse as Integer 3, got %#v", exprs[0])
+	}
+	if exprs[1] != Float(3) {
+		t.Errorf("expected #i3 to parse as Float 3.0, got %#v", exprs[1])
+	}
+	if exprs[2] != Integer(255) {
+		t.Errorf("expected #e#xff to parse as Integer 255, got %#v", exprs[2])
+	}
+	if r, ok := exprs[3].(Rational); !ok || r.String() != "1/4" {
+		t.Errorf("expected #e0.25 to parse as Rational 1/4, got %#v", exprs[3])
+	}
+	if exprs[4] != Float(0.25) {
+		t.Errorf("expected #i1/4 to parse as Float 0.25, got %#v", exprs[4])
+	}
+
+	// infinities have no exact representation
+	if _, err := NewParser(strings.NewReader("#e+inf.0")).ParseAll(); err == nil || !strings.Contains(err.Error(), "#e+inf.0") {
+		t.Errorf("expected error for #e+inf.0, got %v", err)
+	}
+}
+
+// TestParser_UnbalancedParens tests that unbalanced parens are reported with their position and a hint about the matching paren.
+func TestParser_UnbalancedParens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a b)\n    )", "line 2 col 5: unexpected ')' — no matching '(' (last open paren was at line 1 col 1)"},
+		{"(a (b))\n)", "line 2 col 1: unexpected ')' — no matching '(' (last open paren was at line 1 col 4)"},
+		{"  )", "line 1 col 3: unexpected ')' — no matching '('"},
+		{"(f '))", "line 1 col 5: unexpected ')' — expected an expression after quote"},
+		// the innermost unclosed paren is reported
+		{"(define (f x)\n  (g x)", "line 2 col 8: unexpected EOF while reading list — unclosed '(' at line 1 col 1"},
+		{"(a\n (b c", "line 2 col 6: unexpected EOF while reading list — unclosed '(' at line 2 col 2"},
+	}
+	for _, tt := range tests {
+		_, err := NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestParser_CommentOnly tests that comment-only and whitespace-only input parses without error,
+// keeping the comments (including a trailing one without a newline) only when KeepComments is enabled.
+func TestParser_CommentOnly(t *testing.T) {
+	tests := []struct {
+		input string
+		kept  []Expr
+	}{
+		{"; only a comment", []Expr{Comment("; only a comment")}},
+		{"; only a comment\n", []Expr{Comment("; only a comment")}},
+		{"; one\n  ; two", []Expr{Comment("; one"), Comment("; two")}},
+		{";", []Expr{Comment(";")}},
+		{"  \n\t ", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		skipped, err := NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.input, err)
+		} else if len(skipped) != 0 {
+			t.Errorf("%q: expected no expressions, got %#v", tt.input, skipped)
+		}
+		kept, err := NewParser(strings.NewReader(tt.input), KeepComments(true)).ParseAll()
+		if err != nil {
+			t.Errorf("%q: keep comments: unexpected error: %v", tt.input, err)
+		} else if !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("%q: keep comments: expected %#v, got %#v", tt.input, tt.kept, kept)
+		}
+	}
+}
+
+// TestParser_Booleans tests that #t/#f and their long forms #true/#false parse as Boolean values rather than symbols.
+func TestParser_Booleans(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader(`#t #f #true #false (#t x)`)).ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll error: %v", err)
+	}
+	expected := []Expr{Boolean(true), Boolean(false), Boolean(true), Boolean(false), List{Boolean(true), Symbol("x")}}
+	if !reflect.DeepEqual(exprs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, exprs)
+	}
+}
+
+// TestParser_DatumLabels tests that #N= / #N# share the labeled datum, build cycles,
+// and are scoped to a single top-level expression.
+func TestParser_DatumLabels(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader("(#1=(a b) #1#) '#0=(x . #0#)")).ParseAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	shared := exprs[0].(List)
+	first, second := shared[0].(List), shared[1].(List)
+	if !reflect.DeepEqual(first, List{Symbol("a"), Symbol("b")}) {
+		t.Fatalf("expected (a b), got %#v", first)
+	}
+	if &first[0] != &second[0] || len(first) != len(second) {
+		t.Errorf("expected both elements to be the same list")
+	}
+
+	// a reference inside its own definition makes the list cyclic
+	cyclic := exprs[1].(List)[1].(List)
+	if len(cyclic) != 3 || cyclic[0] != Symbol("x") || cyclic[1] != Symbol(".") {
+		t.Fatalf("expected (x . #0#), got %d elements", len(cyclic))
+	}
+	if inner, ok := cyclic[2].(List); !ok || &inner[0] != &cyclic[0] {
+		t.Errorf("expected the last element to be the list itself")
+	}
+
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a #2#)", "line 1 col 4: undefined datum label #2#"},
+		{"(#1=a #1=b)", "line 1 col 7: duplicate datum label #1="},
+		{"#1=#1#", "line 1 col 1: datum label #1= has no datum other than #1#"},
+		// labels do not carry over to the next expression
+		{"#1=(a) #1#", "line 1 col 8: undefined datum label #1#"},
+	}
+	for _, tt := range errTests {
+		_, err := NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestParser_Vectors tests that #( ... ) reads a vector literal, including nested
+// vectors and labels, and that a dotted tail is rejected inside a vector.
+func TestParser_Vectors(t *testing.T) {
+	exprs, err := NewParser(strings.NewReader("#(1 (a) #()) '#(x) #(#1=(b) #1#)")).ParseAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Expr{
+		&Vector{Elems: []Expr{Integer(1), List{Symbol("a")}, &Vector{}}},
+		List{Symbol("quote"), &Vector{Elems: []Expr{Symbol("x")}}},
+	}
+	if !reflect.DeepEqual(exprs[:2], expected) {
+		t.Errorf("expected %#v, got %#v", expected, exprs[:2])
+	}
+	labeled := exprs[2].(*Vector)
+	first, second := labeled.Elems[0].(List), labeled.Elems[1].(List)
+	if &first[0] != &second[0] {
+		t.Errorf("expected both elements to be the same list")
+	}
+
+	errTests := []struct {
+		input    string
+		expected string
+	}{
+		{"#(a . b)", "line 1 col 5: unexpected '.' in vector"},
+		{"#(a", "line 1 col 4: unexpected EOF while reading list — unclosed '(' at line 1 col 1"},
+	}
+	for _, tt := range errTests {
+		_, err := NewParser(strings.NewReader(tt.input)).ParseAll()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package parser
+
+import "math/big"
+
+// Rational は正確な分数（1/3 など）を表します。
+// 値は常に既約で分母は正です。整数になる値は Integer で表すため、NewRational で生成してください。
+type Rational struct {
+	rat *big.Rat
+}
+
+// NewRational は r と等しい値の式を返します。r が int64 に収まる整数であれば Integer を、
+// そうでなければ Rational を返します。r は複製されるため、呼び出し側で再利用できます。
+func NewRational(r *big.Rat) Expr {
+	if r.IsInt() {
+		if n := r.Num(); n.IsInt64() {
+			return Integer(n.Int64())
+		}
+	}
+	return Rational{rat: new(big.Rat).Set(r)}
+}
+
+// Rat は値を *big.Rat として返します。返された値を変更しても Rational には影響しません。
+func (r Rational) Rat() *big.Rat {
+	if r.rat == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(r.rat)
+}
+
+// Num は分子を返します。
+func (r Rational) Num() *big.Int {
+	return r.Rat().Num()
+}
+
+// Den は分母を返します。
+func (r Rational) Den() *big.Int {
+	return r.Rat().Denom()
+}
+
+// Float64 は最も近い浮動小数点数を返します。
+func (r Rational) Float64() float64 {
+	f, _ := r.Rat().Float64()
+	return f
+}
+
+// String は "1/3" の形式で値を返します。
+func (r Rational) String() string {
+	return r.Rat().RatString()
+}
+
+// Equal は2つの Rational が等しい値であるかを判定します。
+func (r Rational) Equal(other Rational) bool {
+	return r.Rat().Cmp(other.Rat()) == 0
+}